@@ -1,3 +1,15 @@
 module servercommander
 
 go 1.23.5
+
+require (
+	github.com/pkg/sftp v1.13.9
+	golang.org/x/crypto v0.36.0
+	golang.org/x/term v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.31.0 // indirect
+)
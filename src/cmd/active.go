@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	ftpservice "servercommander/src/services/ftp"
+	sftpservice "servercommander/src/services/sftp"
+)
+
+// activeSessionIdle is the period after which an unused file transfer
+// connection is closed.
+const activeSessionIdle = 5 * time.Minute
+
+// activeSession is a file transfer connection kept open after a command
+// finished. Subsequent commands for the same alias reuse it instead of
+// reconnecting, and tab completion lists remote paths through it.
+type activeSession struct {
+	ftp      *ftpservice.Client
+	sftp     *sftpservice.Client
+	revision time.Time
+	lastUsed time.Time
+}
+
+var active = struct {
+	sync.Mutex
+	sessions map[string]*activeSession
+}{sessions: map[string]*activeSession{}}
+
+// takeActiveFTP removes and returns the open FTP connection for alias when it
+// is still alive and belongs to the current revision of the session.
+func takeActiveFTP(alias string, revision time.Time) *ftpservice.Client {
+	entry := takeActive(alias, revision)
+	if entry == nil || entry.ftp == nil {
+		closeActive(entry)
+		return nil
+	}
+	if err := entry.ftp.Ping(); err != nil {
+		entry.ftp.Close()
+		return nil
+	}
+	return entry.ftp
+}
+
+// takeActiveSFTP is the SFTP counterpart of takeActiveFTP.
+func takeActiveSFTP(alias string, revision time.Time) *sftpservice.Client {
+	entry := takeActive(alias, revision)
+	if entry == nil || entry.sftp == nil {
+		closeActive(entry)
+		return nil
+	}
+	if err := entry.sftp.Ping(); err != nil {
+		entry.sftp.Close()
+		return nil
+	}
+	return entry.sftp
+}
+
+// keepActiveFTP parks an FTP connection for reuse.
+func keepActiveFTP(alias string, revision time.Time, client *ftpservice.Client) {
+	keepActive(alias, &activeSession{ftp: client, revision: revision})
+}
+
+// keepActiveSFTP parks an SFTP connection for reuse.
+func keepActiveSFTP(alias string, revision time.Time, client *sftpservice.Client) {
+	keepActive(alias, &activeSession{sftp: client, revision: revision})
+}
+
+// peekActive returns the open connection for alias without taking ownership.
+// It is used by completion, which must never prompt for credentials.
+func peekActive(alias string) *activeSession {
+	active.Lock()
+	defer active.Unlock()
+	expireActive()
+	entry := active.sessions[strings.ToLower(alias)]
+	if entry != nil {
+		entry.lastUsed = time.Now()
+	}
+	return entry
+}
+
+func takeActive(alias string, revision time.Time) *activeSession {
+	active.Lock()
+	defer active.Unlock()
+	expireActive()
+
+	key := strings.ToLower(alias)
+	entry, ok := active.sessions[key]
+	if !ok {
+		return nil
+	}
+	delete(active.sessions, key)
+
+	if !entry.revision.Equal(revision) {
+		closeActive(entry)
+		return nil
+	}
+	return entry
+}
+
+func keepActive(alias string, entry *activeSession) {
+	active.Lock()
+	defer active.Unlock()
+
+	key := strings.ToLower(alias)
+	if previous, ok := active.sessions[key]; ok {
+		closeActive(previous)
+	}
+	entry.lastUsed = time.Now()
+	active.sessions[key] = entry
+	expireActive()
+}
+
+// expireActive must be called with the mutex held.
+func expireActive() {
+	for key, entry := range active.sessions {
+		if time.Since(entry.lastUsed) > activeSessionIdle {
+			closeActive(entry)
+			delete(active.sessions, key)
+		}
+	}
+}
+
+func closeActive(entry *activeSession) {
+	if entry == nil {
+		return
+	}
+	if entry.ftp != nil {
+		entry.ftp.Close()
+	}
+	if entry.sftp != nil {
+		entry.sftp.Close()
+	}
+}
+
+// closeActiveSessions closes every parked connection. It is called before the
+// program exits.
+func closeActiveSessions() {
+	active.Lock()
+	defer active.Unlock()
+	for key, entry := range active.sessions {
+		closeActive(entry)
+		delete(active.sessions, key)
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"servercommander/src/console"
 )
 
-func clearCommand(args []string) error {
+func clearCommand(*Context) error {
 	if console.ClearConsole() {
 		console.ApplicationBanner()
 		return nil
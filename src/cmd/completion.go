@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"servercommander/src/services/config"
+	"servercommander/src/utils"
+)
+
+// Complete returns the completion candidates for the last word of line. It is
+// used by the console when the user presses Tab. Candidates are escaped so
+// they can be inserted into the command line verbatim.
+func Complete(line string) []string {
+	fields, word, _ := utils.SplitCompletionLine(line)
+
+	if len(fields) == 0 {
+		return filterPrefix(commandPathCompletion(nil, word), word)
+	}
+
+	spec, exists := commandRegistry[strings.ToLower(fields[0])]
+	if !exists {
+		return nil
+	}
+
+	candidates := filterPrefix(spec.complete(fields[1:], word), word)
+	for i, candidate := range candidates {
+		candidates[i] = utils.QuoteArgument(candidate)
+	}
+	return candidates
+}
+
+// aliasCompletion completes stored session aliases, optionally restricted to
+// the given protocols.
+func aliasCompletion(protocols ...config.Protocol) CompletionFunc {
+	return func([]string, string) []string {
+		store, err := config.LoadSessions()
+		if err != nil {
+			return nil
+		}
+
+		aliases := []string{}
+		for _, session := range store.List() {
+			if len(protocols) > 0 && !containsProtocol(protocols, session.Protocol) {
+				continue
+			}
+			aliases = append(aliases, session.Alias)
+		}
+		return aliases
+	}
+}
+
+// localPathCompletion completes paths on the local file system.
+func localPathCompletion(_ []string, word string) []string {
+	dir, base := splitCompletionPath(word, string(filepath.Separator))
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	if strings.HasPrefix(readDir, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			readDir = filepath.Join(home, readDir[1:])
+		}
+	}
+
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+
+	candidates := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		candidate := dir + name
+		if entry.IsDir() {
+			candidate += string(filepath.Separator)
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// remotePathCompletion completes remote paths for the session named by the
+// argument at aliasIndex. Paths are only listed through an already active
+// connection because completion must not prompt for credentials.
+func remotePathCompletion(aliasIndex int) CompletionFunc {
+	return func(args []string, word string) []string {
+		if aliasIndex >= len(args) {
+			return nil
+		}
+
+		entry := peekActive(args[aliasIndex])
+		if entry == nil {
+			return nil
+		}
+
+		dir, _ := splitCompletionPath(word, "/")
+		listDir := dir
+		if listDir == "" {
+			listDir = "."
+		}
+
+		candidates := []string{}
+		switch {
+		case entry.ftp != nil:
+			entries, err := entry.ftp.List(listDir)
+			if err != nil {
+				return nil
+			}
+			for _, remote := range entries {
+				candidates = appendRemoteCandidate(candidates, dir, remote.Name, remote.IsDir)
+			}
+		case entry.sftp != nil:
+			entries, err := entry.sftp.List(listDir)
+			if err != nil {
+				return nil
+			}
+			for _, remote := range entries {
+				candidates = appendRemoteCandidate(candidates, dir, remote.Name, remote.IsDir)
+			}
+		}
+		return candidates
+	}
+}
+
+func appendRemoteCandidate(candidates []string, dir, name string, isDir bool) []string {
+	name = path.Base(name)
+	if name == "." || name == ".." {
+		return candidates
+	}
+	candidate := dir + name
+	if isDir {
+		candidate += "/"
+	}
+	return append(candidates, candidate)
+}
+
+// splitCompletionPath separates the directory part, including its trailing
+// separator, from the partial file name.
+func splitCompletionPath(word, separator string) (string, string) {
+	index := strings.LastIndex(word, separator)
+	if separator != "/" {
+		// Accept forward slashes on Windows as well.
+		if slash := strings.LastIndex(word, "/"); slash > index {
+			index = slash
+		}
+	}
+	if index == -1 {
+		return "", word
+	}
+	return word[:index+1], word[index+1:]
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	matches := []string{}
+	lowerPrefix := strings.ToLower(prefix)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), lowerPrefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func containsProtocol(protocols []config.Protocol, protocol config.Protocol) bool {
+	for _, candidate := range protocols {
+		if candidate == protocol {
+			return true
+		}
+	}
+	return false
+}
@@ -10,69 +10,116 @@ import (
 	"servercommander/src/utils"
 )
 
-// CommandHandler represents the business logic executed for a command.
-type CommandHandler func(args []string) error
-
-// CommandDescriptor keeps metadata for a registered command.
-type CommandDescriptor struct {
-	Name        string
-	Description string
-	Handler     CommandHandler
-}
+// CompletionFunc returns the completion candidates for the word being typed.
+// args holds the positional arguments of the resolved (sub)command preceding
+// that word; flags are not included.
+type CompletionFunc func(args []string, word string) []string
 
-var commandRegistry = map[string]CommandDescriptor{}
+var commandRegistry = map[string]*CommandSpec{}
 
 // RegisterCommand adds a new command to the registry. It will panic if the
-// command name collides with an existing entry because this indicates a
-// developer error that should be caught during tests.
-func RegisterCommand(name, description string, handler CommandHandler) {
-	if name == "" {
+// command name collides with an existing entry or the spec is inconsistent
+// because this indicates a developer error that should be caught during tests.
+func RegisterCommand(spec *CommandSpec) {
+	if spec.Name == "" {
 		panic("command name cannot be empty")
 	}
 
-	key := strings.ToLower(name)
+	key := strings.ToLower(spec.Name)
 	if _, exists := commandRegistry[key]; exists {
-		panic(fmt.Sprintf("command %s already registered", name))
+		panic(fmt.Sprintf("command %s already registered", spec.Name))
 	}
 
-	commandRegistry[key] = CommandDescriptor{
-		Name:        key,
-		Description: description,
-		Handler:     handler,
+	spec.Name = key
+	spec.link()
+	validateSpec(spec)
+	commandRegistry[key] = spec
+}
+
+func validateSpec(spec *CommandSpec) {
+	if len(spec.Subcommands) > 0 {
+		if spec.Handler != nil || len(spec.Args) > 0 || len(spec.Flags) > 0 {
+			panic(fmt.Sprintf("command %s mixes subcommands with arguments", spec.Path()))
+		}
+		for _, sub := range spec.Subcommands {
+			validateSpec(sub)
+		}
+		return
+	}
+
+	if spec.Handler == nil {
+		panic(fmt.Sprintf("command %s has no handler", spec.Path()))
+	}
+	for i, arg := range spec.Args {
+		if arg.Variadic && i != len(spec.Args)-1 {
+			panic(fmt.Sprintf("command %s declares variadic argument %s before others", spec.Path(), arg.Name))
+		}
 	}
 }
 
-// Execute resolves the command within the registry and runs the attached
-// handler. It returns an error if the command does not exist or if the
-// handler reports an error.
+// Execute splits the input into shell-style arguments, resolves the command
+// within the registry and runs the attached handler. It returns an error if
+// the command does not exist or if the handler reports an error.
 func Execute(input string) error {
-	parts := strings.Fields(input)
+	parts, offsets, err := utils.SplitCommandLineOffsets(input)
+	if err != nil {
+		return &utils.UsageError{Message: fmt.Sprintf("invalid input: %v", err)}
+	}
+	return execute(parts, func(index int) string {
+		return strings.TrimSpace(input[offsets[index]:])
+	})
+}
+
+// ExecuteArgs runs a command that has already been split into arguments, such
+// as the arguments passed to the program itself.
+func ExecuteArgs(parts []string) error {
+	return execute(parts, nil)
+}
+
+// execute runs a split command line. raw, when set, returns the input from
+// the argument at index on as it was typed.
+func execute(parts []string, raw func(index int) string) error {
 	if len(parts) == 0 {
 		return nil
 	}
 
 	key := strings.ToLower(parts[0])
-	descriptor, exists := commandRegistry[key]
+	spec, exists := commandRegistry[key]
 	if !exists {
-		return fmt.Errorf("unknown command '%s'. Type 'help' to list available commands", parts[0])
+		return &utils.UsageError{Message: fmt.Sprintf("unknown command '%s'. Type 'help' to list available commands", parts[0])}
 	}
 
-	if err := descriptor.Handler(parts[1:]); err != nil {
-		services.LogToFile(fmt.Sprintf("command '%s' failed: %v", descriptor.Name, err))
+	leaf, args, err := spec.resolve(parts[1:])
+	if err != nil {
+		return err
+	}
+	ctx, err := leaf.parse(args)
+	if err != nil {
+		return err
+	}
+	if raw != nil && ctx.verbatimFrom >= 0 {
+		ctx.raw = raw(len(parts) - len(args) + ctx.verbatimFrom)
+	}
+
+	if err := leaf.Handler(ctx); err != nil {
+		var exitErr *utils.ExitError
+		if !errors.As(err, &exitErr) {
+			services.LogToFile(fmt.Sprintf("command '%s' failed: %v", leaf.Path(), err))
+		}
 		return err
 	}
 
-	services.LogToFile(fmt.Sprintf("command '%s' executed successfully", descriptor.Name))
+	services.LogToFile(fmt.Sprintf("command '%s' executed successfully", leaf.Path()))
 	return nil
 }
 
 // ListCommands returns a deterministic, alphabetically sorted slice of
-// descriptors. This is primarily used by the help command but also enables
+// command specs. This is primarily used by the help command but also enables
 // other commands to query the available functionality.
-func ListCommands() []CommandDescriptor {
-	commands := make([]CommandDescriptor, 0, len(commandRegistry))
-	for _, descriptor := range commandRegistry {
-		commands = append(commands, descriptor)
+func ListCommands() []*CommandSpec {
+	commands := make([]*CommandSpec, 0, len(commandRegistry))
+	for _, spec := range commandRegistry {
+		commands = append(commands, spec)
 	}
 
 	sort.Slice(commands, func(i, j int) bool {
@@ -85,18 +132,33 @@ func ListCommands() []CommandDescriptor {
 // init registers the built-in commands to guarantee they are always
 // available even when the application is extended with plugins.
 func init() {
-	RegisterCommand("help", "Shows this help", helpCommand)
-	RegisterCommand("clear", "Clears the console", clearCommand)
-	RegisterCommand("exit", "Exits the program", exitCommand)
-}
-
-// ensureUsage enforces the expected number of positional arguments for a
-// command. It simplifies handler implementations by providing a consistent
-// user-facing error message. Some commands use advanced parsing and therefore
-// do not rely on this helper.
-func ensureUsage(args []string, min, max int, usage string) error {
-	if len(args) < min || (max >= 0 && len(args) > max) {
-		return errors.New(utils.FormatUsageError(usage))
-	}
-	return nil
+	RegisterCommand(&CommandSpec{
+		Name:        "help",
+		Description: "Shows this help",
+		Args: []Arg{{
+			Name:        "command",
+			Description: "Command, optionally followed by subcommands, to describe",
+			Optional:    true,
+			Variadic:    true,
+			Complete:    commandPathCompletion,
+		}},
+		Examples: []string{"help", "help session import"},
+		Handler:  helpCommand,
+	})
+	RegisterCommand(&CommandSpec{
+		Name:        "clear",
+		Description: "Clears the console",
+		Handler:     clearCommand,
+	})
+	RegisterCommand(&CommandSpec{
+		Name:        "exit",
+		Description: "Exits the program, or ends a script with the given status",
+		Args: []Arg{{
+			Name:        "status",
+			Description: "Exit status between 0 and 255; a script otherwise ends with the status of its first failing command",
+			Optional:    true,
+		}},
+		Examples: []string{"exit", "exit 3"},
+		Handler:  exitCommand,
+	})
 }
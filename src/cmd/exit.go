@@ -3,14 +3,36 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"servercommander/src/console"
 	"servercommander/src/utils"
 )
 
-func exitCommand(args []string) error {
+func exitCommand(ctx *Context) error {
+	status, explicit := 0, ctx.Arg("status") != ""
+	if explicit {
+		code, err := strconv.Atoi(ctx.Arg("status"))
+		if err != nil || code < 0 || code > 255 {
+			return &utils.UsageError{Message: "the exit status must be a number between 0 and 255"}
+		}
+		status = code
+	}
+
+	if console.Interactive() && transferQueue.Running() {
+		leave, err := utils.PromptBool("Transfers are still running in the background. Exit and abort them", false)
+		if err != nil || !leave {
+			return err
+		}
+	}
+	closeActiveSessions()
+	if !console.Interactive() {
+		// Scripts and single commands end through main, which turns the
+		// error into the exit status.
+		return &utils.ExitError{Code: status, Explicit: explicit}
+	}
 	console.GoodbyeBanner()
 	fmt.Println(utils.Red, "Exiting the program...", utils.Reset)
-	os.Exit(0)
+	os.Exit(status)
 	return nil
 }
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
 	"path"
 	"path/filepath"
@@ -10,58 +9,144 @@ import (
 
 	"servercommander/src/services/config"
 	ftpservice "servercommander/src/services/ftp"
+	"servercommander/src/services/transfer"
 	"servercommander/src/utils"
 )
 
 func init() {
-	RegisterCommand("ftp", "Perform FTP/FTPS file operations", ftpCommand)
+	RegisterCommand(&CommandSpec{
+		Name:        "ftp",
+		Description: "Perform FTP/FTPS file operations",
+		Subcommands: append([]*CommandSpec{
+			{
+				Name:        "list",
+				Description: "List a remote directory",
+				Args: []Arg{
+					{Name: "alias", Description: "FTP session", Complete: aliasCompletion(config.ProtocolFTP)},
+					{Name: "remote-path", Description: "Remote directory", Optional: true, Default: ".", Complete: remotePathCompletion(0)},
+				},
+				Flags:    []Flag{outputFlag},
+				Examples: []string{"ftp list prod /var/www --output csv"},
+				Handler: func(ctx *Context) error {
+					return withFTPClient(ctx.Arg("alias"), func(client *ftpservice.Client) error {
+						return renderFTPListing(client, ctx.Arg("remote-path"), ctx.OutputFormat())
+					})
+				},
+			},
+			{
+				Name:        "upload",
+				Description: "Upload a local file or, with --recursive, a directory tree",
+				Args: []Arg{
+					{Name: "alias", Description: "FTP session", Complete: aliasCompletion(config.ProtocolFTP)},
+					{Name: "local", Description: "Local file or directory", Complete: localPathCompletion},
+					{Name: "remote", Description: "Remote file, or directory when ending with /", Complete: remotePathCompletion(0)},
+				},
+				Flags: append([]Flag{limitFlag, verifyFlag}, treeFlags...),
+				Examples: []string{
+					`ftp upload prod "My Report.pdf" /docs/`,
+					`ftp upload -r prod ./public /var/www/ --exclude "*.map"`,
+				},
+				Handler: func(ctx *Context) error {
+					local := ctx.Arg("local")
+					remote := ctx.Arg("remote")
+					if strings.HasSuffix(remote, "/") {
+						remote = path.Join(remote, filepath.Base(filepath.Clean(local)))
+					}
+					applyLimit, err := limitOverride(ctx)
+					if err != nil {
+						return err
+					}
+					verify, err := verifyEnabled(ctx, config.ProtocolFTP)
+					if err != nil {
+						return err
+					}
+					if !ctx.Bool("recursive") {
+						return withFTPClient(ctx.Arg("alias"), func(client *ftpservice.Client) error {
+							applyLimit(client)
+							if err := transferFile(client, "Uploaded", fmt.Sprintf("%s to %s:%s", local, ctx.Arg("alias"), remote), func() error {
+								return client.Upload(local, remote)
+							}); err != nil || !verify {
+								return err
+							}
+							return verifyTransferred(client, local, remote)
+						})
+					}
+
+					return runTree(ctx, config.ProtocolFTP, transfer.Upload, local, remote)
+				},
+			},
+			{
+				Name:        "download",
+				Description: "Download a remote file or, with --recursive, a directory tree",
+				Args: []Arg{
+					{Name: "alias", Description: "FTP session", Complete: aliasCompletion(config.ProtocolFTP)},
+					{Name: "remote", Description: "Remote file or directory", Complete: remotePathCompletion(0)},
+					{Name: "local", Description: "Local file, or directory when ending with a separator", Complete: localPathCompletion},
+				},
+				Flags:    append([]Flag{limitFlag, verifyFlag}, treeFlags...),
+				Examples: []string{`ftp download -r prod /var/www/logs ./logs --include "*.log"`},
+				Handler: func(ctx *Context) error {
+					remote := ctx.Arg("remote")
+					local := ctx.Arg("local")
+					if strings.HasSuffix(local, string(filepath.Separator)) || strings.HasSuffix(local, "/") {
+						local = filepath.Join(local, path.Base(remote))
+					}
+					applyLimit, err := limitOverride(ctx)
+					if err != nil {
+						return err
+					}
+					verify, err := verifyEnabled(ctx, config.ProtocolFTP)
+					if err != nil {
+						return err
+					}
+					if !ctx.Bool("recursive") {
+						return withFTPClient(ctx.Arg("alias"), func(client *ftpservice.Client) error {
+							applyLimit(client)
+							if err := transferFile(client, "Downloaded", fmt.Sprintf("%s:%s to %s", ctx.Arg("alias"), remote, local), func() error {
+								return client.Download(remote, local)
+							}); err != nil || !verify {
+								return err
+							}
+							return verifyTransferred(client, local, remote)
+						})
+					}
+
+					return runTree(ctx, config.ProtocolFTP, transfer.Download, local, remote)
+				},
+			},
+			// Servers without MLSD only report minutes, so timestamps within a minute
+			// count as equal.
+			syncCommand("ftp", time.Minute, aliasCompletion(config.ProtocolFTP), func(alias string, fn func(transfer.Remote) error) error {
+				return withFTPClient(alias, func(client *ftpservice.Client) error {
+					return showProgress(client, func() error {
+						return fn(ftpRemote{client})
+					})
+				})
+			}),
+			verifyCommand("ftp", aliasCompletion(config.ProtocolFTP), func(alias string, fn func(verifiableRemote) error) error {
+				return withFTPClient(alias, func(client *ftpservice.Client) error {
+					return fn(ftpRemote{client})
+				})
+			}),
+		}, ftpFileCommands...),
+	})
 }
 
-func ftpCommand(args []string) error {
-	if len(args) == 0 {
-		return errors.New(utils.FormatUsageError("ftp <list|upload|download> <alias> [paths]"))
-	}
+// treeFlags are accepted by the transfer commands that can work recursively.
+var treeFlags = []Flag{
+	{Name: "recursive", Short: "r", Kind: BoolFlag, Description: "Transfer a whole directory tree"},
+	{Name: "include", Kind: ListFlag, Placeholder: "glob", Description: "Only transfer files matching the pattern"},
+	{Name: "exclude", Kind: ListFlag, Placeholder: "glob", Description: "Skip files and directories matching the pattern"},
+	{Name: "connections", Kind: IntFlag, Placeholder: "n", Description: "Number of parallel connections for a recursive transfer"},
+	{Name: "wait", Kind: BoolFlag, Description: "Wait for a recursive transfer instead of running it in the background"},
+}
 
-	action := strings.ToLower(args[0])
-	switch action {
-	case "list":
-		if err := ensureUsage(args[1:], 1, 2, "ftp list <alias> [remote-path]"); err != nil {
-			return err
-		}
-		remotePath := "."
-		if len(args) == 3 {
-			remotePath = args[2]
-		}
-		return withFTPClient(args[1], func(client *ftpservice.Client) error {
-			return renderFTPListing(client, remotePath)
-		})
-	case "upload":
-		if err := ensureUsage(args[1:], 3, 3, "ftp upload <alias> <local> <remote>"); err != nil {
-			return err
-		}
-		local := args[2]
-		remote := args[3]
-		return withFTPClient(args[1], func(client *ftpservice.Client) error {
-			if strings.HasSuffix(remote, "/") {
-				remote = path.Join(remote, filepath.Base(local))
-			}
-			return client.Upload(local, remote)
-		})
-	case "download":
-		if err := ensureUsage(args[1:], 3, 3, "ftp download <alias> <remote> <local>"); err != nil {
-			return err
-		}
-		remote := args[2]
-		local := args[3]
-		return withFTPClient(args[1], func(client *ftpservice.Client) error {
-			if strings.HasSuffix(local, string(filepath.Separator)) {
-				local = filepath.Join(local, filepath.Base(remote))
-			}
-			return client.Download(remote, local)
-		})
-	default:
-		return fmt.Errorf("unknown ftp action '%s'", action)
+func treeFilter(ctx *Context) (transfer.Filter, error) {
+	filter := transfer.Filter{Include: ctx.Strings("include"), Exclude: ctx.Strings("exclude")}
+	if err := filter.Validate(); err != nil {
+		return transfer.Filter{}, err
 	}
+	return filter, nil
 }
 
 func withFTPClient(alias string, fn func(*ftpservice.Client) error) error {
@@ -73,37 +158,55 @@ func withFTPClient(alias string, fn func(*ftpservice.Client) error) error {
 		return fmt.Errorf("session '%s' is not configured for FTP", session.Alias)
 	}
 
-	password, err := promptPassword(session)
-	if err != nil {
-		return err
+	// Reuse the connection left open by a previous command when possible. The
+	// connection is parked again afterwards so completion can list remote
+	// paths and follow-up commands do not prompt for the password again.
+	client := takeActiveFTP(session.Alias, session.UpdatedAt)
+	if client == nil {
+		password, err := promptPassword(session)
+		if err != nil {
+			return err
+		}
+
+		client, err = ftpservice.Connect(session, password)
+		if err != nil {
+			return err
+		}
 	}
+	defer keepActiveFTP(session.Alias, session.UpdatedAt, client)
 
-	client, err := ftpservice.Connect(session, password)
-	if err != nil {
+	if err := applyConfiguredLimit(client, session); err != nil {
 		return err
 	}
-	defer client.Close()
-
 	return fn(client)
 }
 
-func renderFTPListing(client *ftpservice.Client, path string) error {
+func renderFTPListing(client *ftpservice.Client, path string, format utils.OutputFormat) error {
 	entries, err := client.List(path)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("%s%-30s %-12s %-20s%s\n", utils.Cyan, "NAME", "SIZE", "MODIFIED", utils.Reset)
+	return utils.Render(format, entries, func() error {
+		return printFTPEntries(entries)
+	})
+}
+
+func printFTPEntries(entries []ftpservice.Entry) error {
+	fmt.Printf("%s%-30s %-12s %-11s %-20s%s\n", utils.Cyan, "NAME", "SIZE", "MODE", "MODIFIED", utils.Reset)
 	for _, entry := range entries {
 		name := entry.Name
 		if entry.IsDir {
 			name += "/"
 		}
+		if entry.Target != "" {
+			name += " -> " + entry.Target
+		}
 		modTime := ""
 		if !entry.ModTime.IsZero() {
 			modTime = entry.ModTime.UTC().Format(time.RFC3339)
 		}
-		fmt.Printf("%-30s %-12d %-20s\n", name, entry.Size, modTime)
+		fmt.Printf("%-30s %-12d %-11s %-20s\n", name, entry.Size, entry.Permissions, modTime)
 	}
 	return nil
 }
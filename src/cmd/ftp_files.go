@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/term"
+
+	"servercommander/src/services/config"
+	ftpservice "servercommander/src/services/ftp"
+	"servercommander/src/utils"
+)
+
+// yesFlag lets scripts run destructive commands without being asked.
+var yesFlag = Flag{Name: "yes", Short: "y", Kind: BoolFlag, Description: "Do not ask for confirmation"}
+
+// ftpFileCommands manage individual remote files and directories.
+var ftpFileCommands = []*CommandSpec{
+	{
+		Name:        "rm",
+		Description: "Delete remote files",
+		Args: []Arg{
+			{Name: "alias", Description: "FTP session", Complete: aliasCompletion(config.ProtocolFTP)},
+			{Name: "remote", Description: "Remote files to delete", Variadic: true, Complete: remotePathCompletion(0)},
+		},
+		Flags:    []Flag{yesFlag},
+		Examples: []string{"ftp rm prod /tmp/upload.zip", "ftp rm -y prod /logs/old.log /logs/older.log"},
+		Handler: func(ctx *Context) error {
+			files := ctx.Args("remote")
+			question := fmt.Sprintf("Delete %s", files[0])
+			if len(files) > 1 {
+				question = fmt.Sprintf("Delete %d remote files", len(files))
+			}
+			if ok, err := confirmAction(ctx, question); !ok || err != nil {
+				return err
+			}
+			return withFTPClient(ctx.Arg("alias"), func(client *ftpservice.Client) error {
+				for _, file := range files {
+					if err := client.Remove(file); err != nil {
+						return err
+					}
+					fmt.Printf("%sDeleted %s%s\n", utils.Green, file, utils.Reset)
+				}
+				return nil
+			})
+		},
+	},
+	{
+		Name:        "rmdir",
+		Description: "Delete a remote directory, including its contents with --recursive",
+		Args: []Arg{
+			{Name: "alias", Description: "FTP session", Complete: aliasCompletion(config.ProtocolFTP)},
+			{Name: "remote", Description: "Remote directory", Complete: remotePathCompletion(0)},
+		},
+		Flags: []Flag{
+			{Name: "recursive", Short: "r", Kind: BoolFlag, Description: "Delete the directory together with everything in it"},
+			yesFlag,
+		},
+		Examples: []string{"ftp rmdir prod /releases/41", "ftp rmdir -r -y prod /releases/40"},
+		Handler: func(ctx *Context) error {
+			dir := ctx.Arg("remote")
+			recursive := ctx.Bool("recursive")
+			question := fmt.Sprintf("Delete directory %s", dir)
+			if recursive {
+				question = fmt.Sprintf("Delete directory %s and everything in it", dir)
+			}
+			if ok, err := confirmAction(ctx, question); !ok || err != nil {
+				return err
+			}
+			return withFTPClient(ctx.Arg("alias"), func(client *ftpservice.Client) error {
+				var err error
+				if recursive {
+					err = client.RemoveAll(dir)
+				} else {
+					err = client.RemoveDir(dir)
+				}
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%sDeleted %s%s\n", utils.Green, dir, utils.Reset)
+				return nil
+			})
+		},
+	},
+	{
+		Name:        "mkdir",
+		Description: "Create a remote directory including missing parents",
+		Args: []Arg{
+			{Name: "alias", Description: "FTP session", Complete: aliasCompletion(config.ProtocolFTP)},
+			{Name: "remote", Description: "Remote directory", Complete: remotePathCompletion(0)},
+		},
+		Examples: []string{"ftp mkdir prod /releases/42/assets"},
+		Handler: func(ctx *Context) error {
+			return withFTPClient(ctx.Arg("alias"), func(client *ftpservice.Client) error {
+				return client.Mkdir(ctx.Arg("remote"))
+			})
+		},
+	},
+	{
+		Name:        "mv",
+		Description: "Move or rename a remote file or directory",
+		Args: []Arg{
+			{Name: "alias", Description: "FTP session", Complete: aliasCompletion(config.ProtocolFTP)},
+			{Name: "from", Description: "Current remote path", Complete: remotePathCompletion(0)},
+			{Name: "to", Description: "New remote path", Complete: remotePathCompletion(0)},
+		},
+		Flags:    []Flag{yesFlag},
+		Examples: []string{"ftp mv prod /www/index.html /www/index.old.html"},
+		Handler: func(ctx *Context) error {
+			from, to := ctx.Arg("from"), ctx.Arg("to")
+			return withFTPClient(ctx.Arg("alias"), func(client *ftpservice.Client) error {
+				// Most servers silently replace an existing target.
+				if _, err := client.Stat(to); err == nil {
+					ok, err := confirmAction(ctx, fmt.Sprintf("%s already exists. Overwrite it", to))
+					if !ok || err != nil {
+						return err
+					}
+				}
+				return client.Rename(from, to)
+			})
+		},
+	},
+	{
+		Name:        "chmod",
+		Description: "Change the permissions of a remote path using SITE CHMOD",
+		Args: []Arg{
+			{Name: "alias", Description: "FTP session", Complete: aliasCompletion(config.ProtocolFTP)},
+			{Name: "mode", Description: "Octal permissions such as 644"},
+			{Name: "remote", Description: "Remote file or directory", Complete: remotePathCompletion(0)},
+		},
+		Examples: []string{"ftp chmod prod 755 /cgi-bin/run.cgi"},
+		Handler: func(ctx *Context) error {
+			mode, err := strconv.ParseUint(ctx.Arg("mode"), 8, 32)
+			if err != nil || mode > 07777 {
+				return &utils.UsageError{Message: fmt.Sprintf("invalid mode '%s'; expected octal permissions such as 644", ctx.Arg("mode"))}
+			}
+			return withFTPClient(ctx.Arg("alias"), func(client *ftpservice.Client) error {
+				return client.Chmod(ctx.Arg("remote"), os.FileMode(mode))
+			})
+		},
+	},
+	{
+		Name:        "stat",
+		Description: "Show the size, modification time and permissions of a remote path",
+		Args: []Arg{
+			{Name: "alias", Description: "FTP session", Complete: aliasCompletion(config.ProtocolFTP)},
+			{Name: "remote", Description: "Remote file or directory", Complete: remotePathCompletion(0)},
+		},
+		Flags:    []Flag{outputFlag},
+		Examples: []string{"ftp stat prod /www/index.html --output json"},
+		Handler: func(ctx *Context) error {
+			return withFTPClient(ctx.Arg("alias"), func(client *ftpservice.Client) error {
+				entry, err := client.Stat(ctx.Arg("remote"))
+				if err != nil {
+					return err
+				}
+				return utils.Render(ctx.OutputFormat(), entry, func() error {
+					return printFTPEntry(entry)
+				})
+			})
+		},
+	},
+	{
+		Name:        "cat",
+		Description: "Print a remote file to standard output",
+		Args: []Arg{
+			{Name: "alias", Description: "FTP session", Complete: aliasCompletion(config.ProtocolFTP)},
+			{Name: "remote", Description: "Remote file", Complete: remotePathCompletion(0)},
+		},
+		Examples: []string{"ftp cat prod /logs/error.log | grep timeout"},
+		Handler: func(ctx *Context) error {
+			return withFTPClient(ctx.Arg("alias"), func(client *ftpservice.Client) error {
+				return client.Retrieve(ctx.Arg("remote"), os.Stdout)
+			})
+		},
+	},
+}
+
+// confirmAction asks before a destructive operation unless --yes was given.
+// Without a terminal there is nobody to answer, so the operation is refused
+// rather than consuming piped input as the answer.
+func confirmAction(ctx *Context, question string) (bool, error) {
+	if ctx.Bool("yes") {
+		return true, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, &utils.UsageError{Message: fmt.Sprintf("%s needs confirmation; pass --yes to run it non-interactively", ctx.Command.Path())}
+	}
+
+	ok, err := utils.PromptBool(question, false)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		fmt.Println(utils.Yellow, "Cancelled.", utils.Reset)
+	}
+	return ok, nil
+}
+
+func printFTPEntry(entry ftpservice.Entry) error {
+	kind := "file"
+	switch {
+	case entry.IsDir:
+		kind = "directory"
+	case entry.Target != "":
+		kind = "symlink"
+	}
+
+	fmt.Printf("%sPath:%s         %s\n", utils.Blue, utils.Reset, entry.Name)
+	fmt.Printf("%sType:%s         %s\n", utils.Blue, utils.Reset, kind)
+	if entry.Target != "" {
+		fmt.Printf("%sTarget:%s       %s\n", utils.Blue, utils.Reset, entry.Target)
+	}
+	fmt.Printf("%sSize:%s         %s (%d bytes)\n", utils.Blue, utils.Reset, utils.FormatBytes(entry.Size), entry.Size)
+	if !entry.ModTime.IsZero() {
+		fmt.Printf("%sModified:%s     %s\n", utils.Blue, utils.Reset, entry.ModTime.UTC().Format(time.RFC3339))
+	}
+	if entry.Permissions != "" {
+		fmt.Printf("%sPermissions:%s  %s\n", utils.Blue, utils.Reset, entry.Permissions)
+	}
+	if entry.Owner != "" || entry.Group != "" {
+		fmt.Printf("%sOwner:%s        %s:%s\n", utils.Blue, utils.Reset, entry.Owner, entry.Group)
+	}
+	return nil
+}
@@ -2,15 +2,125 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"servercommander/src/utils"
 )
 
-func helpCommand(args []string) error {
-	commands := ListCommands()
-	fmt.Println(utils.Green, "Available commands:")
-	for _, descriptor := range commands {
-		fmt.Printf("%s  %-8s%s - %s\n", utils.Blue, descriptor.Name, utils.Reset, descriptor.Description)
+func helpCommand(ctx *Context) error {
+	path := ctx.Args("command")
+	if len(path) == 0 {
+		commands := ListCommands()
+		fmt.Println(utils.Green, "Available commands:")
+		for _, spec := range commands {
+			fmt.Printf("%s  %-8s%s - %s\n", utils.Blue, spec.Name, utils.Reset, spec.Description)
+		}
+		fmt.Println(utils.Green, "Type 'help <command>' for details.", utils.Reset)
+		return nil
 	}
+
+	spec, exists := commandRegistry[strings.ToLower(path[0])]
+	if !exists {
+		return &utils.UsageError{Message: fmt.Sprintf("unknown command '%s'. Type 'help' to list available commands", path[0])}
+	}
+	for _, name := range path[1:] {
+		sub := spec.subcommand(name)
+		if sub == nil {
+			return &utils.UsageError{Message: fmt.Sprintf("'%s' has no subcommand '%s'", spec.Path(), name)}
+		}
+		spec = sub
+	}
+
+	printHelpPage(spec)
 	return nil
 }
+
+// printHelpPage renders the usage, arguments, options, subcommands and
+// examples declared by spec.
+func printHelpPage(spec *CommandSpec) {
+	fmt.Printf("%sUsage:%s %s\n", utils.Cyan, utils.Reset, spec.Usage())
+	if spec.Description != "" {
+		fmt.Printf("\n%s\n", spec.Description)
+	}
+
+	if len(spec.Subcommands) > 0 {
+		fmt.Printf("\n%sSubcommands:%s\n", utils.Cyan, utils.Reset)
+		for _, sub := range spec.Subcommands {
+			fmt.Printf("%s  %-12s%s  %s\n", utils.Blue, sub.Name, utils.Reset, sub.Description)
+		}
+	}
+
+	width := 12
+	for _, arg := range spec.Args {
+		width = max(width, len(arg.Name))
+	}
+	for _, flag := range spec.Flags {
+		width = max(width, len(flag.synopsis()))
+	}
+
+	if len(spec.Args) > 0 {
+		fmt.Printf("\n%sArguments:%s\n", utils.Cyan, utils.Reset)
+		for _, arg := range spec.Args {
+			description := arg.Description
+			if len(arg.Values) > 0 {
+				description += fmt.Sprintf(" (%s)", strings.Join(arg.Values, ", "))
+			}
+			if arg.Default != "" {
+				description += fmt.Sprintf(" [default: %s]", arg.Default)
+			}
+			fmt.Printf("%s  %-*s%s  %s\n", utils.Blue, width, arg.Name, utils.Reset, description)
+		}
+	}
+
+	if len(spec.Flags) > 0 {
+		fmt.Printf("\n%sOptions:%s\n", utils.Cyan, utils.Reset)
+		for _, flag := range spec.Flags {
+			description := flag.Description
+			if flag.Default != "" && flag.Kind != BoolFlag {
+				description += fmt.Sprintf(" [default: %s]", flag.Default)
+			}
+			if flag.Kind == ListFlag {
+				description += " (repeatable)"
+			}
+			fmt.Printf("%s  %-*s%s  %s\n", utils.Blue, width, flag.synopsis(), utils.Reset, description)
+		}
+	}
+
+	if len(spec.Examples) > 0 {
+		fmt.Printf("\n%sExamples:%s\n", utils.Cyan, utils.Reset)
+		for _, example := range spec.Examples {
+			fmt.Printf("  %s\n", example)
+		}
+	}
+
+	if len(spec.Subcommands) > 0 {
+		fmt.Printf("\nType 'help %s <subcommand>' for details.\n", spec.Path())
+	}
+}
+
+// commandPathCompletion completes command and subcommand names for help.
+func commandPathCompletion(args []string, _ string) []string {
+	if len(args) == 0 {
+		names := []string{}
+		for _, spec := range ListCommands() {
+			names = append(names, spec.Name)
+		}
+		return names
+	}
+
+	spec, exists := commandRegistry[strings.ToLower(args[0])]
+	if !exists {
+		return nil
+	}
+	for _, name := range args[1:] {
+		if spec = spec.subcommand(name); spec == nil {
+			return nil
+		}
+	}
+
+	names := []string{}
+	for _, sub := range spec.Subcommands {
+		names = append(names, sub.Name)
+	}
+	return names
+}
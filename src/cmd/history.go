@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"servercommander/src/console"
+	"servercommander/src/utils"
+)
+
+func init() {
+	RegisterCommand(&CommandSpec{
+		Name:        "history",
+		Description: "List or re-run previous commands",
+		Args: []Arg{{
+			Name:        "index|clear",
+			Description: "Entry to re-run, or clear to erase the history",
+			Optional:    true,
+			Complete: func([]string, string) []string {
+				return []string{"clear"}
+			},
+		}},
+		Examples: []string{"history", "history 12", "history clear"},
+		Handler:  historyCommand,
+	})
+}
+
+func historyCommand(ctx *Context) error {
+	selection := ctx.Arg("index|clear")
+
+	entries := console.HistoryEntries()
+	if selection == "" {
+		if len(entries) == 0 {
+			fmt.Println(utils.Yellow, "History is empty.", utils.Reset)
+			return nil
+		}
+		for i, entry := range entries {
+			fmt.Printf("%s%5d%s  %s\n", utils.Blue, i+1, utils.Reset, entry)
+		}
+		return nil
+	}
+
+	if strings.EqualFold(selection, "clear") {
+		if err := console.ClearHistory(); err != nil {
+			return err
+		}
+		fmt.Println(utils.Green, "History cleared.", utils.Reset)
+		return nil
+	}
+
+	index, err := strconv.Atoi(selection)
+	if err != nil || index < 1 || index > len(entries) {
+		return fmt.Errorf("history index must be between 1 and %d", len(entries))
+	}
+
+	entry := entries[index-1]
+	if isHistoryInvocation(entry) {
+		return fmt.Errorf("refusing to re-run history command '%s'", entry)
+	}
+
+	// Record the re-executed command in place of the "history <n>" line the
+	// console just stored.
+	if last := entries[len(entries)-1]; isHistoryInvocation(last) {
+		if err := console.ReplaceLastHistory(entry); err != nil {
+			fmt.Println(utils.Yellow, err.Error(), utils.Reset)
+		}
+	}
+
+	fmt.Printf("%s%s%s\n", utils.Cyan, entry, utils.Reset)
+	return Execute(entry)
+}
+
+func isHistoryInvocation(line string) bool {
+	fields, err := utils.SplitCommandLine(line)
+	if err != nil {
+		return false
+	}
+	return len(fields) > 0 && strings.EqualFold(fields[0], "history")
+}
@@ -13,14 +13,14 @@ import (
 )
 
 func init() {
-	RegisterCommand("htop", "Launch the system monitor with ServerCommander colors", htopCommand)
+	RegisterCommand(&CommandSpec{
+		Name:        "htop",
+		Description: "Launch the system monitor with ServerCommander colors",
+		Handler:     htopCommand,
+	})
 }
 
-func htopCommand(args []string) error {
-	if err := ensureUsage(args, 0, 0, "htop"); err != nil {
-		return err
-	}
-
+func htopCommand(*Context) error {
 	if runtime.GOOS == "windows" {
 		return runWindowsProcessMonitor()
 	}
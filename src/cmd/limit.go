@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"servercommander/src/services/config"
+	"servercommander/src/utils"
+)
+
+// limitFlag overrides the bandwidth limit configured for the session or in
+// config.yaml.
+var limitFlag = Flag{Name: "limit", Kind: StringFlag, Placeholder: "rate", Description: "Limit the bandwidth per second, e.g. 512K or 2M; 0 lifts the configured limit"}
+
+// rateLimited is implemented by the protocol clients that can throttle their
+// transfers.
+type rateLimited interface {
+	SetLimit(bytesPerSecond int64)
+}
+
+// applyConfiguredLimit sets the limit of the session, or the protocol wide
+// limit of config.yaml, on a client. Connections are reused between commands,
+// so this also removes an override left by --limit.
+func applyConfiguredLimit(client rateLimited, session config.Session) error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	client.SetLimit(settings.TransferLimit(session))
+	return nil
+}
+
+// limitOverride validates --limit before connecting. The returned function
+// applies it to the connected client and does nothing when the flag is unset.
+func limitOverride(ctx *Context) (func(rateLimited), error) {
+	limit, set, err := limitFlagValue(ctx)
+	if err != nil || !set {
+		return func(rateLimited) {}, err
+	}
+	return func(client rateLimited) {
+		client.SetLimit(limit)
+	}, nil
+}
+
+// limitFlagValue returns the limit given with --limit in bytes per second
+// and whether the flag was used at all.
+func limitFlagValue(ctx *Context) (int64, bool, error) {
+	value := ctx.String("limit")
+	if value == "" {
+		return 0, false, nil
+	}
+	limit, err := utils.ParseBytes(value)
+	if err != nil {
+		return 0, false, &utils.UsageError{Message: err.Error()}
+	}
+	return limit, true, nil
+}
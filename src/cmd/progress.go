@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"servercommander/src/services"
+	"servercommander/src/services/transfer"
+	"servercommander/src/utils"
+)
+
+// progressReporter is implemented by the protocol clients that can report
+// the progress of their transfers.
+type progressReporter interface {
+	SetProgress(transfer.ProgressFactory)
+}
+
+// showProgress renders a progress bar for every file client transfers while
+// fn runs. Connections are reused between commands, so the bar is detached
+// again afterwards.
+func showProgress(client progressReporter, fn func() error) error {
+	client.SetProgress(func(name string, total, offset int64) transfer.Progress {
+		return utils.NewProgressBar(name, total, offset)
+	})
+	defer client.SetProgress(nil)
+	return fn()
+}
+
+// transferFile runs a single file transfer with a progress bar and reports
+// the throughput on the console and in the log once it succeeded. description
+// names the source and destination, e.g. "a.txt to prod:/docs/a.txt".
+func transferFile(client progressReporter, verb, description string, fn func() error) error {
+	var bar *utils.ProgressBar
+	client.SetProgress(func(name string, total, offset int64) transfer.Progress {
+		bar = utils.NewProgressBar(name, total, offset)
+		return bar
+	})
+	defer client.SetProgress(nil)
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("%s %s", verb, description)
+	// A transfer that was already complete on disk copies no data.
+	if bar != nil {
+		bytes, elapsed := bar.Stats()
+		message += fmt.Sprintf(" (%s in %s, %s)", utils.FormatBytes(bytes), elapsed.Round(time.Millisecond), utils.FormatRate(bytes, elapsed))
+	}
+	fmt.Printf("%s%s%s\n", utils.Green, message, utils.Reset)
+	services.LogToFile(message)
+	return nil
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -12,36 +13,59 @@ import (
 )
 
 func init() {
-	RegisterCommand("session", "Manage saved server sessions", sessionCommand)
-}
-
-func sessionCommand(args []string) error {
-	if len(args) == 0 {
-		return errors.New(utils.FormatUsageError("session <add|list|remove|show> [alias]"))
-	}
-
-	action := strings.ToLower(args[0])
-	switch action {
-	case "add":
-		if err := ensureUsage(args[1:], 1, 1, "session add <alias>"); err != nil {
-			return err
-		}
-		return sessionAdd(args[1])
-	case "list":
-		return sessionList()
-	case "remove":
-		if err := ensureUsage(args[1:], 1, 1, "session remove <alias>"); err != nil {
-			return err
-		}
-		return sessionRemove(args[1])
-	case "show":
-		if err := ensureUsage(args[1:], 1, 1, "session show <alias>"); err != nil {
-			return err
-		}
-		return sessionShow(args[1])
-	default:
-		return fmt.Errorf("unknown session action '%s'", action)
-	}
+	RegisterCommand(&CommandSpec{
+		Name:        "session",
+		Description: "Manage saved server sessions",
+		Subcommands: []*CommandSpec{
+			{
+				Name:        "add",
+				Description: "Create a session or edit an existing one",
+				Args:        []Arg{{Name: "alias", Description: "Name of the session", Complete: aliasCompletion()}},
+				Handler: func(ctx *Context) error {
+					return sessionAdd(ctx.Arg("alias"))
+				},
+			},
+			{
+				Name:        "list",
+				Description: "List stored sessions",
+				Flags:       []Flag{outputFlag},
+				Examples:    []string{"session list --output json"},
+				Handler: func(ctx *Context) error {
+					return sessionList(ctx.OutputFormat())
+				},
+			},
+			{
+				Name:        "remove",
+				Description: "Delete a session",
+				Args:        []Arg{{Name: "alias", Description: "Session to delete", Complete: aliasCompletion()}},
+				Handler: func(ctx *Context) error {
+					return sessionRemove(ctx.Arg("alias"))
+				},
+			},
+			{
+				Name:        "show",
+				Description: "Show the details of a session",
+				Args:        []Arg{{Name: "alias", Description: "Session to show", Complete: aliasCompletion()}},
+				Flags:       []Flag{outputFlag},
+				Handler: func(ctx *Context) error {
+					return sessionShow(ctx.Arg("alias"), ctx.OutputFormat())
+				},
+			},
+			sessionImportSpec,
+			{
+				Name:        "export",
+				Description: "Write sessions to a bundle file that can be shared",
+				Args: []Arg{
+					{Name: "file", Description: "Bundle file to write", Complete: localPathCompletion},
+					{Name: "aliases", Description: "Sessions to export; all sessions when omitted", Optional: true, Variadic: true, Complete: aliasCompletion()},
+				},
+				Examples: []string{"session export team.json", "session export web.json web1 web2"},
+				Handler: func(ctx *Context) error {
+					return sessionExport(ctx.Arg("file"), ctx.Args("aliases"))
+				},
+			},
+		},
+	})
 }
 
 func sessionAdd(alias string) error {
@@ -65,13 +89,19 @@ func sessionAdd(alias string) error {
 	return nil
 }
 
-func sessionList() error {
+func sessionList(format utils.OutputFormat) error {
 	store, err := config.LoadSessions()
 	if err != nil {
 		return err
 	}
 
 	sessions := store.List()
+	return utils.Render(format, sessions, func() error {
+		return printSessionTable(sessions)
+	})
+}
+
+func printSessionTable(sessions []config.Session) error {
 	if len(sessions) == 0 {
 		fmt.Println(utils.Yellow, "No sessions stored.", utils.Reset)
 		return nil
@@ -109,12 +139,18 @@ func sessionRemove(alias string) error {
 	return nil
 }
 
-func sessionShow(alias string) error {
+func sessionShow(alias string, format utils.OutputFormat) error {
 	session, err := loadSession(alias)
 	if err != nil {
 		return err
 	}
 
+	return utils.Render(format, session, func() error {
+		return printSessionDetails(session)
+	})
+}
+
+func printSessionDetails(session config.Session) error {
 	fmt.Printf("%sAlias:%s        %s\n", utils.Blue, utils.Reset, session.Alias)
 	fmt.Printf("%sProtocol:%s     %s\n", utils.Blue, utils.Reset, session.Protocol)
 	fmt.Printf("%sHost:%s         %s:%d\n", utils.Blue, utils.Reset, session.Host, session.Port)
@@ -123,12 +159,33 @@ func sessionShow(alias string) error {
 	if session.KeyPath != "" {
 		fmt.Printf("%sKey Path:%s     %s\n", utils.Blue, utils.Reset, session.KeyPath)
 	}
+	if session.ProxyJump != "" {
+		fmt.Printf("%sProxy Jump:%s   %s\n", utils.Blue, utils.Reset, session.ProxyJump)
+	}
 	if session.Protocol == config.ProtocolFTP {
-		fmt.Printf("%sTLS Enabled:%s %t\n", utils.Blue, utils.Reset, session.UseTLS)
+		tlsMode := session.TLSMode
+		if tlsMode == "" {
+			tlsMode = config.TLSModeNone
+		}
+		fmt.Printf("%sTLS Mode:%s     %s\n", utils.Blue, utils.Reset, tlsMode)
+		if session.CACertPath != "" {
+			fmt.Printf("%sCA Bundle:%s    %s\n", utils.Blue, utils.Reset, session.CACertPath)
+		}
+		dataMode := session.DataMode
+		if dataMode == "" {
+			dataMode = config.DataModePassive
+		}
+		fmt.Printf("%sData Mode:%s    %s\n", utils.Blue, utils.Reset, dataMode)
+	}
+	if session.MaxTransferSpeed > 0 {
+		fmt.Printf("%sSpeed Limit:%s  %d KB/s\n", utils.Blue, utils.Reset, session.MaxTransferSpeed)
 	}
 	if session.Description != "" {
 		fmt.Printf("%sDescription:%s %s\n", utils.Blue, utils.Reset, session.Description)
 	}
+	if len(session.Tags) > 0 {
+		fmt.Printf("%sTags:%s         %s\n", utils.Blue, utils.Reset, strings.Join(session.Tags, ", "))
+	}
 	fmt.Printf("%sRequires Pass:%s %t\n", utils.Blue, utils.Reset, session.RequiresPass)
 	fmt.Printf("%sCreated:%s      %s\n", utils.Blue, utils.Reset, session.CreatedAt.Format(time.RFC3339))
 	fmt.Printf("%sUpdated:%s      %s\n", utils.Blue, utils.Reset, session.UpdatedAt.Format(time.RFC3339))
@@ -224,33 +281,124 @@ func promptSessionDetails(alias string, existing config.Session, exists bool) (c
 		keyPath = ""
 	}
 
+	proxyJump := ""
+	if protocol == config.ProtocolSSH || protocol == config.ProtocolSFTP {
+		jumpDefault := "none"
+		if existing.ProxyJump != "" {
+			jumpDefault = existing.ProxyJump
+		}
+		jumpInput, err := utils.Prompt("Jump hosts ([user@]host[:port] or session aliases, comma separated; none to connect directly)", jumpDefault)
+		if err != nil {
+			return config.Session{}, err
+		}
+		if !strings.EqualFold(jumpInput, "none") {
+			proxyJump = jumpInput
+		}
+	}
+
 	description, err := utils.Prompt("Description", existing.Description)
 	if err != nil {
 		return config.Session{}, err
 	}
 
-	useTLS := existing.UseTLS
+	tagsDefault := "none"
+	if len(existing.Tags) > 0 {
+		tagsDefault = strings.Join(existing.Tags, ",")
+	}
+	tagsInput, err := utils.Prompt("Tags (comma separated; none to remove all)", tagsDefault)
+	if err != nil {
+		return config.Session{}, err
+	}
+	tags := parseTags(tagsInput)
+
+	tlsMode := config.TLSMode("")
+	caCertPath := ""
 	if protocol == config.ProtocolFTP {
-		useTLS, err = utils.PromptBool("Use explicit TLS", existing.UseTLS)
+		tlsDefault := string(config.TLSModeNone)
+		if existing.TLSMode != "" {
+			tlsDefault = string(existing.TLSMode)
+		}
+		tlsInput, err := utils.Prompt("TLS mode (none/explicit/implicit)", tlsDefault)
 		if err != nil {
 			return config.Session{}, err
 		}
+		tlsMode = config.TLSMode(strings.ToLower(tlsInput))
+		switch tlsMode {
+		case config.TLSModeNone:
+			tlsMode = ""
+		case config.TLSModeExplicit, config.TLSModeImplicit:
+			caCertPath, err = utils.Prompt("CA bundle (leave empty to use the system certificates)", existing.CACertPath)
+			if err != nil {
+				return config.Session{}, err
+			}
+		default:
+			return config.Session{}, fmt.Errorf("unsupported TLS mode '%s'", tlsInput)
+		}
+	}
+
+	dataMode := config.DataMode("")
+	if protocol == config.ProtocolFTP {
+		modeDefault := string(config.DataModePassive)
+		if existing.DataMode != "" {
+			modeDefault = string(existing.DataMode)
+		}
+		modeInput, err := utils.Prompt("Data connection mode (passive/active)", modeDefault)
+		if err != nil {
+			return config.Session{}, err
+		}
+		dataMode = config.DataMode(strings.ToLower(modeInput))
+		if dataMode != config.DataModePassive && dataMode != config.DataModeActive {
+			return config.Session{}, fmt.Errorf("unsupported data connection mode '%s'", modeInput)
+		}
+	}
+
+	maxSpeed := int64(0)
+	if protocol == config.ProtocolFTP || protocol == config.ProtocolSFTP {
+		speedInput, err := utils.Prompt("Max transfer speed in KB/s (0 uses the setting from config.yaml)", strconv.FormatInt(existing.MaxTransferSpeed, 10))
+		if err != nil {
+			return config.Session{}, err
+		}
+		maxSpeed, err = strconv.ParseInt(speedInput, 10, 64)
+		if err != nil || maxSpeed < 0 {
+			return config.Session{}, fmt.Errorf("invalid transfer speed '%s'", speedInput)
+		}
 	}
 
 	return config.Session{
-		Alias:        alias,
-		Protocol:     protocol,
-		Host:         host,
-		Port:         port,
-		Username:     username,
-		AuthMethod:   authMethod,
-		KeyPath:      keyPath,
-		UseTLS:       useTLS,
-		Description:  description,
-		RequiresPass: requiresPass,
+		Alias:            alias,
+		Protocol:         protocol,
+		Host:             host,
+		Port:             port,
+		Username:         username,
+		AuthMethod:       authMethod,
+		KeyPath:          keyPath,
+		ProxyJump:        proxyJump,
+		TLSMode:          tlsMode,
+		CACertPath:       caCertPath,
+		DataMode:         dataMode,
+		MaxTransferSpeed: maxSpeed,
+		Description:      description,
+		Tags:             tags,
+		RequiresPass:     requiresPass,
 	}, nil
 }
 
+// parseTags splits a comma separated list of tags, dropping duplicates and
+// the leading @ used to address tags in ssh exec. "none" yields no tags.
+func parseTags(input string) []string {
+	var tags []string
+	for _, tag := range strings.Split(input, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "@")
+		if tag == "" || strings.EqualFold(tag, "none") || slices.ContainsFunc(tags, func(other string) bool {
+			return strings.EqualFold(other, tag)
+		}) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
 func defaultPort(protocol config.Protocol) int {
 	switch protocol {
 	case config.ProtocolSSH, config.ProtocolSFTP:
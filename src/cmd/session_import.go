@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"servercommander/src/services/config"
+	"servercommander/src/utils"
+)
+
+var sessionImportSpec = &CommandSpec{
+	Name:        "import",
+	Description: "Import sessions from other tools or a bundle",
+	Subcommands: []*CommandSpec{
+		{
+			Name:        "ssh-config",
+			Description: "Import hosts from an OpenSSH client configuration",
+			Args: []Arg{{
+				Name:        "path",
+				Description: "Configuration file; defaults to ~/.ssh/config",
+				Optional:    true,
+				Complete:    localPathCompletion,
+			}},
+			Handler: func(ctx *Context) error {
+				path := ctx.Arg("path")
+				if path == "" {
+					defaultPath, err := config.DefaultSSHConfigPath()
+					if err != nil {
+						return err
+					}
+					path = defaultPath
+				}
+				imported, skipped, err := config.ParseSSHConfig(path)
+				if err != nil {
+					return err
+				}
+				return importSessions(imported, skipped)
+			},
+		},
+		{
+			Name:        "filezilla",
+			Description: "Import sites from a FileZilla site manager export",
+			Args:        []Arg{{Name: "file", Description: "sitemanager.xml file", Complete: localPathCompletion}},
+			Handler: func(ctx *Context) error {
+				imported, skipped, err := config.ParseFileZilla(ctx.Arg("file"))
+				if err != nil {
+					return err
+				}
+				return importSessions(imported, skipped)
+			},
+		},
+		{
+			Name:        "winscp",
+			Description: "Import sites from a WinSCP configuration file",
+			Args:        []Arg{{Name: "file", Description: "WinSCP.ini file", Complete: localPathCompletion}},
+			Handler: func(ctx *Context) error {
+				imported, skipped, err := config.ParseWinSCP(ctx.Arg("file"))
+				if err != nil {
+					return err
+				}
+				return importSessions(imported, skipped)
+			},
+		},
+		{
+			Name:        "bundle",
+			Description: "Import sessions from a bundle written by session export",
+			Args:        []Arg{{Name: "file", Description: "Bundle file to read", Complete: localPathCompletion}},
+			Flags: []Flag{{
+				Name:        "policy",
+				Kind:        StringFlag,
+				Values:      []string{string(config.ConflictMerge), string(config.ConflictOverwrite), string(config.ConflictSkip)},
+				Description: "How to handle aliases that already exist; asked interactively when omitted",
+			}},
+			Examples: []string{"session import bundle team.json --policy merge"},
+			Handler: func(ctx *Context) error {
+				var policy config.ConflictPolicy
+				if ctx.IsSet("policy") {
+					parsed, err := config.ParseConflictPolicy(ctx.String("policy"))
+					if err != nil {
+						return err
+					}
+					policy = parsed
+				}
+				return importBundle(ctx.Arg("file"), policy)
+			},
+		},
+	},
+}
+
+// importSessions previews the imported sessions, highlights aliases that
+// already exist in the store and upserts the selection after confirmation.
+// Entries the importer had to skip are reported up front.
+func importSessions(imported []config.ImportedSession, skipped []string) error {
+	for _, entry := range skipped {
+		fmt.Printf("%sSkipped: %s%s\n", utils.Yellow, entry, utils.Reset)
+	}
+
+	if len(imported) == 0 {
+		fmt.Println(utils.Yellow, "No sessions found to import.", utils.Reset)
+		return nil
+	}
+
+	store, err := config.LoadSessions()
+	if err != nil {
+		return err
+	}
+
+	conflicts := 0
+	fmt.Printf("%s%-20s %-8s %-30s %-12s %-10s%s\n", utils.Cyan, "Alias", "Protocol", "Host", "User", "Status", utils.Reset)
+	for _, entry := range imported {
+		session := entry.Session
+		status := utils.Green + "new" + utils.Reset
+		if _, exists := store.Get(session.Alias); exists {
+			status = utils.Yellow + "conflict" + utils.Reset
+			conflicts++
+		}
+		fmt.Printf("%-20s %-8s %-30s %-12s %s\n",
+			session.Alias,
+			session.Protocol,
+			fmt.Sprintf("%s:%d", session.Host, session.Port),
+			session.Username,
+			status,
+		)
+	}
+
+	for _, entry := range imported {
+		if len(entry.Unmapped) == 0 {
+			continue
+		}
+		fmt.Printf("%s%s: not imported: %s%s\n", utils.Yellow, entry.Session.Alias, strings.Join(entry.Unmapped, ", "), utils.Reset)
+	}
+
+	overwrite := false
+	if conflicts > 0 {
+		overwrite, err = utils.PromptBool(fmt.Sprintf("%d alias(es) already exist. Overwrite them", conflicts), false)
+		if err != nil {
+			return err
+		}
+	}
+
+	selected := []config.Session{}
+	for _, entry := range imported {
+		if _, exists := store.Get(entry.Session.Alias); exists && !overwrite {
+			continue
+		}
+		selected = append(selected, entry.Session)
+	}
+
+	if len(selected) == 0 {
+		fmt.Println(utils.Yellow, "Nothing to import.", utils.Reset)
+		return nil
+	}
+
+	confirm, err := utils.PromptBool(fmt.Sprintf("Import %d session(s)", len(selected)), true)
+	if err != nil {
+		return err
+	}
+	if !confirm {
+		fmt.Println(utils.Yellow, "Import cancelled.", utils.Reset)
+		return nil
+	}
+
+	for _, session := range selected {
+		store.Upsert(session)
+	}
+	if err := store.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s%d session(s) imported.%s\n", utils.Green, len(selected), utils.Reset)
+	return nil
+}
+
+// sessionExport writes the selected sessions, or all sessions when no alias is
+// given, into a bundle file.
+func sessionExport(path string, aliases []string) error {
+	store, err := config.LoadSessions()
+	if err != nil {
+		return err
+	}
+
+	sessions := []config.Session{}
+	if len(aliases) == 0 {
+		sessions = store.List()
+	}
+	for _, alias := range aliases {
+		session, ok := store.Get(alias)
+		if !ok {
+			return fmt.Errorf("session '%s' not found", alias)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if len(sessions) == 0 {
+		fmt.Println(utils.Yellow, "No sessions stored.", utils.Reset)
+		return nil
+	}
+
+	if err := config.WriteBundle(path, sessions); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s%d session(s) exported to %s.%s\n", utils.Green, len(sessions), path, utils.Reset)
+	return nil
+}
+
+// importBundle merges a session bundle into the store. When no policy is
+// given and aliases collide, the user is asked how to resolve the conflicts.
+func importBundle(path string, policy config.ConflictPolicy) error {
+	bundle, err := config.ReadBundle(path)
+	if err != nil {
+		return err
+	}
+
+	if len(bundle.Sessions) == 0 {
+		fmt.Println(utils.Yellow, "The bundle does not contain any sessions.", utils.Reset)
+		return nil
+	}
+
+	store, err := config.LoadSessions()
+	if err != nil {
+		return err
+	}
+
+	conflicts := []string{}
+	for _, session := range bundle.Sessions {
+		if _, exists := store.Get(session.Alias); exists {
+			conflicts = append(conflicts, strings.ToLower(session.Alias))
+		}
+	}
+
+	if len(conflicts) > 0 && policy == "" {
+		fmt.Printf("%sExisting aliases: %s%s\n", utils.Yellow, strings.Join(conflicts, ", "), utils.Reset)
+		answer, err := utils.Prompt("Conflict policy (merge/overwrite/skip)", string(config.ConflictSkip))
+		if err != nil {
+			return err
+		}
+		if policy, err = config.ParseConflictPolicy(answer); err != nil {
+			return err
+		}
+	}
+
+	for _, session := range bundle.Sessions {
+		action := store.Apply(session, policy)
+		fmt.Printf("  %-20s %s\n", strings.ToLower(session.Alias), action)
+	}
+
+	if err := store.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%sBundle %s imported.%s\n", utils.Green, path, utils.Reset)
+	return nil
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "prod", want: []string{"prod"}},
+		{input: " prod , eu ,web ", want: []string{"prod", "eu", "web"}},
+		{input: "@prod,@eu", want: []string{"prod", "eu"}},
+		{input: "prod,Prod,@PROD,eu", want: []string{"prod", "eu"}},
+		{input: "prod,,", want: []string{"prod"}},
+		{input: "none", want: nil},
+		{input: "NONE", want: nil},
+		{input: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := parseTags(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTags(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -1,69 +1,137 @@
 package cmd
 
 import (
-	"bytes"
-	"errors"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"servercommander/src/services/config"
+	sftpservice "servercommander/src/services/sftp"
+	"servercommander/src/services/transfer"
 	"servercommander/src/utils"
 )
 
 func init() {
-	RegisterCommand("sftp", "Perform SFTP file operations", sftpCommand)
+	RegisterCommand(&CommandSpec{
+		Name:        "sftp",
+		Description: "Perform SFTP file operations",
+		Subcommands: []*CommandSpec{
+			{
+				Name:        "list",
+				Description: "List a remote directory",
+				Args: []Arg{
+					{Name: "alias", Description: "SFTP session", Complete: aliasCompletion(config.ProtocolSFTP)},
+					{Name: "remote-path", Description: "Remote directory", Optional: true, Default: ".", Complete: remotePathCompletion(0)},
+				},
+				Flags:    []Flag{outputFlag},
+				Examples: []string{"sftp list prod /var/www --output csv"},
+				Handler: func(ctx *Context) error {
+					return withSFTPClient(ctx.Arg("alias"), func(client *sftpservice.Client) error {
+						return renderSFTPListing(client, ctx.Arg("remote-path"), ctx.OutputFormat())
+					})
+				},
+			},
+			{
+				Name:        "upload",
+				Description: "Upload a local file or, with --recursive, a directory tree",
+				Args: []Arg{
+					{Name: "alias", Description: "SFTP session", Complete: aliasCompletion(config.ProtocolSFTP)},
+					{Name: "local", Description: "Local file or directory", Complete: localPathCompletion},
+					{Name: "remote", Description: "Remote file, or directory when ending with /", Complete: remotePathCompletion(0)},
+				},
+				Flags:    append([]Flag{limitFlag, verifyFlag}, treeFlags...),
+				Examples: []string{`sftp upload -r prod ./public /var/www/ --connections 8`},
+				Handler: func(ctx *Context) error {
+					local := ctx.Arg("local")
+					remote := ctx.Arg("remote")
+					applyLimit, err := limitOverride(ctx)
+					if err != nil {
+						return err
+					}
+					verify, err := verifyEnabled(ctx, config.ProtocolSFTP)
+					if err != nil {
+						return err
+					}
+					if ctx.Bool("recursive") {
+						if strings.HasSuffix(remote, "/") {
+							remote = path.Join(remote, filepath.Base(filepath.Clean(local)))
+						}
+						return runTree(ctx, config.ProtocolSFTP, transfer.Upload, local, remote)
+					}
+					return withSFTPClient(ctx.Arg("alias"), func(client *sftpservice.Client) error {
+						applyLimit(client)
+						if strings.HasSuffix(remote, "/") {
+							remote = path.Join(remote, filepath.Base(local))
+						}
+						if err := transferFile(client, "Uploaded", fmt.Sprintf("%s to %s:%s", local, ctx.Arg("alias"), remote), func() error {
+							return client.Upload(local, remote)
+						}); err != nil || !verify {
+							return err
+						}
+						return verifyTransferred(client, local, remote)
+					})
+				},
+			},
+			{
+				Name:        "download",
+				Description: "Download a remote file or, with --recursive, a directory tree",
+				Args: []Arg{
+					{Name: "alias", Description: "SFTP session", Complete: aliasCompletion(config.ProtocolSFTP)},
+					{Name: "remote", Description: "Remote file or directory", Complete: remotePathCompletion(0)},
+					{Name: "local", Description: "Local file, or directory when ending with a separator", Complete: localPathCompletion},
+				},
+				Flags:    append([]Flag{limitFlag, verifyFlag}, treeFlags...),
+				Examples: []string{`sftp download -r prod /var/log/nginx ./logs --include "*.gz"`},
+				Handler: func(ctx *Context) error {
+					remote := ctx.Arg("remote")
+					local := ctx.Arg("local")
+					applyLimit, err := limitOverride(ctx)
+					if err != nil {
+						return err
+					}
+					verify, err := verifyEnabled(ctx, config.ProtocolSFTP)
+					if err != nil {
+						return err
+					}
+					if ctx.Bool("recursive") {
+						if strings.HasSuffix(local, string(filepath.Separator)) || strings.HasSuffix(local, "/") {
+							local = filepath.Join(local, path.Base(remote))
+						}
+						return runTree(ctx, config.ProtocolSFTP, transfer.Download, local, remote)
+					}
+					return withSFTPClient(ctx.Arg("alias"), func(client *sftpservice.Client) error {
+						applyLimit(client)
+						if strings.HasSuffix(local, string(filepath.Separator)) || strings.HasSuffix(local, "/") {
+							local = filepath.Join(local, path.Base(remote))
+						}
+						if err := transferFile(client, "Downloaded", fmt.Sprintf("%s:%s to %s", ctx.Arg("alias"), remote, local), func() error {
+							return client.Download(remote, local)
+						}); err != nil || !verify {
+							return err
+						}
+						return verifyTransferred(client, local, remote)
+					})
+				},
+			},
+			syncCommand("sftp", time.Second, aliasCompletion(config.ProtocolSFTP), func(alias string, fn func(transfer.Remote) error) error {
+				return withSFTPClient(alias, func(client *sftpservice.Client) error {
+					return showProgress(client, func() error {
+						return fn(sftpRemote{client})
+					})
+				})
+			}),
+			verifyCommand("sftp", aliasCompletion(config.ProtocolSFTP), func(alias string, fn func(verifiableRemote) error) error {
+				return withSFTPClient(alias, func(client *sftpservice.Client) error {
+					return fn(sftpRemote{client})
+				})
+			}),
+		},
+	})
 }
 
-func sftpCommand(args []string) error {
-	if len(args) == 0 {
-		return errors.New(utils.FormatUsageError("sftp <list|upload|download> <alias> [paths]"))
-	}
-
-	action := strings.ToLower(args[0])
-	switch action {
-	case "list":
-		if err := ensureUsage(args[1:], 1, 2, "sftp list <alias> [remote-path]"); err != nil {
-			return err
-		}
-		remotePath := "."
-		if len(args) == 3 {
-			remotePath = args[2]
-		}
-		return runSFTPBatch(args[1], []string{fmt.Sprintf("ls %s", remotePath)}, renderSFTPListing)
-	case "upload":
-		if err := ensureUsage(args[1:], 3, 3, "sftp upload <alias> <local> <remote>"); err != nil {
-			return err
-		}
-		alias := args[1]
-		local := args[2]
-		remote := args[3]
-		if strings.HasSuffix(remote, "/") {
-			remote = path.Join(remote, filepath.Base(local))
-		}
-		return runSFTPBatch(alias, []string{fmt.Sprintf("put %s %s", local, remote)}, nil)
-	case "download":
-		if err := ensureUsage(args[1:], 3, 3, "sftp download <alias> <remote> <local>"); err != nil {
-			return err
-		}
-		alias := args[1]
-		remote := args[2]
-		local := args[3]
-		if strings.HasSuffix(local, string(filepath.Separator)) {
-			local = filepath.Join(local, filepath.Base(remote))
-		}
-		return runSFTPBatch(alias, []string{fmt.Sprintf("get %s %s", remote, local)}, nil)
-	default:
-		return fmt.Errorf("unknown sftp action '%s'", action)
-	}
-}
-
-func runSFTPBatch(alias string, commands []string, postProcess func(string) error) error {
+func withSFTPClient(alias string, fn func(*sftpservice.Client) error) error {
 	session, err := loadSession(alias)
 	if err != nil {
 		return err
@@ -72,122 +140,61 @@ func runSFTPBatch(alias string, commands []string, postProcess func(string) erro
 		return fmt.Errorf("session '%s' is not configured for SFTP", session.Alias)
 	}
 
-	var password string
-	useSSHPass := false
-	if session.RequiresPass {
-		if _, err := exec.LookPath("sshpass"); err == nil {
-			password, err = promptPassword(session)
-			if err != nil {
-				return err
-			}
-			useSSHPass = true
-		} else {
-			fmt.Println(utils.Yellow, "sshpass not found; sftp will prompt for the password directly.", utils.Reset)
+	// See withFTPClient for how connections are reused between commands.
+	client := takeActiveSFTP(session.Alias, session.UpdatedAt)
+	if client == nil {
+		password, err := promptPassword(session)
+		if err != nil {
+			return err
 		}
-	}
 
-	batch := strings.Join(commands, "\n") + "\n"
-
-	batchSource := "-"
-	cleanup := func() {}
-	if session.RequiresPass && !useSSHPass {
-		file, err := os.CreateTemp("", "sftp-batch-*.txt")
+		conn, _, err := dialSSHWithRetry(session, password)
 		if err != nil {
-			return fmt.Errorf("failed to create temporary batch file: %w", err)
-		}
-		if _, err := file.WriteString(batch); err != nil {
-			file.Close()
-			os.Remove(file.Name())
-			return fmt.Errorf("failed to write temporary batch file: %w", err)
-		}
-		if err := file.Close(); err != nil {
-			os.Remove(file.Name())
-			return fmt.Errorf("failed to close temporary batch file: %w", err)
-		}
-		batchSource = file.Name()
-		cleanup = func() {
-			os.Remove(file.Name())
+			return err
 		}
-	}
-	defer cleanup()
-
-	args := buildSFTPArgs(session, batchSource)
-
-	var cmd *exec.Cmd
-	if useSSHPass {
-		cmd = exec.Command("sshpass", append([]string{"-p", password, "sftp"}, args...)...)
-	} else {
-		cmd = exec.Command("sftp", args...)
-	}
-
-	var stdout, stderr bytes.Buffer
-	stdoutWriter := io.Writer(&stdout)
-	stderrWriter := io.Writer(&stderr)
 
-	if batchSource == "-" {
-		cmd.Stdin = strings.NewReader(batch)
-	} else {
-		cmd.Stdin = os.Stdin
-		stdoutWriter = io.MultiWriter(os.Stdout, stdoutWriter)
-		stderrWriter = io.MultiWriter(os.Stderr, stderrWriter)
+		client, err = sftpservice.NewClient(conn)
+		if err != nil {
+			conn.Close()
+			return err
+		}
 	}
+	defer keepActiveSFTP(session.Alias, session.UpdatedAt, client)
 
-	cmd.Stdout = stdoutWriter
-	cmd.Stderr = stderrWriter
-
-	if err := cmd.Run(); err != nil {
-		output := stderr.String()
-		if output == "" {
-			output = stdout.String()
-		}
-		return fmt.Errorf("sftp command failed: %s", strings.TrimSpace(output))
+	if err := applyConfiguredLimit(client, session); err != nil {
+		return err
 	}
+	return fn(client)
+}
 
-	if postProcess != nil {
-		return postProcess(stdout.String())
+func renderSFTPListing(client *sftpservice.Client, path string, format utils.OutputFormat) error {
+	entries, err := client.List(path)
+	if err != nil {
+		return err
 	}
 
-	fmt.Println(strings.TrimSpace(stdout.String()))
-	return nil
+	return utils.Render(format, entries, func() error {
+		return printSFTPEntries(entries)
+	})
 }
 
-func renderSFTPListing(output string) error {
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	if len(lines) == 0 || output == "" {
+func printSFTPEntries(entries []sftpservice.Entry) error {
+	if len(entries) == 0 {
 		fmt.Println("(empty)")
 		return nil
 	}
 
-	fmt.Printf("%s%-30s %-12s %-20s%s\n", utils.Cyan, "NAME", "SIZE", "MODIFIED", utils.Reset)
-	for _, line := range lines {
-		fields := strings.Fields(line)
-		if len(fields) >= 9 {
-			name := strings.Join(fields[8:], " ")
-			size := fields[4]
-			date := strings.Join(fields[5:8], " ")
-			fmt.Printf("%-30s %-12s %-20s\n", name, size, normaliseDate(date))
-			continue
+	fmt.Printf("%s%-30s %-12s %-11s %-20s%s\n", utils.Cyan, "NAME", "SIZE", "MODE", "MODIFIED", utils.Reset)
+	for _, entry := range entries {
+		name := entry.Name
+		if entry.IsDir {
+			name += "/"
+		}
+		modTime := ""
+		if !entry.ModTime.IsZero() {
+			modTime = entry.ModTime.UTC().Format(time.RFC3339)
 		}
-		fmt.Println(line)
+		fmt.Printf("%-30s %-12d %-11s %-20s\n", name, entry.Size, entry.Mode, modTime)
 	}
 	return nil
 }
-
-func buildSFTPArgs(session config.Session, batchSource string) []string {
-	args := []string{"-b", batchSource}
-	args = append(args, "-P", fmt.Sprintf("%d", session.Port))
-	if session.AuthMethod == config.AuthPrivateKey && session.KeyPath != "" {
-		args = append(args, "-i", session.KeyPath)
-	}
-	args = append(args, fmt.Sprintf("%s@%s", session.Username, session.Host))
-	return args
-}
-
-func normaliseDate(value string) string {
-	// sftp output differs across implementations. Try to parse RFC3339 and
-	// fall back to the raw string when parsing fails.
-	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
-		return parsed.UTC().Format(time.RFC3339)
-	}
-	return value
-}
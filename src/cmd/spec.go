@@ -0,0 +1,473 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"servercommander/src/utils"
+)
+
+// FlagKind describes the type of value accepted by a flag.
+type FlagKind int
+
+const (
+	// BoolFlag is a switch such as --recursive. It may be given as
+	// --name=false to disable it explicitly.
+	BoolFlag FlagKind = iota
+	// StringFlag takes a single value.
+	StringFlag
+	// IntFlag takes a single integer value.
+	IntFlag
+	// ListFlag takes a value and may be repeated.
+	ListFlag
+)
+
+// Flag declares an option accepted by a command.
+type Flag struct {
+	Name        string
+	Short       string
+	Kind        FlagKind
+	Default     string
+	Placeholder string
+	// Values restricts the accepted values and is used for completion.
+	Values      []string
+	Description string
+}
+
+// outputFlag is shared by the list and show commands that can print their
+// results in machine-readable formats.
+var outputFlag = Flag{
+	Name:        "output",
+	Short:       "o",
+	Kind:        StringFlag,
+	Default:     string(utils.OutputTable),
+	Values:      utils.OutputFormats(),
+	Description: "Output format",
+}
+
+// Arg declares a positional argument.
+type Arg struct {
+	Name        string
+	Description string
+	Optional    bool
+	// Variadic arguments consume all remaining positional arguments and must
+	// be declared last.
+	Variadic bool
+	// Verbatim stops flag parsing at the first word of this argument so that
+	// the remaining input, such as a remote command line, is passed on
+	// untouched. Context.Raw returns it as typed.
+	Verbatim bool
+	Default  string
+	// Values restricts the accepted values and is used for completion.
+	Values   []string
+	Complete CompletionFunc
+}
+
+// CommandSpec declares a command: its subcommands or, for leaf commands, the
+// accepted arguments, flags and the handler. Parsing, validation, usage
+// messages, help pages and completion are derived from it.
+type CommandSpec struct {
+	Name        string
+	Description string
+	Subcommands []*CommandSpec
+	Args        []Arg
+	Flags       []Flag
+	Examples    []string
+	Handler     func(ctx *Context) error
+
+	parent *CommandSpec
+}
+
+// Context holds the parsed arguments passed to a command handler.
+type Context struct {
+	Command *CommandSpec
+	args    map[string][]string
+	flags   map[string][]string
+	// verbatimFrom is the index of the argument at which a Verbatim argument
+	// started, or -1; raw is the input from there on as it was typed.
+	verbatimFrom int
+	raw          string
+}
+
+// Arg returns the value of a positional argument or its default.
+func (c *Context) Arg(name string) string {
+	if values := c.args[name]; len(values) > 0 {
+		return values[0]
+	}
+	if arg := c.Command.arg(name); arg != nil {
+		return arg.Default
+	}
+	return ""
+}
+
+// Args returns all values bound to a variadic argument.
+func (c *Context) Args(name string) []string {
+	return c.args[name]
+}
+
+// Raw returns a Verbatim argument. Input typed in the console is returned
+// exactly as typed, however many words it has: quotes, variable references
+// and ~ are left for the remote shell. Commands given as program arguments
+// were already split and expanded by the local shell; their words are joined
+// with spaces, like OpenSSH does.
+func (c *Context) Raw(name string) string {
+	if c.raw != "" {
+		return c.raw
+	}
+	return strings.Join(c.args[name], " ")
+}
+
+// IsSet reports whether a flag was given on the command line.
+func (c *Context) IsSet(name string) bool {
+	_, ok := c.flags[name]
+	return ok
+}
+
+// String returns the value of a flag or its default.
+func (c *Context) String(name string) string {
+	if values := c.flags[name]; len(values) > 0 {
+		return values[len(values)-1]
+	}
+	if flag := c.Command.flag(name); flag != nil {
+		return flag.Default
+	}
+	return ""
+}
+
+// Strings returns every value given for a repeatable flag.
+func (c *Context) Strings(name string) []string {
+	return c.flags[name]
+}
+
+// Bool returns the value of a switch.
+func (c *Context) Bool(name string) bool {
+	value, _ := strconv.ParseBool(c.String(name))
+	return value
+}
+
+// Int returns the value of an integer flag. Values are validated while
+// parsing, so invalid input never reaches the handler.
+func (c *Context) Int(name string) int {
+	value, _ := strconv.Atoi(c.String(name))
+	return value
+}
+
+// OutputFormat returns the format selected with the shared output flag.
+func (c *Context) OutputFormat() utils.OutputFormat {
+	return utils.OutputFormat(c.String(outputFlag.Name))
+}
+
+// Path returns the full command path, e.g. "session import bundle".
+func (s *CommandSpec) Path() string {
+	if s.parent == nil {
+		return s.Name
+	}
+	return s.parent.Path() + " " + s.Name
+}
+
+// Usage returns the one-line synopsis of the command.
+func (s *CommandSpec) Usage() string {
+	parts := []string{s.Path()}
+
+	if len(s.Subcommands) > 0 {
+		names := make([]string, 0, len(s.Subcommands))
+		for _, sub := range s.Subcommands {
+			names = append(names, sub.Name)
+		}
+		parts = append(parts, "<"+strings.Join(names, "|")+">", "...")
+		return strings.Join(parts, " ")
+	}
+
+	for _, arg := range s.Args {
+		name := arg.Name
+		if arg.Variadic {
+			name += "..."
+		}
+		if arg.Optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+
+	for _, flag := range s.Flags {
+		parts = append(parts, "["+flag.synopsis()+"]")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (f Flag) synopsis() string {
+	name := "--" + f.Name
+	if f.Short != "" {
+		name = "-" + f.Short + "|" + name
+	}
+	if f.Kind == BoolFlag {
+		return name
+	}
+	return name + " <" + f.placeholder() + ">"
+}
+
+func (f Flag) placeholder() string {
+	switch {
+	case f.Placeholder != "":
+		return f.Placeholder
+	case len(f.Values) > 0:
+		return strings.Join(f.Values, "|")
+	case f.Kind == IntFlag:
+		return "n"
+	default:
+		return "value"
+	}
+}
+
+func (s *CommandSpec) subcommand(name string) *CommandSpec {
+	for _, sub := range s.Subcommands {
+		if strings.EqualFold(sub.Name, name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+func (s *CommandSpec) flag(name string) *Flag {
+	for i := range s.Flags {
+		if s.Flags[i].Name == name || (s.Flags[i].Short != "" && s.Flags[i].Short == name) {
+			return &s.Flags[i]
+		}
+	}
+	return nil
+}
+
+func (s *CommandSpec) arg(name string) *Arg {
+	for i := range s.Args {
+		if s.Args[i].Name == name {
+			return &s.Args[i]
+		}
+	}
+	return nil
+}
+
+// argAt returns the argument receiving the positional value at index.
+func (s *CommandSpec) argAt(index int) *Arg {
+	if index < len(s.Args) {
+		return &s.Args[index]
+	}
+	if n := len(s.Args); n > 0 && s.Args[n-1].Variadic {
+		return &s.Args[n-1]
+	}
+	return nil
+}
+
+// link sets the parent pointers of the subcommand tree.
+func (s *CommandSpec) link() {
+	for _, sub := range s.Subcommands {
+		sub.parent = s
+		sub.link()
+	}
+}
+
+// resolve walks the subcommand tree along args and returns the leaf command
+// together with the remaining arguments.
+func (s *CommandSpec) resolve(args []string) (*CommandSpec, []string, error) {
+	spec := s
+	for len(spec.Subcommands) > 0 {
+		if len(args) == 0 {
+			return nil, nil, utils.NewUsageError(spec.Usage())
+		}
+		sub := spec.subcommand(args[0])
+		if sub == nil {
+			return nil, nil, &utils.UsageError{Message: fmt.Sprintf("unknown %s action '%s'. Type 'help %s' for details", spec.Path(), args[0], spec.Path())}
+		}
+		spec = sub
+		args = args[1:]
+	}
+	return spec, args, nil
+}
+
+// parse binds args to the flags and positional arguments of a leaf command.
+func (s *CommandSpec) parse(args []string) (*Context, error) {
+	ctx := &Context{Command: s, args: map[string][]string{}, flags: map[string][]string{}, verbatimFrom: -1}
+
+	positionals := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if s.verbatimAt(len(positionals)) && i+1 < len(args) {
+				ctx.verbatimFrom = i + 1
+			}
+			positionals = append(positionals, args[i+1:]...)
+			break
+		}
+		if !isFlag(arg) {
+			// Options are still accepted in front of a Verbatim argument;
+			// everything from its first word on belongs to it.
+			if s.verbatimAt(len(positionals)) {
+				ctx.verbatimFrom = i
+				positionals = append(positionals, args[i:]...)
+				break
+			}
+			positionals = append(positionals, arg)
+			continue
+		}
+
+		name, value, hasValue := splitFlag(arg)
+		flag := s.flag(name)
+		if flag == nil {
+			return nil, s.usageError(fmt.Sprintf("unknown option %s", arg))
+		}
+
+		if flag.Kind == BoolFlag {
+			if !hasValue {
+				value = "true"
+			} else if _, err := strconv.ParseBool(value); err != nil {
+				return nil, s.usageError(fmt.Sprintf("option --%s expects true or false", flag.Name))
+			}
+		} else if !hasValue {
+			if i+1 >= len(args) {
+				return nil, s.usageError(fmt.Sprintf("option --%s requires a value", flag.Name))
+			}
+			i++
+			value = args[i]
+		}
+
+		if flag.Kind == IntFlag {
+			if _, err := strconv.Atoi(value); err != nil {
+				return nil, s.usageError(fmt.Sprintf("option --%s expects a number, got '%s'", flag.Name, value))
+			}
+		}
+		canonical, ok := matchValue(flag.Values, value)
+		if !ok {
+			return nil, s.usageError(fmt.Sprintf("invalid value '%s' for --%s (expected %s)", value, flag.Name, strings.Join(flag.Values, ", ")))
+		}
+		ctx.flags[flag.Name] = append(ctx.flags[flag.Name], canonical)
+	}
+
+	for i, arg := range s.Args {
+		if arg.Variadic {
+			if i < len(positionals) {
+				ctx.args[arg.Name] = positionals[i:]
+			} else if !arg.Optional {
+				return nil, utils.NewUsageError(s.Usage())
+			}
+			positionals = nil
+			break
+		}
+		if i >= len(positionals) {
+			if !arg.Optional {
+				return nil, utils.NewUsageError(s.Usage())
+			}
+			continue
+		}
+		value, ok := matchValue(arg.Values, positionals[i])
+		if !ok {
+			return nil, s.usageError(fmt.Sprintf("invalid %s '%s' (expected %s)", arg.Name, positionals[i], strings.Join(arg.Values, ", ")))
+		}
+		ctx.args[arg.Name] = []string{value}
+	}
+
+	if len(positionals) > len(s.Args) {
+		return nil, utils.NewUsageError(s.Usage())
+	}
+
+	return ctx, nil
+}
+
+func (s *CommandSpec) verbatimAt(index int) bool {
+	arg := s.argAt(index)
+	return arg != nil && arg.Verbatim
+}
+
+func (s *CommandSpec) usageError(problem string) error {
+	return &utils.UsageError{Message: fmt.Sprintf("%s; expected: %s", problem, s.Usage())}
+}
+
+// isFlag reports whether arg looks like an option. Negative numbers and a lone
+// dash are treated as positional values.
+func isFlag(arg string) bool {
+	if len(arg) < 2 || arg[0] != '-' {
+		return false
+	}
+	_, err := strconv.ParseFloat(arg, 64)
+	return err != nil
+}
+
+func splitFlag(arg string) (string, string, bool) {
+	name := strings.TrimLeft(arg, "-")
+	if index := strings.Index(name, "="); index >= 0 {
+		return name[:index], name[index+1:], true
+	}
+	return name, "", false
+}
+
+// matchValue checks value against the allowed values, ignoring case, and
+// returns the canonical spelling.
+func matchValue(allowed []string, value string) (string, bool) {
+	if len(allowed) == 0 {
+		return value, true
+	}
+	for _, candidate := range allowed {
+		if strings.EqualFold(candidate, value) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// complete returns candidates for word given the arguments typed so far.
+func (s *CommandSpec) complete(args []string, word string) []string {
+	spec := s
+	for len(spec.Subcommands) > 0 {
+		if len(args) == 0 {
+			names := make([]string, 0, len(spec.Subcommands))
+			for _, sub := range spec.Subcommands {
+				names = append(names, sub.Name)
+			}
+			return names
+		}
+		if spec = spec.subcommand(args[0]); spec == nil {
+			return nil
+		}
+		args = args[1:]
+	}
+
+	positionals := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !isFlag(arg) {
+			if spec.verbatimAt(len(positionals)) {
+				return nil
+			}
+			positionals = append(positionals, arg)
+			continue
+		}
+		name, _, hasValue := splitFlag(arg)
+		if flag := spec.flag(name); flag != nil && flag.Kind != BoolFlag && !hasValue {
+			if i+1 == len(args) {
+				return flag.Values
+			}
+			i++
+		}
+	}
+
+	if strings.HasPrefix(word, "-") {
+		names := make([]string, 0, len(spec.Flags))
+		for _, flag := range spec.Flags {
+			names = append(names, "--"+flag.Name)
+		}
+		return names
+	}
+
+	arg := spec.argAt(len(positionals))
+	switch {
+	case arg == nil:
+		return nil
+	case len(arg.Values) > 0:
+		return arg.Values
+	case arg.Complete != nil:
+		return arg.Complete(positionals, word)
+	default:
+		return nil
+	}
+}
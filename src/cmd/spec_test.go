@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"servercommander/src/utils"
+)
+
+func newTestSpec() *CommandSpec {
+	spec := &CommandSpec{
+		Name: "tool",
+		Subcommands: []*CommandSpec{
+			{
+				Name: "copy",
+				Args: []Arg{
+					{Name: "source"},
+					{Name: "target", Optional: true, Default: "."},
+				},
+				Flags: []Flag{
+					{Name: "recursive", Short: "r", Kind: BoolFlag},
+					{Name: "limit", Kind: IntFlag},
+					{Name: "mode", Kind: StringFlag, Default: "safe", Values: []string{"fast", "safe"}},
+					{Name: "exclude", Kind: ListFlag},
+				},
+			},
+			{
+				Name: "run",
+				Args: []Arg{
+					{Name: "targets"},
+					{Name: "command", Variadic: true, Verbatim: true},
+				},
+				Flags: []Flag{
+					{Name: "parallel", Short: "p", Kind: IntFlag, Default: "10"},
+					{Name: "group", Short: "g", Kind: BoolFlag},
+				},
+			},
+			{
+				Name: "format",
+				Args: []Arg{{Name: "kind", Values: []string{"JSON", "YAML"}}},
+			},
+			{
+				Name: "tags",
+				Subcommands: []*CommandSpec{
+					{Name: "add", Args: []Arg{{Name: "names", Variadic: true}}},
+					{Name: "list", Args: []Arg{{Name: "filter", Optional: true, Variadic: true}}},
+				},
+			},
+		},
+	}
+	spec.link()
+	return spec
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		path string
+		rest []string
+		err  string
+	}{
+		{name: "leaf", args: []string{"copy", "a"}, path: "tool copy", rest: []string{"a"}},
+		{name: "case insensitive", args: []string{"COPY"}, path: "tool copy", rest: []string{}},
+		{name: "nested", args: []string{"tags", "add", "x", "y"}, path: "tool tags add", rest: []string{"x", "y"}},
+		{name: "flags stay with the leaf", args: []string{"run", "-p", "3", "web"}, path: "tool run", rest: []string{"-p", "3", "web"}},
+		{name: "missing subcommand", args: nil, err: "tool <copy|run|format|tags> ..."},
+		{name: "missing nested subcommand", args: []string{"tags"}, err: "tool tags <add|list> ..."},
+		{name: "unknown subcommand", args: []string{"move"}, err: "unknown tool action 'move'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaf, rest, err := newTestSpec().resolve(tt.args)
+			if tt.err != "" {
+				var usageErr *utils.UsageError
+				if !errors.As(err, &usageErr) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("resolve(%q) error = %v, want usage error containing %q", tt.args, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve(%q) failed: %v", tt.args, err)
+			}
+			if leaf.Path() != tt.path || !reflect.DeepEqual(rest, tt.rest) {
+				t.Errorf("resolve(%q) = %q, %q, want %q, %q", tt.args, leaf.Path(), rest, tt.path, tt.rest)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		args     []string
+		want     map[string][]string
+		flags    map[string][]string
+		verbatim int
+		err      string
+	}{
+		{
+			name:    "positionals",
+			command: "copy",
+			args:    []string{"a", "b"},
+			want:    map[string][]string{"source": {"a"}, "target": {"b"}},
+			flags:   map[string][]string{},
+		},
+		{
+			name:    "optional omitted",
+			command: "copy",
+			args:    []string{"a"},
+			want:    map[string][]string{"source": {"a"}},
+			flags:   map[string][]string{},
+		},
+		{
+			name:    "flags anywhere",
+			command: "copy",
+			args:    []string{"-r", "a", "--limit", "5", "b", "--mode=FAST"},
+			want:    map[string][]string{"source": {"a"}, "target": {"b"}},
+			flags:   map[string][]string{"recursive": {"true"}, "limit": {"5"}, "mode": {"fast"}},
+		},
+		{
+			name:    "bool with value",
+			command: "copy",
+			args:    []string{"--recursive=false", "a"},
+			want:    map[string][]string{"source": {"a"}},
+			flags:   map[string][]string{"recursive": {"false"}},
+		},
+		{
+			name:    "repeated list flag",
+			command: "copy",
+			args:    []string{"a", "--exclude", "*.log", "--exclude=tmp"},
+			want:    map[string][]string{"source": {"a"}},
+			flags:   map[string][]string{"exclude": {"*.log", "tmp"}},
+		},
+		{
+			name:    "negative number is positional",
+			command: "copy",
+			args:    []string{"-5", "-"},
+			want:    map[string][]string{"source": {"-5"}, "target": {"-"}},
+			flags:   map[string][]string{},
+		},
+		{
+			name:    "double dash ends flags",
+			command: "copy",
+			args:    []string{"--", "-r", "--limit"},
+			want:    map[string][]string{"source": {"-r"}, "target": {"--limit"}},
+			flags:   map[string][]string{},
+		},
+		{
+			name:    "value matched case insensitively",
+			command: "format",
+			args:    []string{"yaml"},
+			want:    map[string][]string{"kind": {"YAML"}},
+			flags:   map[string][]string{},
+		},
+		{
+			name:     "verbatim keeps flags of the command",
+			command:  "run",
+			args:     []string{"web", "ls", "-la", "--group"},
+			want:     map[string][]string{"targets": {"web"}, "command": {"ls", "-la", "--group"}},
+			flags:    map[string][]string{},
+			verbatim: 1,
+		},
+		{
+			name:     "options before the verbatim command",
+			command:  "run",
+			args:     []string{"-p", "2", "web", "--group", "df", "-h"},
+			want:     map[string][]string{"targets": {"web"}, "command": {"df", "-h"}},
+			flags:    map[string][]string{"parallel": {"2"}, "group": {"true"}},
+			verbatim: 4,
+		},
+		{
+			name:     "options after the targets",
+			command:  "run",
+			args:     []string{"web,db", "-p", "3", "-g", "uptime", "-p"},
+			want:     map[string][]string{"targets": {"web,db"}, "command": {"uptime", "-p"}},
+			flags:    map[string][]string{"parallel": {"3"}, "group": {"true"}},
+			verbatim: 4,
+		},
+		{
+			name:     "verbatim after double dash",
+			command:  "run",
+			args:     []string{"web", "--", "-x", "y"},
+			want:     map[string][]string{"targets": {"web"}, "command": {"-x", "y"}},
+			flags:    map[string][]string{},
+			verbatim: 2,
+		},
+		{
+			name:    "variadic",
+			command: "tags add",
+			args:    []string{"a", "b", "c"},
+			want:    map[string][]string{"names": {"a", "b", "c"}},
+			flags:   map[string][]string{},
+		},
+		{
+			name:    "optional variadic omitted",
+			command: "tags list",
+			args:    nil,
+			want:    map[string][]string{},
+			flags:   map[string][]string{},
+		},
+		{name: "missing argument", command: "copy", args: nil, err: "expected: tool copy <source>"},
+		{name: "missing variadic", command: "run", args: []string{"web"}, err: "expected: tool run <targets> <command...>"},
+		{name: "too many arguments", command: "copy", args: []string{"a", "b", "c"}, err: "expected: tool copy"},
+		{name: "unknown option", command: "copy", args: []string{"--force", "a"}, err: "unknown option --force"},
+		{name: "missing value", command: "copy", args: []string{"a", "--limit"}, err: "option --limit requires a value"},
+		{name: "invalid number", command: "copy", args: []string{"a", "--limit", "x"}, err: "option --limit expects a number, got 'x'"},
+		{name: "invalid bool", command: "copy", args: []string{"a", "--recursive=maybe"}, err: "option --recursive expects true or false"},
+		{name: "value not allowed", command: "copy", args: []string{"a", "--mode", "slow"}, err: "invalid value 'slow' for --mode (expected fast, safe)"},
+		{name: "argument not allowed", command: "format", args: []string{"xml"}, err: "invalid kind 'xml' (expected JSON, YAML)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaf, _, err := newTestSpec().resolve(strings.Fields(tt.command))
+			if err != nil {
+				t.Fatalf("resolve(%q) failed: %v", tt.command, err)
+			}
+
+			ctx, err := leaf.parse(tt.args)
+			if tt.err != "" {
+				var usageErr *utils.UsageError
+				if !errors.As(err, &usageErr) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parse(%q) error = %v, want usage error containing %q", tt.args, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse(%q) failed: %v", tt.args, err)
+			}
+
+			if !reflect.DeepEqual(ctx.args, tt.want) {
+				t.Errorf("parse(%q) args = %q, want %q", tt.args, ctx.args, tt.want)
+			}
+			if !reflect.DeepEqual(ctx.flags, tt.flags) {
+				t.Errorf("parse(%q) flags = %q, want %q", tt.args, ctx.flags, tt.flags)
+			}
+			wantVerbatim := tt.verbatim
+			if wantVerbatim == 0 {
+				wantVerbatim = -1
+			}
+			if ctx.verbatimFrom != wantVerbatim {
+				t.Errorf("parse(%q) verbatimFrom = %d, want %d", tt.args, ctx.verbatimFrom, wantVerbatim)
+			}
+		})
+	}
+}
+
+func TestContextDefaults(t *testing.T) {
+	leaf, _, err := newTestSpec().resolve([]string{"copy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := leaf.parse([]string{"a", "--limit", "7", "--mode", "fast", "--mode", "safe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ctx.Arg("target"); got != "." {
+		t.Errorf("Arg(target) = %q, want the default", got)
+	}
+	if got := ctx.Int("limit"); got != 7 {
+		t.Errorf("Int(limit) = %d, want 7", got)
+	}
+	if got := ctx.String("mode"); got != "safe" {
+		t.Errorf("String(mode) = %q, want the last value", got)
+	}
+	if ctx.Bool("recursive") || ctx.IsSet("recursive") {
+		t.Error("recursive is set although it was not given")
+	}
+}
+
+func TestExecuteRawCommand(t *testing.T) {
+	var got string
+	RegisterCommand(&CommandSpec{
+		Name: "rawtest",
+		Args: []Arg{
+			{Name: "targets"},
+			{Name: "command", Variadic: true, Verbatim: true},
+		},
+		Flags:   []Flag{{Name: "group", Kind: BoolFlag}},
+		Handler: func(ctx *Context) error { got = ctx.Raw("command"); return nil },
+	})
+	t.Cleanup(func() { delete(commandRegistry, "rawtest") })
+	t.Setenv("SC_RAW", "local")
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `rawtest web echo "a  b" '$HOME' $SC_RAW`, want: `echo "a  b" '$HOME' $SC_RAW`},
+		{input: `rawtest web --group   df -h /  `, want: `df -h /`},
+		{input: `rawtest web "echo 'a  b' | wc -c"`, want: `"echo 'a  b' | wc -c"`},
+		{input: `rawtest web $SC_RAW`, want: `$SC_RAW`},
+		{input: `rawtest web ~/bin/deploy`, want: `~/bin/deploy`},
+		{input: `rawtest web -- --help`, want: `--help`},
+	}
+	for _, tt := range tests {
+		got = ""
+		if err := Execute(tt.input); err != nil {
+			t.Fatalf("Execute(%q) failed: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("Execute(%q) passed %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	got = ""
+	if err := ExecuteArgs([]string{"rawtest", "web", "echo", "a  b"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "echo a  b"; got != want {
+		t.Errorf("ExecuteArgs passed %q, want %q", got, want)
+	}
+}
+
+func TestContextRaw(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		raw   string
+		want  string
+	}{
+		{name: "typed command", words: []string{"echo", "a  b"}, raw: `echo "a  b"`, want: `echo "a  b"`},
+		{name: "single quoted word", words: []string{"ls -l | wc"}, raw: `"ls -l | wc"`, want: `"ls -l | wc"`},
+		{name: "single word", words: []string{"/home/me"}, raw: "~", want: "~"},
+		{name: "program arguments", words: []string{"echo", "a  b"}, want: "echo a  b"},
+		{name: "no command", want: ""},
+	}
+
+	for _, tt := range tests {
+		ctx := &Context{args: map[string][]string{"command": tt.words}, raw: tt.raw}
+		if got := ctx.Raw("command"); got != tt.want {
+			t.Errorf("%s: Raw = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
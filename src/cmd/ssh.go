@@ -3,60 +3,69 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"strings"
 
 	"servercommander/src/services/config"
 	sshservice "servercommander/src/services/ssh"
+	"servercommander/src/services/vault"
 	"servercommander/src/utils"
 )
 
 func init() {
-	RegisterCommand("ssh", "Execute SSH operations", sshCommand)
-	RegisterCommand("connect", "Open an interactive SSH session", connectCommand)
+	RegisterCommand(&CommandSpec{
+		Name:        "ssh",
+		Description: "Execute SSH operations",
+		Subcommands: []*CommandSpec{
+			{
+				Name:        "connect",
+				Description: "Open an interactive shell",
+				Args:        []Arg{{Name: "alias", Description: "SSH session", Complete: aliasCompletion(config.ProtocolSSH)}},
+				Handler: func(ctx *Context) error {
+					session, err := loadSSHSession(ctx.Arg("alias"))
+					if err != nil {
+						return err
+					}
+					return startInteractiveSSH(session)
+				},
+			},
+			{
+				Name:        "exec",
+				Description: "Run a command on one or several remote hosts",
+				Args: []Arg{
+					{Name: "targets", Description: "SSH session, or a comma separated list of aliases, glob patterns and @tags", Complete: sshTargetCompletion},
+					{Name: "command", Description: "Remote command line, passed on unchanged", Variadic: true, Verbatim: true},
+				},
+				Flags: execFlags,
+				Examples: []string{
+					"ssh exec web uptime",
+					"ssh exec web systemctl status nginx --no-pager",
+					"ssh exec --parallel 20 @web sudo systemctl reload nginx",
+					`ssh exec "web-*,db1" --group df -h /`,
+				},
+				Handler: execCommand,
+			},
+		},
+	})
+	RegisterCommand(&CommandSpec{
+		Name:        "connect",
+		Description: "Open an interactive SSH session",
+		Args:        []Arg{{Name: "alias", Description: "Session to connect to", Complete: aliasCompletion(config.ProtocolSSH)}},
+		Handler:     connectCommand,
+	})
 }
 
-func sshCommand(args []string) error {
-	if len(args) == 0 {
-		return errors.New(utils.FormatUsageError("ssh <connect|exec> <alias> [command]"))
+func loadSSHSession(alias string) (config.Session, error) {
+	session, err := loadSession(alias)
+	if err != nil {
+		return config.Session{}, err
 	}
-
-	action := strings.ToLower(args[0])
-	switch action {
-	case "connect":
-		if err := ensureUsage(args[1:], 1, 1, "ssh connect <alias>"); err != nil {
-			return err
-		}
-		session, err := loadSession(args[1])
-		if err != nil {
-			return err
-		}
-		if session.Protocol != config.ProtocolSSH {
-			return fmt.Errorf("session '%s' is not an SSH session", session.Alias)
-		}
-		return startInteractiveSSH(session)
-	case "exec":
-		if err := ensureUsage(args[1:], 2, -1, "ssh exec <alias> <command>"); err != nil {
-			return err
-		}
-		session, err := loadSession(args[1])
-		if err != nil {
-			return err
-		}
-		if session.Protocol != config.ProtocolSSH {
-			return fmt.Errorf("session '%s' is not an SSH session", session.Alias)
-		}
-		command := strings.Join(args[2:], " ")
-		return executeRemoteCommand(session, command)
-	default:
-		return fmt.Errorf("unknown ssh action '%s'", action)
+	if session.Protocol != config.ProtocolSSH {
+		return config.Session{}, fmt.Errorf("session '%s' is not an SSH session", session.Alias)
 	}
+	return session, nil
 }
 
-func connectCommand(args []string) error {
-	if err := ensureUsage(args, 1, 1, "connect <alias>"); err != nil {
-		return err
-	}
-	session, err := loadSession(args[0])
+func connectCommand(ctx *Context) error {
+	session, err := loadSession(ctx.Arg("alias"))
 	if err != nil {
 		return err
 	}
@@ -115,17 +124,41 @@ func executeRemoteCommand(session config.Session, command string) error {
 	return nil
 }
 
+// dialSSHWithRetry connects to the session and, when the private key turns out
+// to be encrypted, asks for the passphrase and tries once more. The passphrase
+// is returned so callers can reuse it for additional connections.
 func dialSSHWithRetry(session config.Session, password string) (*sshservice.Client, []byte, error) {
 	client, err := sshservice.Connect(session, password, nil)
+	if err == nil {
+		return client, nil, nil
+	}
+	if !errors.Is(err, sshservice.ErrPassphraseRequired) {
+		return nil, nil, err
+	}
+
+	passphrase, ok := vault.Lookup(session.Alias, vault.KindPassphrase)
+	if !ok {
+		passphrase, err = utils.PromptPassword(fmt.Sprintf("Passphrase for key %s", session.KeyPath))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	client, err = sshservice.Connect(session, password, []byte(passphrase))
 	if err != nil {
 		return nil, nil, err
 	}
-	return client, nil, nil
+	return client, []byte(passphrase), nil
 }
 
+// promptPassword returns the session password from the unlocked vault and
+// falls back to asking the user when the vault has no entry for the session.
 func promptPassword(session config.Session) (string, error) {
 	if !session.RequiresPass {
 		return "", nil
 	}
+	if password, ok := vault.Lookup(session.Alias, vault.KindPassword); ok {
+		return password, nil
+	}
 	return utils.PromptPassword(fmt.Sprintf("Password for %s@%s", session.Username, session.Host))
 }
@@ -0,0 +1,399 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"servercommander/src/services/config"
+	sshservice "servercommander/src/services/ssh"
+	"servercommander/src/services/vault"
+	"servercommander/src/utils"
+)
+
+// execFlags control how ssh exec runs a command on several hosts.
+var execFlags = []Flag{
+	{Name: "parallel", Short: "p", Kind: IntFlag, Placeholder: "n", Default: "10", Description: "Number of hosts to run the command on at the same time"},
+	{Name: "group", Short: "g", Kind: BoolFlag, Description: "Print the output of each host as one block once it finished instead of prefixed lines"},
+	outputFlag,
+}
+
+// hostResult is the outcome of running a command on one host.
+type hostResult struct {
+	Alias    string  `json:"alias" yaml:"alias"`
+	Host     string  `json:"host" yaml:"host"`
+	ExitCode int     `json:"exitCode" yaml:"exitCode"`
+	Seconds  float64 `json:"seconds" yaml:"seconds"`
+	Error    string  `json:"error,omitempty" yaml:"error,omitempty"`
+	// Output is only collected for structured output formats; otherwise it
+	// is written to the console while the command runs.
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
+}
+
+func (r hostResult) failed() bool {
+	return r.Error != "" || r.ExitCode != 0
+}
+
+func execCommand(ctx *Context) error {
+	command := ctx.Raw("command")
+	sessions, err := resolveSSHTargets(ctx.Arg("targets"))
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 1 && ctx.OutputFormat() == utils.OutputTable {
+		return executeRemoteCommand(sessions[0], command)
+	}
+
+	parallel := ctx.Int("parallel")
+	if parallel <= 0 {
+		return &utils.UsageError{Message: "--parallel must be at least 1"}
+	}
+
+	// Passwords, key passphrases and the secrets of jump hosts are asked for
+	// up front, one host after the other, so that no prompt interrupts the
+	// output of hosts that are already running.
+	passwords := make([]string, len(sessions))
+	passphrases := make([][]byte, len(sessions))
+	byKey := map[string][]byte{}
+	for i, session := range sessions {
+		if passwords[i], err = promptPassword(session); err != nil {
+			return err
+		}
+		if passphrases[i], err = keyPassphrase(session, byKey); err != nil {
+			return err
+		}
+		if err := sshservice.PrepareJumpHosts(session); err != nil {
+			return fmt.Errorf("%s: %w", session.Alias, err)
+		}
+	}
+
+	output := &fanOutput{
+		grouped:    ctx.Bool("group"),
+		structured: ctx.OutputFormat() != utils.OutputTable,
+		width:      aliasWidth(sessions),
+	}
+	results := make([]hostResult, len(sessions))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, session := range sessions {
+		// Taking the slot before starting the goroutine starts hosts in the
+		// order they were given.
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = runOnHost(session, passwords[i], passphrases[i], command, output)
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.failed() {
+			failed++
+		}
+	}
+	if err := utils.Render(ctx.OutputFormat(), results, func() error {
+		printHostResults(results)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d host(s) failed", failed, len(results))
+	}
+	return nil
+}
+
+// resolveSSHTargets expands a comma separated list of aliases, glob patterns
+// matched against aliases, and @tags into SSH sessions. Every entry has to
+// match at least one session; hosts matched twice run the command once.
+func resolveSSHTargets(targets string) ([]config.Session, error) {
+	store, err := config.LoadSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []config.Session{}
+	seen := map[string]bool{}
+	add := func(session config.Session) {
+		key := strings.ToLower(session.Alias)
+		if !seen[key] {
+			seen[key] = true
+			sessions = append(sessions, session)
+		}
+	}
+
+	for _, target := range strings.Split(targets, ",") {
+		target = strings.TrimSpace(target)
+		switch {
+		case target == "":
+			continue
+		case strings.HasPrefix(target, "@"):
+			matched := false
+			for _, session := range store.List() {
+				if session.Protocol == config.ProtocolSSH && session.HasTag(target[1:]) {
+					add(session)
+					matched = true
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("no SSH session is tagged '%s'", target[1:])
+			}
+		case strings.ContainsAny(target, "*?["):
+			matched := false
+			for _, session := range store.List() {
+				ok, err := path.Match(strings.ToLower(target), strings.ToLower(session.Alias))
+				if err != nil {
+					return nil, &utils.UsageError{Message: fmt.Sprintf("invalid pattern '%s'", target)}
+				}
+				if ok && session.Protocol == config.ProtocolSSH {
+					add(session)
+					matched = true
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("no SSH session matches '%s'", target)
+			}
+		default:
+			session, err := loadSSHSession(target)
+			if err != nil {
+				return nil, err
+			}
+			add(session)
+		}
+	}
+
+	if len(sessions) == 0 {
+		return nil, &utils.UsageError{Message: "no target given"}
+	}
+	return sessions, nil
+}
+
+// runOnHost connects to one host and runs command on it.
+func runOnHost(session config.Session, password string, passphrase []byte, command string, output *fanOutput) hostResult {
+	result := hostResult{Alias: session.Alias, Host: session.Host, ExitCode: -1}
+	started := time.Now()
+	stdout, stderr, finish := output.host(session.Alias)
+
+	client, err := sshservice.Connect(session, password, passphrase)
+	if err == nil {
+		result.ExitCode, err = client.Exec(command, stdout, stderr)
+		client.Close()
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Seconds = time.Since(started).Round(time.Millisecond).Seconds()
+	result.Output = finish(result)
+	return result
+}
+
+// keyPassphrase returns the passphrase of the session's private key, or nil
+// when the key is not encrypted. It is taken from the vault or asked for once
+// per key file; byKey remembers the answers.
+func keyPassphrase(session config.Session, byKey map[string][]byte) ([]byte, error) {
+	if !sshservice.NeedsPassphrase(session) {
+		return nil, nil
+	}
+	if passphrase, ok := byKey[session.KeyPath]; ok {
+		return passphrase, nil
+	}
+
+	value, found := vault.Lookup(session.Alias, vault.KindPassphrase)
+	if !found {
+		var err error
+		if value, err = utils.PromptPassword(fmt.Sprintf("Passphrase for key %s", session.KeyPath)); err != nil {
+			return nil, err
+		}
+	}
+	byKey[session.KeyPath] = []byte(value)
+	return byKey[session.KeyPath], nil
+}
+
+// fanOutput arranges the output of several hosts on the console. By default
+// every line is written as soon as it is complete, prefixed with the alias
+// of its host; grouped output holds it back until the host finished.
+// Structured output formats only collect it for the results.
+type fanOutput struct {
+	mu         sync.Mutex
+	grouped    bool
+	structured bool
+	width      int
+}
+
+// host returns the writers for the standard output and error of one host.
+// finish flushes what is left once the command ended and returns the
+// collected output for structured formats.
+func (o *fanOutput) host(alias string) (io.Writer, io.Writer, func(hostResult) string) {
+	if o.grouped || o.structured {
+		buffer := &lockedBuffer{}
+		return buffer, buffer, func(result hostResult) string {
+			if o.structured {
+				return buffer.String()
+			}
+			o.mu.Lock()
+			defer o.mu.Unlock()
+			fmt.Printf("%s=== %s (%s)%s\n", hostResultColor(result), alias, describeHostResult(result), utils.Reset)
+			if text := buffer.String(); text != "" {
+				fmt.Print(text)
+				if !strings.HasSuffix(text, "\n") {
+					fmt.Println()
+				}
+			}
+			return ""
+		}
+	}
+
+	prefix := fmt.Sprintf("%s%-*s%s | ", utils.Cyan, o.width, alias, utils.Reset)
+	stdout := &prefixWriter{mu: &o.mu, out: os.Stdout, prefix: prefix}
+	stderr := &prefixWriter{mu: &o.mu, out: os.Stderr, prefix: prefix}
+	return stdout, stderr, func(result hostResult) string {
+		stdout.Flush()
+		stderr.Flush()
+		if result.Error != "" {
+			o.mu.Lock()
+			fmt.Fprintf(os.Stderr, "%s%s%s%s\n", prefix, utils.Red, result.Error, utils.Reset)
+			o.mu.Unlock()
+		}
+		return ""
+	}
+}
+
+// prefixWriter writes complete lines to out, each preceded by prefix. The
+// shared mutex keeps lines of different hosts from being mixed.
+type prefixWriter struct {
+	mu      *sync.Mutex
+	out     io.Writer
+	prefix  string
+	pending []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		end := bytes.IndexByte(w.pending, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		w.writeLine(w.pending[:end])
+		w.pending = w.pending[end+1:]
+	}
+}
+
+// Flush writes a last line that did not end with a newline.
+func (w *prefixWriter) Flush() {
+	if len(w.pending) > 0 {
+		w.writeLine(w.pending)
+		w.pending = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintf(w.out, "%s%s\n", w.prefix, bytes.TrimSuffix(line, []byte("\r")))
+}
+
+// lockedBuffer collects standard output and error, which the SSH session
+// copies from separate goroutines.
+type lockedBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
+
+func aliasWidth(sessions []config.Session) int {
+	width := 0
+	for _, session := range sessions {
+		width = max(width, len(session.Alias))
+	}
+	return width
+}
+
+func printHostResults(results []hostResult) {
+	width := len("HOST")
+	for _, result := range results {
+		width = max(width, len(result.Alias))
+	}
+
+	fmt.Println()
+	fmt.Printf("%s%-*s %-6s %-10s %s%s\n", utils.Cyan, width, "HOST", "EXIT", "DURATION", "STATUS", utils.Reset)
+	failed := 0
+	for _, result := range results {
+		exit := "-"
+		if result.ExitCode >= 0 {
+			exit = fmt.Sprint(result.ExitCode)
+		}
+		if result.failed() {
+			failed++
+		}
+		duration := time.Duration(result.Seconds * float64(time.Second)).Round(time.Millisecond)
+		fmt.Printf("%-*s %-6s %-10s %s%s%s\n", width, result.Alias, exit, duration, hostResultColor(result), describeHostResult(result), utils.Reset)
+	}
+	fmt.Printf("%s%d of %d host(s) succeeded%s\n", utils.Cyan, len(results)-failed, len(results), utils.Reset)
+}
+
+func describeHostResult(result hostResult) string {
+	switch {
+	case result.Error != "":
+		return "error: " + result.Error
+	case result.ExitCode != 0:
+		return fmt.Sprintf("failed with exit code %d", result.ExitCode)
+	default:
+		return "ok"
+	}
+}
+
+func hostResultColor(result hostResult) string {
+	if result.failed() {
+		return utils.Red
+	}
+	return utils.Green
+}
+
+// sshTargetCompletion completes the aliases of SSH sessions and their tags
+// prefixed with @, also after a comma.
+func sshTargetCompletion(_ []string, word string) []string {
+	store, err := config.LoadSessions()
+	if err != nil {
+		return nil
+	}
+
+	head := ""
+	if index := strings.LastIndex(word, ","); index >= 0 {
+		head = word[:index+1]
+	}
+	candidates := []string{}
+	tags := map[string]bool{}
+	for _, session := range store.List() {
+		if session.Protocol != config.ProtocolSSH {
+			continue
+		}
+		candidates = append(candidates, head+session.Alias)
+		for _, tag := range session.Tags {
+			if !tags[tag] {
+				tags[tag] = true
+				candidates = append(candidates, head+"@"+tag)
+			}
+		}
+	}
+	return candidates
+}
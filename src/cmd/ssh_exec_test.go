@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"servercommander/src/services/config"
+	"servercommander/src/utils"
+)
+
+func TestResolveSSHTargets(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	store, err := config.LoadSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, session := range []config.Session{
+		{Alias: "web-1", Protocol: config.ProtocolSSH, Tags: []string{"prod", "web"}},
+		{Alias: "web-2", Protocol: config.ProtocolSSH, Tags: []string{"Prod", "web"}},
+		{Alias: "db", Protocol: config.ProtocolSSH, Tags: []string{"prod"}},
+		{Alias: "web-files", Protocol: config.ProtocolFTP, Tags: []string{"web", "files"}},
+	} {
+		store.Upsert(session)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		targets string
+		want    []string
+		err     string
+		usage   bool
+	}{
+		{name: "alias", targets: "db", want: []string{"db"}},
+		{name: "aliases keep their order", targets: "web-2, DB ,web-1", want: []string{"web-2", "db", "web-1"}},
+		{name: "tag", targets: "@web", want: []string{"web-1", "web-2"}},
+		{name: "tag ignores case", targets: "@PROD", want: []string{"db", "web-1", "web-2"}},
+		{name: "pattern skips other protocols", targets: "web-*", want: []string{"web-1", "web-2"}},
+		{name: "pattern ignores case", targets: "WEB-?", want: []string{"web-1", "web-2"}},
+		{name: "hosts matched twice run once", targets: "web-1,@prod,web-*", want: []string{"web-1", "db", "web-2"}},
+		{name: "empty entries", targets: ",db,,", want: []string{"db"}},
+		{name: "unknown alias", targets: "db,cache", err: "session 'cache' not found"},
+		{name: "not an ssh session", targets: "web-files", err: "session 'web-files' is not an SSH session"},
+		{name: "unknown tag", targets: "@files", err: "no SSH session is tagged 'files'"},
+		{name: "pattern without match", targets: "cache-*", err: "no SSH session matches 'cache-*'"},
+		{name: "invalid pattern", targets: "web-[", err: "invalid pattern 'web-['", usage: true},
+		{name: "no target", targets: " , ", err: "no target given", usage: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, err := resolveSSHTargets(tt.targets)
+			if tt.err != "" {
+				var usageErr *utils.UsageError
+				if err == nil || !strings.Contains(err.Error(), tt.err) || errors.As(err, &usageErr) != tt.usage {
+					t.Errorf("resolveSSHTargets(%q) error = %v, want %q (usage error %v)", tt.targets, err, tt.err, tt.usage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveSSHTargets(%q) failed: %v", tt.targets, err)
+			}
+
+			aliases := make([]string, len(sessions))
+			for i, session := range sessions {
+				aliases[i] = session.Alias
+			}
+			if !reflect.DeepEqual(aliases, tt.want) {
+				t.Errorf("resolveSSHTargets(%q) = %q, want %q", tt.targets, aliases, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+		// flushed is the output after Flush.
+		flushed string
+	}{
+		{name: "complete lines", writes: []string{"a\nb\n"}, want: "web | a\nweb | b\n", flushed: "web | a\nweb | b\n"},
+		{name: "line split across writes", writes: []string{"he", "llo\nwor", "ld\n"}, want: "web | hello\nweb | world\n", flushed: "web | hello\nweb | world\n"},
+		{name: "last line without newline", writes: []string{"a\npartial"}, want: "web | a\n", flushed: "web | a\nweb | partial\n"},
+		{name: "carriage returns", writes: []string{"a\r\n\r\n"}, want: "web | a\nweb | \n", flushed: "web | a\nweb | \n"},
+		{name: "nothing written", writes: nil, want: "", flushed: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writer := &prefixWriter{mu: &sync.Mutex{}, out: &out, prefix: "web | "}
+			for _, data := range tt.writes {
+				if n, err := writer.Write([]byte(data)); n != len(data) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", data, n, err)
+				}
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+			writer.Flush()
+			writer.Flush()
+			if out.String() != tt.flushed {
+				t.Errorf("output after Flush = %q, want %q", out.String(), tt.flushed)
+			}
+		})
+	}
+}
+
+func TestKeyPassphrase(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := gossh.MarshalPrivateKeyWithPassphrase(key, "", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := gossh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedPath := filepath.Join(dir, "encrypted")
+	plainPath := filepath.Join(dir, "plain")
+	if err := os.WriteFile(encryptedPath, pem.EncodeToMemory(encrypted), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(plainPath, pem.EncodeToMemory(plain), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only one answer is available: hosts sharing the key must not ask twice.
+	utils.SetPromptReader(strings.NewReader("secret\n"))
+	byKey := map[string][]byte{}
+	tests := []struct {
+		session config.Session
+		want    string
+	}{
+		{session: config.Session{Alias: "a", AuthMethod: config.AuthPrivateKey, KeyPath: encryptedPath}, want: "secret"},
+		{session: config.Session{Alias: "b", AuthMethod: config.AuthPrivateKey, KeyPath: encryptedPath}, want: "secret"},
+		{session: config.Session{Alias: "c", AuthMethod: config.AuthPrivateKey, KeyPath: plainPath}},
+		{session: config.Session{Alias: "d", AuthMethod: config.AuthPassword}},
+	}
+	for _, tt := range tests {
+		got, err := keyPassphrase(tt.session, byKey)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("keyPassphrase(%s) = %q, %v, want %q", tt.session.Alias, got, err, tt.want)
+		}
+	}
+}
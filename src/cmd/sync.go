@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	ftpservice "servercommander/src/services/ftp"
+	sftpservice "servercommander/src/services/sftp"
+	"servercommander/src/services/transfer"
+	"servercommander/src/utils"
+)
+
+// ftpRemote adapts the FTP client to transfer.Remote.
+type ftpRemote struct {
+	*ftpservice.Client
+}
+
+func (r ftpRemote) List(dir string) ([]transfer.RemoteFile, error) {
+	entries, err := r.Client.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]transfer.RemoteFile, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, transfer.RemoteFile{Name: entry.Name, Size: entry.Size, ModTime: entry.ModTime, IsDir: entry.IsDir})
+	}
+	return files, nil
+}
+
+// sftpRemote adapts the SFTP client to transfer.Remote.
+type sftpRemote struct {
+	*sftpservice.Client
+}
+
+func (r sftpRemote) List(dir string) ([]transfer.RemoteFile, error) {
+	entries, err := r.Client.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]transfer.RemoteFile, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, transfer.RemoteFile{Name: entry.Name, Size: entry.Size, ModTime: entry.ModTime, IsDir: entry.IsDir})
+	}
+	return files, nil
+}
+
+// syncCommand builds the sync subcommand shared by the ftp and sftp commands.
+// connect runs fn with a connected remote for the given alias. tolerance is
+// the timestamp difference still considered equal.
+func syncCommand(command string, tolerance time.Duration, complete CompletionFunc, connect func(alias string, fn func(transfer.Remote) error) error) *CommandSpec {
+	return &CommandSpec{
+		Name:        "sync",
+		Description: "Mirror a local directory to a remote one or vice versa, transferring only differences",
+		Args: []Arg{
+			{Name: "alias", Description: strings.ToUpper(command) + " session", Complete: complete},
+			{Name: "local", Description: "Local directory", Complete: localPathCompletion},
+			{Name: "remote", Description: "Remote directory", Complete: remotePathCompletion(0)},
+		},
+		Flags: []Flag{
+			{Name: "direction", Kind: StringFlag, Default: string(transfer.Upload), Values: []string{string(transfer.Upload), string(transfer.Download)}, Description: "Which side is the source"},
+			{Name: "delete", Kind: BoolFlag, Description: "Delete destination files that do not exist in the source"},
+			{Name: "force", Kind: BoolFlag, Description: "Allow --delete to empty the destination when the source is empty"},
+			{Name: "checksum", Kind: BoolFlag, Description: "Compare file contents instead of modification times"},
+			{Name: "dry-run", Short: "n", Kind: BoolFlag, Description: "Only print what would be done"},
+			{Name: "include", Kind: ListFlag, Placeholder: "glob", Description: "Only synchronise files matching the pattern"},
+			{Name: "exclude", Kind: ListFlag, Placeholder: "glob", Description: "Skip files and directories matching the pattern"},
+			limitFlag,
+		},
+		Examples: []string{
+			fmt.Sprintf("%s sync prod ./public /var/www --delete --dry-run", command),
+			fmt.Sprintf("%s sync prod ./backup /var/www --direction download", command),
+		},
+		Handler: func(ctx *Context) error {
+			filter, err := treeFilter(ctx)
+			if err != nil {
+				return err
+			}
+			applyLimit, err := limitOverride(ctx)
+			if err != nil {
+				return err
+			}
+			options := transfer.SyncOptions{
+				Direction: transfer.Direction(ctx.String("direction")),
+				Delete:    ctx.Bool("delete"),
+				Force:     ctx.Bool("force"),
+				Checksum:  ctx.Bool("checksum"),
+				Filter:    filter,
+				Tolerance: tolerance,
+			}
+			local, remoteDir := ctx.Arg("local"), ctx.Arg("remote")
+
+			return connect(ctx.Arg("alias"), func(remote transfer.Remote) error {
+				if limited, ok := remote.(rateLimited); ok {
+					applyLimit(limited)
+				}
+				steps, err := transfer.Plan(remote, local, remoteDir, options)
+				if err != nil {
+					return err
+				}
+				if len(steps) == 0 {
+					fmt.Printf("%sAlready in sync.%s\n", utils.Green, utils.Reset)
+					return nil
+				}
+				if ctx.Bool("dry-run") {
+					printSyncPlan(steps)
+					return nil
+				}
+
+				summary := transfer.Apply(remote, local, remoteDir, options.Direction, steps, printSyncStep)
+				fmt.Printf("%s%d copied (%s), %d directories created, %d deleted in %s (%s)%s\n",
+					utils.Cyan,
+					summary.Copied,
+					utils.FormatBytes(summary.Bytes),
+					summary.Created,
+					summary.Deleted,
+					summary.Duration.Round(time.Millisecond),
+					utils.FormatRate(summary.Bytes, summary.Duration),
+					utils.Reset,
+				)
+				if summary.Failed > 0 {
+					return fmt.Errorf("%d of %d step(s) failed", summary.Failed, len(steps))
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func printSyncPlan(steps []transfer.Step) {
+	var bytes int64
+	for _, step := range steps {
+		detail := step.Reason
+		if step.Action == transfer.ActionCopy {
+			bytes += step.Size
+			detail = fmt.Sprintf("%s, %s", step.Reason, utils.FormatBytes(step.Size))
+		}
+		fmt.Printf("%s  %-8s%s %s (%s)\n", syncActionColor(step.Action), step.Action, utils.Reset, step.Path, detail)
+	}
+	fmt.Printf("%s%d step(s), %s to transfer (dry run)%s\n", utils.Cyan, len(steps), utils.FormatBytes(bytes), utils.Reset)
+}
+
+func printSyncStep(step transfer.Step, err error) {
+	if err != nil {
+		fmt.Printf("%s  failed   %s: %v%s\n", utils.Red, step.Path, err, utils.Reset)
+		return
+	}
+	fmt.Printf("%s  %-8s%s %s\n", syncActionColor(step.Action), step.Action, utils.Reset, step.Path)
+}
+
+func syncActionColor(action transfer.Action) string {
+	switch action {
+	case transfer.ActionDelete, transfer.ActionRmdir:
+		return utils.Yellow
+	default:
+		return utils.Green
+	}
+}
@@ -0,0 +1,319 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"servercommander/src/console"
+	"servercommander/src/services"
+	"servercommander/src/services/config"
+	ftpservice "servercommander/src/services/ftp"
+	sftpservice "servercommander/src/services/sftp"
+	sshservice "servercommander/src/services/ssh"
+	"servercommander/src/services/transfer"
+	"servercommander/src/utils"
+)
+
+// transferQueue runs the recursive transfers started in this process.
+var transferQueue = transfer.NewQueue()
+
+func init() {
+	idArg := Arg{Name: "id", Description: "Transfer number shown by 'transfers list'", Complete: transferIDCompletion}
+
+	RegisterCommand(&CommandSpec{
+		Name:        "transfers",
+		Description: "Manage recursive transfers running in the background",
+		Subcommands: []*CommandSpec{
+			{
+				Name:        "list",
+				Description: "Show the progress of recursive transfers",
+				Flags:       []Flag{outputFlag},
+				Handler: func(ctx *Context) error {
+					jobs := transferQueue.Jobs()
+					return utils.Render(ctx.OutputFormat(), jobs, func() error {
+						return printTransferJobs(jobs)
+					})
+				},
+			},
+			{
+				Name:        "pause",
+				Description: "Stop a transfer from starting further files",
+				Args:        []Arg{idArg},
+				Handler: func(ctx *Context) error {
+					return controlTransfer(ctx, "paused", transferQueue.Pause)
+				},
+			},
+			{
+				Name:        "resume",
+				Description: "Continue a paused transfer",
+				Args:        []Arg{idArg},
+				Handler: func(ctx *Context) error {
+					return controlTransfer(ctx, "resumed", transferQueue.Resume)
+				},
+			},
+			{
+				Name:        "cancel",
+				Description: "Abort a transfer; partial files are resumed by the next attempt",
+				Args:        []Arg{idArg},
+				Handler: func(ctx *Context) error {
+					return controlTransfer(ctx, "cancelled", transferQueue.Cancel)
+				},
+			},
+		},
+	})
+}
+
+func controlTransfer(ctx *Context, verb string, action func(int) error) error {
+	id, err := strconv.Atoi(ctx.Arg("id"))
+	if err != nil {
+		return &utils.UsageError{Message: fmt.Sprintf("invalid transfer id '%s'", ctx.Arg("id"))}
+	}
+	if err := action(id); err != nil {
+		return err
+	}
+	fmt.Printf("%sTransfer #%d %s.%s\n", utils.Green, id, verb, utils.Reset)
+	return nil
+}
+
+func transferIDCompletion(_ []string, _ string) []string {
+	ids := []string{}
+	for _, job := range transferQueue.Jobs() {
+		if !job.State.Finished() {
+			ids = append(ids, strconv.Itoa(job.ID))
+		}
+	}
+	return ids
+}
+
+// runTree transfers a directory tree through the transfer queue using a pool
+// of connections. In the interactive console the transfer continues in the
+// background unless --wait is given; elsewhere the command waits, because the
+// process would otherwise exit and abort it.
+func runTree(ctx *Context, protocol config.Protocol, direction transfer.Direction, local, remote string) error {
+	filter, err := treeFilter(ctx)
+	if err != nil {
+		return err
+	}
+	limit, limitSet, err := limitFlagValue(ctx)
+	if err != nil {
+		return err
+	}
+
+	session, err := loadSession(ctx.Arg("alias"))
+	if err != nil {
+		return err
+	}
+	if session.Protocol != protocol {
+		return fmt.Errorf("session '%s' is not configured for %s", session.Alias, strings.ToUpper(string(protocol)))
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	if !limitSet {
+		limit = settings.TransferLimit(session)
+	}
+	connections := ctx.Int("connections")
+	if connections <= 0 {
+		connections = settings.Transfer(protocol).Connections
+	}
+
+	password, err := promptPassword(session)
+	if err != nil {
+		return err
+	}
+	// All connections of the pool share one limiter so the limit applies to
+	// the transfer as a whole.
+	first, connect, err := transferConnector(session, password, transfer.NewLimiter(limit))
+	if err != nil {
+		return err
+	}
+
+	background := console.Interactive() && !ctx.Bool("wait")
+	verb := "uploaded"
+	if direction == transfer.Download {
+		verb = "downloaded"
+	}
+	report := printFileResult(verb)
+	if background {
+		report = logFileResult
+	}
+	job := transferQueue.Submit(transfer.JobSpec{
+		Alias:       session.Alias,
+		Direction:   direction,
+		LocalRoot:   local,
+		RemoteRoot:  remote,
+		Filter:      filter,
+		Connections: connections,
+		Retries:     settings.Transfer(protocol).Retries,
+		Verify:      ctx.Bool("verify") || settings.Transfer(protocol).Verify,
+		Connect:     connect,
+		Report:      report,
+	}, first)
+
+	if background {
+		fmt.Printf("%sTransfer #%d started in the background. Use 'transfers list' to follow it.%s\n", utils.Cyan, job.ID(), utils.Reset)
+		go func() {
+			status := job.Wait()
+			console.Notify(fmt.Sprintf("%s%s%s", transferStateColor(status.State), transferSummary(status), utils.Reset))
+			services.LogToFile(transferSummary(status))
+		}()
+		return nil
+	}
+
+	status := job.Wait()
+	if status.Error != "" {
+		return fmt.Errorf("%s", status.Error)
+	}
+	fmt.Printf("%s%s%s\n", utils.Cyan, transferSummary(status), utils.Reset)
+	services.LogToFile(transferSummary(status))
+	switch {
+	case status.State == transfer.JobCancelled:
+		return fmt.Errorf("transfer #%d was cancelled", status.ID)
+	case status.Failed > 0:
+		return fmt.Errorf("%d of %d file(s) failed", status.Failed, status.Files)
+	}
+	return nil
+}
+
+// transferConnector opens the first connection of a pool and returns a
+// function opening further ones. Prompts for a key passphrase or an unknown
+// certificate therefore happen in the foreground, and the answers are reused
+// by the workers.
+func transferConnector(session config.Session, password string, limiter *transfer.Limiter) (transfer.Conn, func() (transfer.Conn, error), error) {
+	if session.Protocol == config.ProtocolFTP {
+		connect := func() (transfer.Conn, error) {
+			client, err := ftpservice.Connect(session, password)
+			if err != nil {
+				return nil, err
+			}
+			client.SetLimiter(limiter)
+			return ftpRemote{client}, nil
+		}
+		first, err := connect()
+		return first, connect, err
+	}
+
+	wrap := func(conn *sshservice.Client) (transfer.Conn, error) {
+		client, err := sftpservice.NewClient(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		client.SetLimiter(limiter)
+		return sftpRemote{client}, nil
+	}
+	conn, passphrase, err := dialSSHWithRetry(session, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	first, err := wrap(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	connect := func() (transfer.Conn, error) {
+		conn, err := sshservice.Connect(session, password, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return wrap(conn)
+	}
+	return first, connect, nil
+}
+
+// printFileResult returns a callback printing one line per transferred file.
+func printFileResult(verb string) func(transfer.FileResult) {
+	return func(result transfer.FileResult) {
+		details := ""
+		if result.Attempts > 1 {
+			details = fmt.Sprintf(", %d attempts", result.Attempts)
+		}
+		if result.Verified != "" {
+			details = fmt.Sprintf(", %s verified%s", result.Verified, details)
+		}
+		if result.Err != nil {
+			fmt.Printf("%s  failed      %s: %v%s%s\n", utils.Red, result.Path, result.Err, details, utils.Reset)
+			return
+		}
+		fmt.Printf("%s  %-11s%s %s (%s%s)\n", utils.Green, verb, utils.Reset, result.Path, utils.FormatBytes(result.Size), details)
+	}
+}
+
+// logFileResult records failures of background transfers, which must not
+// write into the console prompt.
+func logFileResult(result transfer.FileResult) {
+	if result.Err != nil {
+		services.LogToFile(fmt.Sprintf("transfer of %s failed after %d attempt(s): %v", result.Path, result.Attempts, result.Err))
+	}
+}
+
+func transferSummary(status transfer.JobStatus) string {
+	summary := fmt.Sprintf("Transfer #%d %s: %d of %d file(s), %s in %s (%s)",
+		status.ID,
+		status.State,
+		status.Completed,
+		status.Files,
+		utils.FormatBytes(status.Bytes),
+		status.Elapsed().Round(time.Millisecond),
+		utils.FormatRate(status.Bytes, status.Elapsed()),
+	)
+	if status.Failed > 0 {
+		summary += fmt.Sprintf(", %d failed", status.Failed)
+	}
+	if status.Error != "" {
+		summary += ": " + status.Error
+	}
+	return summary
+}
+
+func transferStateColor(state transfer.JobState) string {
+	switch state {
+	case transfer.JobCompleted:
+		return utils.Green
+	case transfer.JobFailed:
+		return utils.Red
+	case transfer.JobPaused, transfer.JobCancelled:
+		return utils.Yellow
+	default:
+		return utils.Cyan
+	}
+}
+
+func printTransferJobs(jobs []transfer.JobStatus) error {
+	if len(jobs) == 0 {
+		fmt.Println("(no transfers)")
+		return nil
+	}
+
+	fmt.Printf("%s%-4s %-10s %-9s %-13s %-26s %-12s %-6s %s%s\n", utils.Cyan, "ID", "STATE", "DIRECTION", "FILES", "PROGRESS", "RATE", "CONNS", "PATHS", utils.Reset)
+	for _, job := range jobs {
+		files := fmt.Sprintf("%d/%d", job.Completed, job.Files)
+		if job.Failed > 0 {
+			files += fmt.Sprintf(" (%d!)", job.Failed)
+		}
+		progress := utils.FormatBytes(job.Bytes)
+		if job.TotalBytes > 0 {
+			progress = fmt.Sprintf("%s/%s %.0f%%", utils.FormatBytes(job.Bytes), utils.FormatBytes(job.TotalBytes), float64(job.Bytes)/float64(job.TotalBytes)*100)
+		}
+		fmt.Printf("%s%-4d %-10s%s %-9s %-13s %-26s %-12s %-6d %s -> %s\n",
+			transferStateColor(job.State),
+			job.ID,
+			job.State,
+			utils.Reset,
+			job.Direction,
+			files,
+			progress,
+			utils.FormatRate(job.Bytes, job.Elapsed()),
+			job.Connections,
+			job.Source,
+			job.Destination,
+		)
+		if job.Error != "" {
+			fmt.Printf("     %s%s%s\n", utils.Red, job.Error, utils.Reset)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"servercommander/src/services/vault"
+	"servercommander/src/utils"
+)
+
+func init() {
+	RegisterCommand(&CommandSpec{
+		Name:        "vault",
+		Description: "Manage the encrypted credential vault",
+		Subcommands: []*CommandSpec{
+			{
+				Name:        "init",
+				Description: "Create the vault and set its master password",
+				Handler: func(*Context) error {
+					return vaultInit()
+				},
+			},
+			{
+				Name:        "unlock",
+				Description: "Unlock the vault for a limited time",
+				Args: []Arg{{
+					Name:        "minutes",
+					Description: "Minutes until the vault locks again",
+					Optional:    true,
+					Default:     strconv.Itoa(int(vault.DefaultTimeout / time.Minute)),
+				}},
+				Handler: func(ctx *Context) error {
+					minutes, err := strconv.Atoi(ctx.Arg("minutes"))
+					if err != nil || minutes <= 0 {
+						return fmt.Errorf("invalid timeout: %s", ctx.Arg("minutes"))
+					}
+					return vaultUnlock(time.Duration(minutes) * time.Minute)
+				},
+			},
+			{
+				Name:        "lock",
+				Description: "Lock the vault immediately",
+				Handler: func(*Context) error {
+					vault.Lock()
+					fmt.Println(utils.Green, "Vault locked.", utils.Reset)
+					return nil
+				},
+			},
+			{
+				Name:        "set",
+				Description: "Store a secret for a session",
+				Args: []Arg{
+					{Name: "alias", Description: "Session the secret belongs to", Complete: aliasCompletion()},
+					{Name: "kind", Description: "Secret type", Optional: true, Default: string(vault.KindPassword), Values: []string{string(vault.KindPassword), string(vault.KindPassphrase)}},
+				},
+				Handler: func(ctx *Context) error {
+					kind, err := vault.ParseSecretKind(ctx.Arg("kind"))
+					if err != nil {
+						return err
+					}
+					return vaultSet(ctx.Arg("alias"), kind)
+				},
+			},
+			{
+				Name:        "remove",
+				Description: "Delete a stored secret",
+				Args: []Arg{
+					{Name: "alias", Description: "Session the secret belongs to", Complete: aliasCompletion()},
+					{Name: "kind", Description: "Secret type", Optional: true, Default: string(vault.KindPassword), Values: []string{string(vault.KindPassword), string(vault.KindPassphrase)}},
+				},
+				Handler: func(ctx *Context) error {
+					kind, err := vault.ParseSecretKind(ctx.Arg("kind"))
+					if err != nil {
+						return err
+					}
+					if err := vault.Remove(ctx.Arg("alias"), kind); err != nil {
+						return err
+					}
+					fmt.Printf("%sRemoved %s for '%s'.%s\n", utils.Green, kind, strings.ToLower(ctx.Arg("alias")), utils.Reset)
+					return nil
+				},
+			},
+			{
+				Name:        "list",
+				Description: "List the stored secrets without revealing them",
+				Handler: func(*Context) error {
+					return vaultList()
+				},
+			},
+		},
+	})
+}
+
+func vaultInit() error {
+	master, err := utils.PromptPassword("New master password")
+	if err != nil {
+		return err
+	}
+	confirm, err := utils.PromptPassword("Repeat master password")
+	if err != nil {
+		return err
+	}
+	if master != confirm {
+		return errors.New("master passwords do not match")
+	}
+
+	if err := vault.Init(master, vault.DefaultTimeout); err != nil {
+		return err
+	}
+
+	fmt.Printf("%sVault created and unlocked for %s.%s\n", utils.Green, vault.DefaultTimeout, utils.Reset)
+	return nil
+}
+
+func vaultUnlock(timeout time.Duration) error {
+	master, err := utils.PromptPassword("Master password")
+	if err != nil {
+		return err
+	}
+
+	if err := vault.Unlock(master, timeout); err != nil {
+		return err
+	}
+
+	fmt.Printf("%sVault unlocked until %s.%s\n", utils.Green, vault.ExpiresAt().Format(time.Kitchen), utils.Reset)
+	return nil
+}
+
+func vaultSet(alias string, kind vault.SecretKind) error {
+	if !vault.IsUnlocked() {
+		return vault.ErrLocked
+	}
+
+	session, err := loadSession(alias)
+	if err != nil {
+		return err
+	}
+
+	secret, err := utils.PromptPassword(fmt.Sprintf("Enter %s for %s", kind, session.Alias))
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return fmt.Errorf("%s cannot be empty", kind)
+	}
+
+	if err := vault.Set(session.Alias, kind, secret); err != nil {
+		return err
+	}
+
+	fmt.Printf("%sStored %s for '%s'.%s\n", utils.Green, kind, session.Alias, utils.Reset)
+	return nil
+}
+
+func vaultList() error {
+	entries, err := vault.List()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println(utils.Yellow, "The vault is empty.", utils.Reset)
+		return nil
+	}
+
+	fmt.Printf("%s%-20s %-12s%s\n", utils.Cyan, "Alias", "Secret", utils.Reset)
+	for _, entry := range entries {
+		fmt.Printf("%-20s %-12s\n", entry.Alias, entry.Kind)
+	}
+	return nil
+}
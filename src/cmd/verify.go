@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"servercommander/src/services"
+	"servercommander/src/services/config"
+	"servercommander/src/services/transfer"
+	"servercommander/src/utils"
+)
+
+// verifyFlag makes upload and download compare the copy with its source.
+var verifyFlag = Flag{Name: "verify", Kind: BoolFlag, Description: "Compare checksums after the transfer, or sizes when the server cannot hash files"}
+
+// verifiableRemote is a remote that can also be verified.
+type verifiableRemote interface {
+	transfer.Remote
+	transfer.Verifier
+}
+
+// verifyEnabled reports whether a transfer has to be verified, either
+// because of --verify or because config.yaml enables it for the protocol.
+func verifyEnabled(ctx *Context, protocol config.Protocol) (bool, error) {
+	if ctx.Bool("verify") {
+		return true, nil
+	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return false, err
+	}
+	return settings.Transfer(protocol).Verify, nil
+}
+
+// verifyTransferred checks a single transferred file and reports the result.
+func verifyTransferred(remote transfer.Verifier, localPath, remotePath string) error {
+	verification, err := transfer.VerifyFile(remote, localPath, remotePath)
+	if err != nil {
+		return err
+	}
+	if err := verification.Err(); err != nil {
+		services.LogToFile(fmt.Sprintf("verification failed: %v", err))
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	if verification.Method == "size" {
+		fmt.Printf("%sVerified %s by size only; the server cannot compute checksums.%s\n", utils.Yellow, remotePath, utils.Reset)
+		return nil
+	}
+	fmt.Printf("%sVerified %s (%s %s)%s\n", utils.Green, remotePath, verification.Method, verification.Remote, utils.Reset)
+	return nil
+}
+
+// verifyCommand builds the verify subcommand shared by the ftp and sftp
+// commands. connect runs fn with a connected remote for the given alias.
+func verifyCommand(command string, complete CompletionFunc, connect func(alias string, fn func(verifiableRemote) error) error) *CommandSpec {
+	return &CommandSpec{
+		Name:        "verify",
+		Description: "Compare a local file or directory tree with the remote copy using server side checksums",
+		Args: []Arg{
+			{Name: "alias", Description: strings.ToUpper(command) + " session", Complete: complete},
+			{Name: "local", Description: "Local file or directory", Complete: localPathCompletion},
+			{Name: "remote", Description: "Remote file or directory", Complete: remotePathCompletion(0)},
+		},
+		Flags: []Flag{
+			{Name: "include", Kind: ListFlag, Placeholder: "glob", Description: "Only compare files matching the pattern"},
+			{Name: "exclude", Kind: ListFlag, Placeholder: "glob", Description: "Skip files and directories matching the pattern"},
+			outputFlag,
+		},
+		Examples: []string{
+			fmt.Sprintf("%s verify prod ./release.tar.gz /backups/release.tar.gz", command),
+			fmt.Sprintf("%s verify prod ./public /var/www --exclude \"*.log\" --output json", command),
+		},
+		Handler: func(ctx *Context) error {
+			filter, err := treeFilter(ctx)
+			if err != nil {
+				return err
+			}
+			local, remotePath := ctx.Arg("local"), ctx.Arg("remote")
+			info, err := os.Stat(local)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", local, err)
+			}
+
+			return connect(ctx.Arg("alias"), func(remote verifiableRemote) error {
+				var results []transfer.Verification
+				if info.IsDir() {
+					if results, err = transfer.VerifyTree(remote, local, remotePath, filter); err != nil {
+						return err
+					}
+				} else {
+					if strings.HasSuffix(remotePath, "/") {
+						remotePath = path.Join(remotePath, filepath.Base(local))
+					}
+					result, err := transfer.VerifyFile(remote, local, remotePath)
+					if err != nil {
+						return err
+					}
+					results = []transfer.Verification{result}
+				}
+
+				if err := utils.Render(ctx.OutputFormat(), results, func() error {
+					printVerifications(results)
+					return nil
+				}); err != nil {
+					return err
+				}
+
+				// Files that only exist remotely are reported but do not make
+				// the local copy differ.
+				differing := 0
+				for _, result := range results {
+					if result.Status != transfer.VerifyOK && result.Status != transfer.VerifyExtra {
+						differing++
+					}
+				}
+				if differing > 0 {
+					return fmt.Errorf("%d of %d file(s) differ", differing, len(results))
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func printVerifications(results []transfer.Verification) {
+	counts := map[transfer.VerifyStatus]int{}
+	for _, result := range results {
+		counts[result.Status]++
+		detail := ""
+		switch result.Status {
+		case transfer.VerifyOK:
+			detail = result.Method
+			if result.Method == "size" {
+				detail = fmt.Sprintf("size only, %s", utils.FormatBytes(result.LocalSize))
+			}
+		case transfer.VerifyMismatch:
+			detail = strings.TrimPrefix(result.Err().Error(), result.Path+": ")
+		case transfer.VerifyMissing:
+			detail = "not on the server"
+		case transfer.VerifyExtra:
+			detail = "only on the server"
+		case transfer.VerifyError:
+			detail = result.Error
+		}
+		fmt.Printf("%s  %-9s%s %s (%s)\n", verifyStatusColor(result.Status), result.Status, utils.Reset, result.Path, detail)
+	}
+
+	fmt.Printf("%s%d file(s): %d ok, %d mismatched, %d missing, %d extra, %d failed%s\n",
+		utils.Cyan,
+		len(results),
+		counts[transfer.VerifyOK],
+		counts[transfer.VerifyMismatch],
+		counts[transfer.VerifyMissing],
+		counts[transfer.VerifyExtra],
+		counts[transfer.VerifyError],
+		utils.Reset,
+	)
+}
+
+func verifyStatusColor(status transfer.VerifyStatus) string {
+	switch status {
+	case transfer.VerifyOK:
+		return utils.Green
+	case transfer.VerifyExtra:
+		return utils.Yellow
+	default:
+		return utils.Red
+	}
+}
@@ -1,41 +1,105 @@
 package console
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
 	"time"
 
+	"golang.org/x/term"
+
 	"servercommander/src/utils"
 )
 
+// interactive is set while the REPL is running.
+var interactive bool
+
+// activeEditor is the line editor of the running console, nil when STDIN is
+// not a terminal.
+var activeEditor *lineEditor
+
+// Interactive reports whether commands are executed from the interactive
+// console rather than from arguments or a script.
+func Interactive() bool {
+	return interactive
+}
+
 // Run starts the interactive console loop using the provided executor to
-// handle individual command lines.
-func Run(executor func(string) error) error {
+// handle individual command lines. When STDIN is a terminal the line editor is
+// used, otherwise lines are read verbatim. The completer, which may be nil,
+// supplies Tab completion candidates for the text left of the cursor.
+func Run(executor func(string) error, completer func(string) []string) error {
+	interactive = true
 	ApplicationBanner()
 
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Printf("%s>>>%s ", utils.Cyan, utils.Reset)
+	if err := loadHistory(); err != nil {
+		fmt.Println(utils.Yellow, "History unavailable:", err.Error(), utils.Reset)
+	}
+
+	fd := int(os.Stdin.Fd())
+	var editor *lineEditor
+	if term.IsTerminal(fd) {
+		editor = newLineEditor(fd)
+		editor.completer = completer
+		activeEditor = editor
+	}
 
-		if !scanner.Scan() {
+	prompt := fmt.Sprintf("%s>>>%s ", utils.Cyan, utils.Reset)
+	for {
+		line, err := readCommand(editor, prompt)
+		if errors.Is(err, utils.ErrInterrupted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) && line == "" {
 			fmt.Println()
-			return scanner.Err()
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			fmt.Println()
+			return err
 		}
 
-		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
 
+		if editor != nil {
+			if err := AddHistory(line); err != nil {
+				fmt.Println(utils.Yellow, err.Error(), utils.Reset)
+			}
+		}
+
 		if err := executor(line); err != nil {
 			fmt.Println(utils.Red, err.Error(), utils.Reset)
 		}
 	}
 }
 
+// Notify prints a message of a background task, such as a finished transfer.
+// While a command line is being edited the message is printed above it and
+// the prompt is redrawn with the partial input.
+func Notify(message string) {
+	if activeEditor != nil && activeEditor.notify(message) {
+		return
+	}
+	fmt.Println(message)
+}
+
+func readCommand(editor *lineEditor, prompt string) (string, error) {
+	if editor != nil {
+		return editor.ReadLine(prompt)
+	}
+
+	fmt.Print(prompt)
+	// Lines are read through the prompt reader so that commands prompting for
+	// input consume the same buffered STDIN.
+	return utils.ReadLine()
+}
+
 // ApplicationBanner prints the program banner in the console.
 func ApplicationBanner() {
 	fmt.Println(utils.Cyan, "==============================")
@@ -0,0 +1,150 @@
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"servercommander/src/services/config"
+)
+
+// maxHistoryEntries limits the number of commands kept in memory and on disk.
+const maxHistoryEntries = 1000
+
+// History keeps the commands entered in the console. Entries are unique: a
+// repeated command moves to the end instead of being stored twice.
+type History struct {
+	mu      sync.Mutex
+	entries []string
+	path    string
+}
+
+var history = &History{}
+
+// loadHistory reads the persisted history from the configuration directory.
+// Failures leave the history empty so the console remains usable.
+func loadHistory() error {
+	path, err := config.FilePath("history")
+	if err != nil {
+		return err
+	}
+
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	history.path = path
+	history.entries = nil
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to open history file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			history.append(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	return nil
+}
+
+// HistoryEntries returns a copy of the recorded commands, oldest first.
+func HistoryEntries() []string {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	return append([]string(nil), history.entries...)
+}
+
+// AddHistory records a command and persists the history.
+func AddHistory(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	history.append(line)
+	return history.save()
+}
+
+// ReplaceLastHistory swaps the most recent entry for line. The history command
+// uses it so that re-executed commands are recorded instead of the
+// "history <n>" invocation that triggered them.
+func ReplaceLastHistory(line string) error {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	if n := len(history.entries); n > 0 {
+		history.entries = history.entries[:n-1]
+	}
+	history.append(line)
+	return history.save()
+}
+
+// ClearHistory removes all entries from memory and disk.
+func ClearHistory() error {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	history.entries = nil
+	return history.save()
+}
+
+// append must be called with the mutex held.
+func (h *History) append(line string) {
+	for i, entry := range h.entries {
+		if entry == line {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			break
+		}
+	}
+
+	h.entries = append(h.entries, line)
+	if overflow := len(h.entries) - maxHistoryEntries; overflow > 0 {
+		h.entries = h.entries[overflow:]
+	}
+}
+
+// save must be called with the mutex held.
+func (h *History) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	data := strings.Join(h.entries, "\n")
+	if data != "" {
+		data += "\n"
+	}
+
+	if err := os.WriteFile(h.path, []byte(data), 0600); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// search looks for the most recent entry at or before index from that
+// contains query. It returns -1 when nothing matches.
+func (h *History) search(query string, from int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if from >= len(h.entries) {
+		from = len(h.entries) - 1
+	}
+	for i := from; i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return i
+		}
+	}
+	return -1
+}
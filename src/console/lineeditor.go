@@ -0,0 +1,544 @@
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/term"
+
+	"servercommander/src/utils"
+)
+
+// Key codes produced by decodeKey in addition to plain runes.
+const (
+	keyNone = iota
+	keyRune
+	keyEnter
+	keyInterrupt
+	keyEOF
+	keyBackspace
+	keyDelete
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyWordLeft
+	keyWordRight
+	keyDeleteWordLeft
+	keyDeleteWordRight
+	keyKillToEnd
+	keyKillToStart
+	keyClearScreen
+	keySearch
+	keyCancel
+	keyTab
+)
+
+type key struct {
+	code int
+	r    rune
+}
+
+// lineEditor implements an Emacs-style line editor on top of a terminal in
+// raw mode. It supports cursor movement, word-wise editing, history navigation,
+// reverse incremental search and Tab completion.
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+	fd  int
+
+	prompt string
+	line   []rune
+	cursor int
+
+	historyIndex int
+	draft        []rune
+
+	searching   bool
+	query       []rune
+	searchIndex int
+	searchStart []rune
+
+	completer     func(string) []string
+	lastKeyWasTab bool
+
+	// mu guards the editing state against notify, which background tasks
+	// call while a line is being read.
+	mu      sync.Mutex
+	reading bool
+}
+
+func newLineEditor(fd int) *lineEditor {
+	return &lineEditor{
+		in:  bufio.NewReader(os.Stdin),
+		out: os.Stdout,
+		fd:  fd,
+	}
+}
+
+// ReadLine displays the prompt and returns the edited line. It returns
+// utils.ErrInterrupted when the user presses Ctrl+C and io.EOF on Ctrl+D at an
+// empty prompt.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(e.fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to switch terminal to raw mode: %w", err)
+	}
+	defer term.Restore(e.fd, state)
+
+	e.mu.Lock()
+	e.prompt = prompt
+	e.line = e.line[:0]
+	e.cursor = 0
+	e.historyIndex = len(HistoryEntries())
+	e.draft = nil
+	e.searching = false
+	e.lastKeyWasTab = false
+	e.reading = true
+	e.refresh()
+	e.mu.Unlock()
+
+	for {
+		k, err := e.decodeKey()
+
+		e.mu.Lock()
+		line, done, err := e.handleKey(k, err)
+		if done {
+			e.reading = false
+		}
+		e.mu.Unlock()
+
+		if done {
+			return line, err
+		}
+	}
+}
+
+// handleKey applies one key press, or the error of reading it, to the line.
+// The boolean is set once ReadLine has to return the line and error.
+func (e *lineEditor) handleKey(k key, err error) (string, bool, error) {
+	if err != nil {
+		fmt.Fprint(e.out, "\r\n")
+		return "", true, err
+	}
+
+	if e.searching {
+		if finished := e.handleSearchKey(k); !finished {
+			return "", false, nil
+		}
+	}
+
+	tabbed := k.code == keyTab
+	switch k.code {
+	case keyTab:
+		e.complete()
+	case keyEnter:
+		e.cursor = len(e.line)
+		e.refresh()
+		fmt.Fprint(e.out, "\r\n")
+		return string(e.line), true, nil
+	case keyInterrupt:
+		fmt.Fprint(e.out, "^C\r\n")
+		return "", true, utils.ErrInterrupted
+	case keyEOF:
+		if len(e.line) == 0 {
+			fmt.Fprint(e.out, "\r\n")
+			return "", true, io.EOF
+		}
+		e.deleteRange(e.cursor, e.cursor+1)
+	case keyRune:
+		e.insert(k.r)
+	case keyBackspace:
+		e.deleteRange(e.cursor-1, e.cursor)
+	case keyDelete:
+		e.deleteRange(e.cursor, e.cursor+1)
+	case keyLeft:
+		e.moveTo(e.cursor - 1)
+	case keyRight:
+		e.moveTo(e.cursor + 1)
+	case keyHome:
+		e.moveTo(0)
+	case keyEnd:
+		e.moveTo(len(e.line))
+	case keyWordLeft:
+		e.moveTo(e.previousWord())
+	case keyWordRight:
+		e.moveTo(e.nextWord())
+	case keyDeleteWordLeft:
+		e.deleteRange(e.previousWord(), e.cursor)
+	case keyDeleteWordRight:
+		e.deleteRange(e.cursor, e.nextWord())
+	case keyKillToEnd:
+		e.deleteRange(e.cursor, len(e.line))
+	case keyKillToStart:
+		e.deleteRange(0, e.cursor)
+	case keyUp:
+		e.recall(-1)
+	case keyDown:
+		e.recall(1)
+	case keyClearScreen:
+		fmt.Fprint(e.out, "\033[H\033[2J")
+		e.refresh()
+	case keySearch:
+		e.startSearch()
+	}
+	e.lastKeyWasTab = tabbed
+	return "", false, nil
+}
+
+// notify prints message above the line being edited and redraws the prompt
+// below it. It reports false when no line is being read, in which case the
+// caller prints the message itself.
+func (e *lineEditor) notify(message string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.reading {
+		return false
+	}
+
+	// The terminal is in raw mode, so line feeds need a carriage return.
+	message = strings.ReplaceAll(strings.TrimSuffix(message, "\n"), "\n", "\r\n")
+	fmt.Fprintf(e.out, "\r\x1b[K%s\r\n", message)
+	e.refresh()
+	return true
+}
+
+// decodeKey reads one key press, translating control characters and ANSI
+// escape sequences into key codes.
+func (e *lineEditor) decodeKey() (key, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+
+	switch r {
+	case '\r', '\n':
+		return key{code: keyEnter}, nil
+	case 0x01:
+		return key{code: keyHome}, nil
+	case 0x02:
+		return key{code: keyLeft}, nil
+	case 0x03:
+		return key{code: keyInterrupt}, nil
+	case 0x04:
+		return key{code: keyEOF}, nil
+	case 0x05:
+		return key{code: keyEnd}, nil
+	case 0x06:
+		return key{code: keyRight}, nil
+	case 0x07:
+		return key{code: keyCancel}, nil
+	case 0x08, 0x7f:
+		return key{code: keyBackspace}, nil
+	case '\t':
+		return key{code: keyTab}, nil
+	case 0x0b:
+		return key{code: keyKillToEnd}, nil
+	case 0x0c:
+		return key{code: keyClearScreen}, nil
+	case 0x0e:
+		return key{code: keyDown}, nil
+	case 0x10:
+		return key{code: keyUp}, nil
+	case 0x12:
+		return key{code: keySearch}, nil
+	case 0x15:
+		return key{code: keyKillToStart}, nil
+	case 0x17:
+		return key{code: keyDeleteWordLeft}, nil
+	case 0x1b:
+		return e.decodeEscape()
+	}
+
+	if r < 0x20 {
+		return key{code: keyNone}, nil
+	}
+	return key{code: keyRune, r: r}, nil
+}
+
+func (e *lineEditor) decodeEscape() (key, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+
+	switch r {
+	case 'b', 'B':
+		return key{code: keyWordLeft}, nil
+	case 'f', 'F':
+		return key{code: keyWordRight}, nil
+	case 'd', 'D':
+		return key{code: keyDeleteWordRight}, nil
+	case 0x7f, 0x08:
+		return key{code: keyDeleteWordLeft}, nil
+	case '[', 'O':
+	default:
+		return key{code: keyCancel}, nil
+	}
+
+	// Collect the parameters of a CSI/SS3 sequence up to the final byte.
+	params := []rune{}
+	for {
+		next, _, err := e.in.ReadRune()
+		if err != nil {
+			return key{}, err
+		}
+		if next >= 0x40 && next <= 0x7e {
+			return decodeSequence(string(params), next), nil
+		}
+		params = append(params, next)
+	}
+}
+
+func decodeSequence(params string, final rune) key {
+	modified := strings.Contains(params, ";5") || strings.Contains(params, ";3")
+	switch final {
+	case 'A':
+		return key{code: keyUp}
+	case 'B':
+		return key{code: keyDown}
+	case 'C':
+		if modified {
+			return key{code: keyWordRight}
+		}
+		return key{code: keyRight}
+	case 'D':
+		if modified {
+			return key{code: keyWordLeft}
+		}
+		return key{code: keyLeft}
+	case 'H':
+		return key{code: keyHome}
+	case 'F':
+		return key{code: keyEnd}
+	case '~':
+		switch params {
+		case "1", "7":
+			return key{code: keyHome}
+		case "4", "8":
+			return key{code: keyEnd}
+		case "3":
+			return key{code: keyDelete}
+		}
+	}
+	return key{code: keyNone}
+}
+
+func (e *lineEditor) insert(r rune) {
+	e.line = append(e.line, 0)
+	copy(e.line[e.cursor+1:], e.line[e.cursor:])
+	e.line[e.cursor] = r
+	e.cursor++
+	e.refresh()
+}
+
+func (e *lineEditor) deleteRange(start, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(e.line) {
+		end = len(e.line)
+	}
+	if start >= end {
+		return
+	}
+	e.line = append(e.line[:start], e.line[end:]...)
+	e.cursor = start
+	e.refresh()
+}
+
+func (e *lineEditor) moveTo(position int) {
+	if position < 0 || position > len(e.line) || position == e.cursor {
+		return
+	}
+	e.cursor = position
+	e.refresh()
+}
+
+func (e *lineEditor) previousWord() int {
+	i := e.cursor
+	for i > 0 && unicode.IsSpace(e.line[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(e.line[i-1]) {
+		i--
+	}
+	return i
+}
+
+func (e *lineEditor) nextWord() int {
+	i := e.cursor
+	for i < len(e.line) && unicode.IsSpace(e.line[i]) {
+		i++
+	}
+	for i < len(e.line) && !unicode.IsSpace(e.line[i]) {
+		i++
+	}
+	return i
+}
+
+// recall replaces the line with an older (-1) or newer (+1) history entry. The
+// line being edited is kept as draft and restored after the newest entry.
+func (e *lineEditor) recall(direction int) {
+	entries := HistoryEntries()
+	index := e.historyIndex + direction
+	if index < 0 || index > len(entries) {
+		return
+	}
+
+	if e.historyIndex == len(entries) {
+		e.draft = append([]rune(nil), e.line...)
+	}
+
+	e.historyIndex = index
+	if index == len(entries) {
+		e.line = append([]rune(nil), e.draft...)
+	} else {
+		e.line = []rune(entries[index])
+	}
+	e.cursor = len(e.line)
+	e.refresh()
+}
+
+// complete asks the completer for candidates matching the word before the
+// cursor. A single candidate is inserted, several candidates are extended to
+// their longest common prefix and listed when Tab is pressed twice.
+func (e *lineEditor) complete() {
+	if e.completer == nil {
+		return
+	}
+
+	before := string(e.line[:e.cursor])
+	candidates := e.completer(before)
+	if len(candidates) == 0 {
+		return
+	}
+
+	// The word may contain quotes and escapes, so its start is located by the
+	// same tokenizer that splits commands.
+	_, _, offset := utils.SplitCompletionLine(before)
+	start := len([]rune(before[:offset]))
+	word := string(e.line[start:e.cursor])
+
+	if len(candidates) == 1 {
+		replacement := candidates[0]
+		if !strings.HasSuffix(replacement, "/") && !strings.HasSuffix(replacement, `\`) {
+			replacement += " "
+		}
+		e.replaceWord(start, replacement)
+		return
+	}
+
+	if prefix := commonPrefix(candidates); len([]rune(prefix)) > len([]rune(word)) {
+		e.replaceWord(start, prefix)
+		return
+	}
+
+	if e.lastKeyWasTab {
+		fmt.Fprint(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+		e.refresh()
+		return
+	}
+	fmt.Fprint(e.out, "\a")
+}
+
+// replaceWord substitutes the text between start and the cursor.
+func (e *lineEditor) replaceWord(start int, replacement string) {
+	rest := append([]rune(nil), e.line[e.cursor:]...)
+	e.line = append(append(e.line[:start], []rune(replacement)...), rest...)
+	e.cursor = start + len([]rune(replacement))
+	e.refresh()
+}
+
+func commonPrefix(values []string) string {
+	prefix := []rune(values[0])
+	for _, value := range values[1:] {
+		runes := []rune(value)
+		n := 0
+		for n < len(prefix) && n < len(runes) && unicode.ToLower(prefix[n]) == unicode.ToLower(runes[n]) {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
+
+func (e *lineEditor) startSearch() {
+	e.searching = true
+	e.query = e.query[:0]
+	e.searchIndex = len(HistoryEntries())
+	e.searchStart = append([]rune(nil), e.line...)
+	e.refresh()
+}
+
+// handleSearchKey processes a key while reverse incremental search is active.
+// It returns true when the key ended the search and should be handled by the
+// regular editor as well.
+func (e *lineEditor) handleSearchKey(k key) bool {
+	switch k.code {
+	case keyRune:
+		e.query = append(e.query, k.r)
+		e.findMatch(e.searchIndex)
+	case keyBackspace:
+		if len(e.query) > 0 {
+			e.query = e.query[:len(e.query)-1]
+		}
+		e.findMatch(len(HistoryEntries()) - 1)
+	case keySearch:
+		e.findMatch(e.searchIndex - 1)
+	case keyCancel, keyInterrupt:
+		e.searching = false
+		e.line = e.searchStart
+		e.cursor = len(e.line)
+		e.refresh()
+	case keyNone:
+	default:
+		e.searching = false
+		e.cursor = len(e.line)
+		e.refresh()
+		return true
+	}
+	return false
+}
+
+func (e *lineEditor) findMatch(from int) {
+	if len(e.query) == 0 {
+		e.refresh()
+		return
+	}
+
+	if index := history.search(string(e.query), from); index >= 0 {
+		e.searchIndex = index
+		e.line = []rune(HistoryEntries()[index])
+		e.cursor = len(e.line)
+	}
+	e.refresh()
+}
+
+// refresh redraws the prompt and line and places the terminal cursor at the
+// editing position.
+func (e *lineEditor) refresh() {
+	var builder strings.Builder
+	builder.WriteString("\r")
+	if e.searching {
+		fmt.Fprintf(&builder, "(reverse-i-search)`%s': %s", string(e.query), string(e.line))
+		builder.WriteString("\x1b[K")
+		fmt.Fprint(e.out, builder.String())
+		return
+	}
+
+	builder.WriteString(e.prompt)
+	builder.WriteString(string(e.line))
+	builder.WriteString("\x1b[K")
+	if back := len(e.line) - e.cursor; back > 0 {
+		fmt.Fprintf(&builder, "\x1b[%dD", back)
+	}
+	fmt.Fprint(e.out, builder.String())
+}
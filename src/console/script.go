@@ -0,0 +1,62 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"servercommander/src/utils"
+)
+
+// RunScript executes commands line by line without banner or line editor.
+// Empty lines and lines starting with '#' are ignored. The directives
+// "set -e" and "set +e" toggle stopping at the first failing command, which
+// stopOnError enables from the start. readLine returns the next line and
+// io.EOF at the end of the script; name is used in error messages.
+//
+// The returned error is the first command failure, so callers can derive an
+// exit status from it even when the script continued afterwards. A command
+// returning a *utils.ExitError ends the script; unless it carries an explicit
+// status the first failure is returned in its place.
+func RunScript(name string, readLine func() (string, error), executor func(string) error, stopOnError bool) error {
+	var firstErr error
+	for number := 1; ; number++ {
+		line, err := readLine()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		done := errors.Is(err, io.EOF)
+
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case line == "set -e":
+			stopOnError = true
+		case line == "set +e":
+			stopOnError = false
+		default:
+			if cmdErr := executor(line); cmdErr != nil {
+				var exitErr *utils.ExitError
+				if errors.As(cmdErr, &exitErr) {
+					if exitErr.Explicit || firstErr == nil {
+						return exitErr
+					}
+					return firstErr
+				}
+				fmt.Fprintf(os.Stderr, "%s%s:%d: %v%s\n", utils.Red, name, number, cmdErr, utils.Reset)
+				if firstErr == nil {
+					firstErr = cmdErr
+				}
+				if stopOnError {
+					return firstErr
+				}
+			}
+		}
+
+		if done {
+			return firstErr
+		}
+	}
+}
@@ -1,14 +1,105 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"os"
+
+	"golang.org/x/term"
 
 	"servercommander/src/cmd"
 	"servercommander/src/console"
+	"servercommander/src/utils"
+)
+
+// Exit codes reported in non-interactive mode.
+const (
+	exitSuccess = 0
+	exitFailure = 1
+	exitUsage   = 2
 )
 
 func main() {
-	if err := console.Run(cmd.Execute); err != nil {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run selects the mode of operation: a single command given as arguments, a
+// script file (-f), commands piped through STDIN or the interactive console.
+func run(args []string) int {
+	flags := flag.NewFlagSet("servercommander", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: servercommander [-e] [-f script] [command [args...]]")
+		flags.PrintDefaults()
+	}
+	script := flags.String("f", "", "execute commands from a script file ('-' reads STDIN)")
+	stopOnError := flags.Bool("e", false, "stop a script at the first failing command")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSuccess
+		}
+		return exitUsage
+	}
+
+	switch {
+	case *script != "":
+		return runScriptFile(*script, *stopOnError)
+	case flags.NArg() > 0:
+		err := cmd.ExecuteArgs(flags.Args())
+		var exitErr *utils.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			fmt.Fprintln(os.Stderr, utils.Red, err.Error(), utils.Reset)
+		}
+		return exitCode(err)
+	case !term.IsTerminal(int(os.Stdin.Fd())):
+		// Lines are read through the prompt reader so that commands prompting
+		// for input consume the same buffered STDIN.
+		return exitCode(console.RunScript("stdin", utils.ReadLine, cmd.Execute, *stopOnError))
+	}
+
+	if err := console.Run(cmd.Execute, cmd.Complete); err != nil {
 		log.Fatal(err)
 	}
+	return exitSuccess
+}
+
+func runScriptFile(path string, stopOnError bool) int {
+	if path == "-" {
+		return exitCode(console.RunScript("stdin", utils.ReadLine, cmd.Execute, stopOnError))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, utils.Red, fmt.Sprintf("unable to open script: %v", err), utils.Reset)
+		return exitFailure
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	readLine := func() (string, error) {
+		line, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) && line != "" {
+			return line, nil
+		}
+		return line, err
+	}
+	return exitCode(console.RunScript(path, readLine, cmd.Execute, stopOnError))
+}
+
+func exitCode(err error) int {
+	var usageErr *utils.UsageError
+	var exitErr *utils.ExitError
+	switch {
+	case err == nil:
+		return exitSuccess
+	case errors.As(err, &exitErr):
+		return exitErr.Code
+	case errors.As(err, &usageErr):
+		return exitUsage
+	default:
+		return exitFailure
+	}
 }
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ConflictPolicy decides what happens when an imported session uses an alias
+// that already exists in the store.
+type ConflictPolicy string
+
+const (
+	// ConflictMerge keeps the existing session and overrides only the fields
+	// the incoming session sets. Tags are combined.
+	ConflictMerge ConflictPolicy = "merge"
+	// ConflictOverwrite replaces the existing session entirely.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictSkip leaves the existing session untouched.
+	ConflictSkip ConflictPolicy = "skip"
+)
+
+// ParseConflictPolicy validates a user supplied policy name.
+func ParseConflictPolicy(value string) (ConflictPolicy, error) {
+	policy := ConflictPolicy(strings.ToLower(value))
+	switch policy {
+	case ConflictMerge, ConflictOverwrite, ConflictSkip:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy '%s' (expected merge, overwrite or skip)", value)
+	}
+}
+
+// SessionBundle is a portable set of sessions that can be shared between
+// installations. It uses the same layout versioning as the session store.
+type SessionBundle struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Sessions   []Session `json:"sessions"`
+}
+
+// bundleDocument is the raw representation of a bundle used to run
+// migrations before the sessions are decoded.
+type bundleDocument struct {
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exportedAt"`
+	Sessions   []map[string]any `json:"sessions"`
+}
+
+// WriteBundle stores the sessions in a bundle file at path.
+func WriteBundle(path string, sessions []Session) error {
+	bundle := SessionBundle{
+		Version:    SessionsVersion,
+		ExportedAt: time.Now().UTC(),
+		Sessions:   sessions,
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialise session bundle: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session bundle: %w", err)
+	}
+
+	return nil
+}
+
+// ReadBundle loads a bundle file and upgrades it to the current layout.
+func ReadBundle(path string) (*SessionBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read session bundle: %w", err)
+	}
+
+	document := bundleDocument{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid session bundle: %w", err)
+	}
+
+	sessions, err := migrateSessions(document.Version, document.Sessions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session bundle: %w", err)
+	}
+
+	return &SessionBundle{
+		Version:    SessionsVersion,
+		ExportedAt: document.ExportedAt,
+		Sessions:   sessions,
+	}, nil
+}
+
+// Apply adds the session to the store honouring the conflict policy. It
+// returns a short description of the action taken.
+func (s *SessionStore) Apply(session Session, policy ConflictPolicy) string {
+	existing, exists := s.Get(session.Alias)
+	if !exists {
+		s.Upsert(session)
+		return "added"
+	}
+
+	switch policy {
+	case ConflictSkip:
+		return "skipped"
+	case ConflictMerge:
+		s.Upsert(mergeSessions(existing, session))
+		return "merged"
+	default:
+		s.Upsert(session)
+		return "overwritten"
+	}
+}
+
+// mergeSessions overlays the non-empty fields of incoming onto existing.
+func mergeSessions(existing, incoming Session) Session {
+	merged := existing
+	if incoming.Protocol != "" {
+		merged.Protocol = incoming.Protocol
+	}
+	if incoming.Host != "" {
+		merged.Host = incoming.Host
+	}
+	if incoming.Port != 0 {
+		merged.Port = incoming.Port
+	}
+	if incoming.Username != "" {
+		merged.Username = incoming.Username
+	}
+	if incoming.AuthMethod != "" {
+		merged.AuthMethod = incoming.AuthMethod
+		merged.RequiresPass = incoming.RequiresPass
+	}
+	if incoming.KeyPath != "" {
+		merged.KeyPath = incoming.KeyPath
+	}
+	if incoming.ProxyJump != "" {
+		merged.ProxyJump = incoming.ProxyJump
+	}
+	if incoming.TLSMode != "" {
+		merged.TLSMode = incoming.TLSMode
+	}
+	if incoming.CACertPath != "" {
+		merged.CACertPath = incoming.CACertPath
+	}
+	if incoming.DataMode != "" {
+		merged.DataMode = incoming.DataMode
+	}
+	if incoming.MaxTransferSpeed != 0 {
+		merged.MaxTransferSpeed = incoming.MaxTransferSpeed
+	}
+	if incoming.Description != "" {
+		merged.Description = incoming.Description
+	}
+
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, tag := range append(append([]string{}, existing.Tags...), incoming.Tags...) {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	merged.Tags = tags
+	if len(tags) == 0 {
+		merged.Tags = nil
+	}
+
+	return merged
+}
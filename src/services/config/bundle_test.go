@@ -0,0 +1,128 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	existing := Session{
+		Alias:        "web",
+		Protocol:     ProtocolSSH,
+		Host:         "web.example.com",
+		Port:         22,
+		Username:     "deploy",
+		AuthMethod:   AuthPrivateKey,
+		KeyPath:      "~/.ssh/web",
+		Description:  "production",
+		Tags:         []string{"prod", "web"},
+		RequiresPass: false,
+	}
+
+	tests := []struct {
+		name     string
+		incoming Session
+		policy   ConflictPolicy
+		action   string
+		want     Session
+	}{
+		{
+			name:     "new alias",
+			incoming: Session{Alias: "DB", Host: "db"},
+			policy:   ConflictSkip,
+			action:   "added",
+			want:     Session{Alias: "db", Host: "db"},
+		},
+		{
+			name:     "skip",
+			incoming: Session{Alias: "web", Host: "other"},
+			policy:   ConflictSkip,
+			action:   "skipped",
+			want:     existing,
+		},
+		{
+			name:     "overwrite",
+			incoming: Session{Alias: "web", Host: "other", Port: 2222},
+			policy:   ConflictOverwrite,
+			action:   "overwritten",
+			want:     Session{Alias: "web", Host: "other", Port: 2222},
+		},
+		{
+			name:     "merge non-empty fields",
+			incoming: Session{Alias: "web", Port: 2222, AuthMethod: AuthPassword, RequiresPass: true, Tags: []string{"web", "eu"}},
+			policy:   ConflictMerge,
+			action:   "merged",
+			want: Session{
+				Alias:        "web",
+				Protocol:     ProtocolSSH,
+				Host:         "web.example.com",
+				Port:         2222,
+				Username:     "deploy",
+				AuthMethod:   AuthPassword,
+				KeyPath:      "~/.ssh/web",
+				Description:  "production",
+				Tags:         []string{"prod", "web", "eu"},
+				RequiresPass: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &SessionStore{Sessions: map[string]Session{"web": existing}}
+
+			if action := store.Apply(tt.incoming, tt.policy); action != tt.action {
+				t.Errorf("Apply = %q, want %q", action, tt.action)
+			}
+			got, _ := store.Get(tt.want.Alias)
+			got.CreatedAt, got.UpdatedAt = tt.want.CreatedAt, tt.want.UpdatedAt
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("session =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	tests := []struct {
+		value string
+		want  ConflictPolicy
+		ok    bool
+	}{
+		{value: "merge", want: ConflictMerge, ok: true},
+		{value: "Overwrite", want: ConflictOverwrite, ok: true},
+		{value: "SKIP", want: ConflictSkip, ok: true},
+		{value: "replace", ok: false},
+		{value: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseConflictPolicy(tt.value)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseConflictPolicy(%q) = %q, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	sessions := []Session{
+		{Alias: "files", Protocol: ProtocolFTP, Host: "files", Port: 990, AuthMethod: AuthPassword, TLSMode: TLSModeImplicit, RequiresPass: true},
+		{Alias: "web", Protocol: ProtocolSSH, Host: "web", Port: 22, AuthMethod: AuthPrivateKey, KeyPath: "~/.ssh/web", Tags: []string{"prod"}},
+	}
+
+	if err := WriteBundle(path, sessions); err != nil {
+		t.Fatalf("WriteBundle failed: %v", err)
+	}
+	bundle, err := ReadBundle(path)
+	if err != nil {
+		t.Fatalf("ReadBundle failed: %v", err)
+	}
+	if bundle.Version != SessionsVersion || bundle.ExportedAt.IsZero() {
+		t.Errorf("bundle version = %d, exported at %v", bundle.Version, bundle.ExportedAt)
+	}
+	if !reflect.DeepEqual(bundle.Sessions, sessions) {
+		t.Errorf("sessions =\n%+v\nwant\n%+v", bundle.Sessions, sessions)
+	}
+}
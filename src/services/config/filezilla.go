@@ -0,0 +1,188 @@
+package config
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// filezillaServer mirrors the <Server> element of FileZilla's
+// sitemanager.xml.
+type filezillaServer struct {
+	Host      string `xml:"Host"`
+	Port      string `xml:"Port"`
+	Protocol  string `xml:"Protocol"`
+	User      string `xml:"User"`
+	Pass      string `xml:"Pass"`
+	Logontype string `xml:"Logontype"`
+	Keyfile   string `xml:"Keyfile"`
+	PasvMode  string `xml:"PasvMode"`
+	Name      string `xml:"Name"`
+	Comments  string `xml:"Comments"`
+	LocalDir  string `xml:"LocalDir"`
+	RemoteDir string `xml:"RemoteDir"`
+}
+
+// ParseFileZilla reads a FileZilla sitemanager.xml export. Folders are mapped
+// onto session tags. The second return value lists sites that use a protocol
+// ServerCommander cannot handle.
+func ParseFileZilla(path string) ([]ImportedSession, []string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open FileZilla site manager %s: %w", path, err)
+	}
+	defer file.Close()
+
+	decoder := xml.NewDecoder(file)
+	sessions := []ImportedSession{}
+	skipped := []string{}
+	folders := []string{}
+	// pendingFolder is true between a <Folder> start tag and its name, which is
+	// stored as the first character data inside the element.
+	pendingFolder := false
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid FileZilla site manager %s: %w", path, err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "Folder":
+				folders = append(folders, "")
+				pendingFolder = true
+			case "Server":
+				pendingFolder = false
+				var server filezillaServer
+				if err := decoder.DecodeElement(&server, &element); err != nil {
+					return nil, nil, fmt.Errorf("invalid FileZilla site in %s: %w", path, err)
+				}
+				source := strings.Join(append(append([]string{}, folders...), server.Name), "/")
+				imported, ok := mapFileZillaServer(server, folders)
+				if !ok {
+					skipped = append(skipped, fmt.Sprintf("%s (unsupported protocol %s)", source, server.Protocol))
+					continue
+				}
+				imported.Source = source
+				sessions = append(sessions, imported)
+			}
+		case xml.CharData:
+			if pendingFolder {
+				if name := strings.TrimSpace(string(element)); name != "" {
+					folders[len(folders)-1] = name
+					pendingFolder = false
+				}
+			}
+		case xml.EndElement:
+			if element.Name.Local == "Folder" && len(folders) > 0 {
+				folders = folders[:len(folders)-1]
+				pendingFolder = false
+			}
+		}
+	}
+
+	uniqueAliases(sessions)
+	return sessions, skipped, nil
+}
+
+func mapFileZillaServer(server filezillaServer, folders []string) (ImportedSession, bool) {
+	unmapped := []string{}
+	session := Session{
+		Alias:       importAlias(server.Name),
+		Host:        strings.TrimSpace(server.Host),
+		Username:    strings.TrimSpace(server.User),
+		AuthMethod:  AuthPassword,
+		Description: strings.TrimSpace(server.Comments),
+	}
+
+	// Protocol values as defined by FileZilla's ServerProtocol enum.
+	switch strings.TrimSpace(server.Protocol) {
+	case "", "0":
+		session.Protocol = ProtocolFTP
+		unmapped = append(unmapped, "opportunistic TLS (imported as plain FTP)")
+	case "6":
+		session.Protocol = ProtocolFTP
+	case "4":
+		session.Protocol = ProtocolFTP
+		session.TLSMode = TLSModeExplicit
+	case "3":
+		session.Protocol = ProtocolFTP
+		session.TLSMode = TLSModeImplicit
+	case "1":
+		session.Protocol = ProtocolSFTP
+	default:
+		return ImportedSession{}, false
+	}
+
+	session.Port = defaultImportPort(session.Protocol)
+	if session.TLSMode == TLSModeImplicit {
+		session.Port = 990
+	}
+	if port, err := strconv.Atoi(strings.TrimSpace(server.Port)); err == nil && port > 0 {
+		session.Port = port
+	}
+
+	// Logon types: 0 anonymous, 1 normal, 2 ask, 3 interactive, 4 account,
+	// 5 key file.
+	switch strings.TrimSpace(server.Logontype) {
+	case "0":
+		session.Username = "anonymous"
+	case "4":
+		unmapped = append(unmapped, "account logon")
+	case "5":
+		if session.Protocol != ProtocolFTP && server.Keyfile != "" {
+			session.AuthMethod = AuthPrivateKey
+			session.KeyPath = server.Keyfile
+		}
+	}
+
+	if session.Protocol != ProtocolFTP && session.AuthMethod != AuthPrivateKey && server.Keyfile != "" {
+		unmapped = append(unmapped, "key file "+server.Keyfile)
+	}
+	if strings.HasSuffix(strings.ToLower(session.KeyPath), ".ppk") {
+		unmapped = append(unmapped, "PuTTY key format (convert the key to OpenSSH format)")
+	}
+	if server.Pass != "" {
+		unmapped = append(unmapped, "stored password")
+	}
+	switch mode := strings.TrimSpace(server.PasvMode); mode {
+	case "", "MODE_DEFAULT":
+	case "MODE_ACTIVE":
+		session.DataMode = DataModeActive
+	case "MODE_PASSIVE":
+		session.DataMode = DataModePassive
+	default:
+		unmapped = append(unmapped, "transfer mode "+mode)
+	}
+	if dir := strings.TrimSpace(server.RemoteDir); dir != "" {
+		unmapped = append(unmapped, "remote directory")
+	}
+	if dir := strings.TrimSpace(server.LocalDir); dir != "" {
+		unmapped = append(unmapped, "local directory "+dir)
+	}
+
+	for _, folder := range folders {
+		if tag := importAlias(folder); tag != "" {
+			session.Tags = append(session.Tags, tag)
+		}
+	}
+
+	session.RequiresPass = session.AuthMethod == AuthPassword
+	return ImportedSession{Session: session, Unmapped: unmapped}, true
+}
+
+func defaultImportPort(protocol Protocol) int {
+	if protocol == ProtocolFTP {
+		return 21
+	}
+	return 22
+}
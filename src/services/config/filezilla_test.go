@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFileZilla(t *testing.T) {
+	tests := []struct {
+		name     string
+		servers  string
+		want     Session
+		source   string
+		unmapped []string
+		skipped  []string
+	}{
+		{
+			name:    "sftp with key",
+			servers: `<Server><Host>web.example.com</Host><Port>2222</Port><Protocol>1</Protocol><Logontype>5</Logontype><User>deploy</User><Keyfile>/home/me/.ssh/id</Keyfile><Name>Web Server</Name><Comments>prod</Comments></Server>`,
+			want:    Session{Alias: "web-server", Protocol: ProtocolSFTP, Host: "web.example.com", Port: 2222, Username: "deploy", AuthMethod: AuthPrivateKey, KeyPath: "/home/me/.ssh/id", Description: "prod"},
+			source:  "Web Server",
+		},
+		{
+			name:     "plain ftp with stored password",
+			servers:  `<Server><Host>files</Host><Protocol>6</Protocol><Logontype>1</Logontype><User>u</User><Pass encoding="base64">cA==</Pass><Name>files</Name></Server>`,
+			want:     Session{Alias: "files", Protocol: ProtocolFTP, Host: "files", Port: 21, Username: "u", AuthMethod: AuthPassword, RequiresPass: true},
+			source:   "files",
+			unmapped: []string{"stored password"},
+		},
+		{
+			name:     "anonymous with opportunistic tls",
+			servers:  `<Server><Host>mirror</Host><Protocol>0</Protocol><Logontype>0</Logontype><Name>mirror</Name></Server>`,
+			want:     Session{Alias: "mirror", Protocol: ProtocolFTP, Host: "mirror", Port: 21, Username: "anonymous", AuthMethod: AuthPassword, RequiresPass: true},
+			source:   "mirror",
+			unmapped: []string{"opportunistic TLS (imported as plain FTP)"},
+		},
+		{
+			name:     "folders become tags",
+			servers:  `<Folder expanded="1">Customers<Folder>ACME Corp<Server><Host>acme</Host><Protocol>1</Protocol><User>root</User><Keyfile>C:\keys\acme.ppk</Keyfile><Logontype>5</Logontype><Name>acme</Name><RemoteDir>1 0 4 home</RemoteDir></Server></Folder></Folder>`,
+			want:     Session{Alias: "acme", Protocol: ProtocolSFTP, Host: "acme", Port: 22, Username: "root", AuthMethod: AuthPrivateKey, KeyPath: `C:\keys\acme.ppk`, Tags: []string{"customers", "acme-corp"}},
+			source:   "Customers/ACME Corp/acme",
+			unmapped: []string{"PuTTY key format (convert the key to OpenSSH format)", "remote directory"},
+		},
+		{
+			name:     "key file without key logon",
+			servers:  `<Server><Host>h</Host><Protocol>1</Protocol><Logontype>2</Logontype><Keyfile>/k</Keyfile><LocalDir>/tmp</LocalDir><Name>h</Name></Server>`,
+			want:     Session{Alias: "h", Protocol: ProtocolSFTP, Host: "h", Port: 22, AuthMethod: AuthPassword, RequiresPass: true},
+			source:   "h",
+			unmapped: []string{"key file /k", "local directory /tmp"},
+		},
+		{
+			name:    "active mode",
+			servers: `<Server><Host>files</Host><Protocol>6</Protocol><User>u</User><PasvMode>MODE_ACTIVE</PasvMode><Name>files</Name></Server>`,
+			want:    Session{Alias: "files", Protocol: ProtocolFTP, Host: "files", Port: 21, Username: "u", AuthMethod: AuthPassword, DataMode: DataModeActive, RequiresPass: true},
+			source:  "files",
+		},
+		{
+			name:     "unknown transfer mode",
+			servers:  `<Server><Host>files</Host><Protocol>6</Protocol><User>u</User><PasvMode>MODE_FANCY</PasvMode><Name>files</Name></Server>`,
+			want:     Session{Alias: "files", Protocol: ProtocolFTP, Host: "files", Port: 21, Username: "u", AuthMethod: AuthPassword, RequiresPass: true},
+			source:   "files",
+			unmapped: []string{"transfer mode MODE_FANCY"},
+		},
+		{
+			name:    "explicit tls",
+			servers: `<Server><Host>files</Host><Protocol>4</Protocol><User>u</User><Name>files</Name></Server>`,
+			want:    Session{Alias: "files", Protocol: ProtocolFTP, Host: "files", Port: 21, Username: "u", AuthMethod: AuthPassword, TLSMode: TLSModeExplicit, RequiresPass: true},
+			source:  "files",
+		},
+		{
+			name:    "implicit tls",
+			servers: `<Server><Host>files</Host><Protocol>3</Protocol><User>u</User><Name>files</Name></Server>`,
+			want:    Session{Alias: "files", Protocol: ProtocolFTP, Host: "files", Port: 990, Username: "u", AuthMethod: AuthPassword, TLSMode: TLSModeImplicit, RequiresPass: true},
+			source:  "files",
+		},
+		{
+			name:    "unsupported protocol",
+			servers: `<Folder>Cloud<Server><Host>bucket</Host><Protocol>11</Protocol><Name>s3</Name></Server></Folder>`,
+			skipped: []string{"Cloud/s3 (unsupported protocol 11)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sitemanager.xml")
+			content := `<?xml version="1.0" encoding="UTF-8"?><FileZilla3><Servers>` + tt.servers + `</Servers></FileZilla3>`
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			sessions, skipped, err := ParseFileZilla(path)
+			if err != nil {
+				t.Fatalf("ParseFileZilla failed: %v", err)
+			}
+			if !reflect.DeepEqual(skipped, append([]string{}, tt.skipped...)) {
+				t.Errorf("skipped = %q, want %q", skipped, tt.skipped)
+			}
+			if tt.skipped != nil {
+				if len(sessions) != 0 {
+					t.Errorf("imported %d sessions from an unsupported site", len(sessions))
+				}
+				return
+			}
+			if len(sessions) != 1 {
+				t.Fatalf("imported %d sessions, want 1", len(sessions))
+			}
+			if got := sessions[0]; !reflect.DeepEqual(got.Session, tt.want) || got.Source != tt.source || !reflect.DeepEqual(got.Unmapped, append([]string{}, tt.unmapped...)) {
+				t.Errorf("imported\n%+v\n%q %q\nwant\n%+v\n%q %q", got.Session, got.Source, got.Unmapped, tt.want, tt.source, tt.unmapped)
+			}
+		})
+	}
+}
+
+func TestParseFileZillaInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sitemanager.xml")
+	if err := os.WriteFile(path, []byte("<FileZilla3><Servers><Server>"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ParseFileZilla(path); err == nil || !strings.Contains(err.Error(), "invalid FileZilla") {
+		t.Errorf("ParseFileZilla error = %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ImportedSession couples a session produced by one of the importers with the
+// location it originates from and the settings that could not be represented
+// by Session.
+type ImportedSession struct {
+	Session  Session
+	Source   string
+	Unmapped []string
+}
+
+// importAlias turns a free-form site name into an alias that is convenient to
+// type on the console.
+func importAlias(name string) string {
+	var builder strings.Builder
+	lastDash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.':
+			builder.WriteRune(r)
+			lastDash = false
+		case !lastDash && builder.Len() > 0:
+			builder.WriteRune('-')
+			lastDash = true
+		}
+	}
+	return strings.TrimSuffix(builder.String(), "-")
+}
+
+// uniqueAliases appends numeric suffixes to aliases that occur more than once
+// within a single import so no entry silently replaces another.
+func uniqueAliases(sessions []ImportedSession) {
+	seen := map[string]bool{}
+	for i := range sessions {
+		base := sessions[i].Session.Alias
+		if base == "" {
+			base = "imported"
+		}
+		// A suffixed alias may itself occur in the import, so the suffix is
+		// increased until the alias is unused.
+		alias := base
+		for n := 2; seen[strings.ToLower(alias)]; n++ {
+			alias = fmt.Sprintf("%s-%d", base, n)
+		}
+		seen[strings.ToLower(alias)] = true
+		sessions[i].Session.Alias = alias
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImportAlias(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "web", want: "web"},
+		{name: "  Production Web  ", want: "production-web"},
+		{name: "db #1 (primary)", want: "db-1-primary"},
+		{name: "files_eu.example.com", want: "files_eu.example.com"},
+		{name: "--odd--name--", want: "odd-name"},
+		{name: "Bücher", want: "bücher"},
+		{name: "!!!", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := importAlias(tt.name); got != tt.want {
+			t.Errorf("importAlias(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueAliases(t *testing.T) {
+	tests := []struct {
+		aliases []string
+		want    []string
+	}{
+		{aliases: []string{"a", "b"}, want: []string{"a", "b"}},
+		{aliases: []string{"a", "a", "a"}, want: []string{"a", "a-2", "a-3"}},
+		{aliases: []string{"", ""}, want: []string{"imported", "imported-2"}},
+		{aliases: []string{"a", "a-2", "a"}, want: []string{"a", "a-2", "a-3"}},
+		{aliases: []string{"Web", "web"}, want: []string{"Web", "web-2"}},
+	}
+
+	for _, tt := range tests {
+		sessions := make([]ImportedSession, len(tt.aliases))
+		for i, alias := range tt.aliases {
+			sessions[i].Session.Alias = alias
+		}
+		uniqueAliases(sessions)
+
+		got := make([]string, len(sessions))
+		for i, session := range sessions {
+			got[i] = session.Session.Alias
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("uniqueAliases(%q) = %q, want %q", tt.aliases, got, tt.want)
+		}
+	}
+}
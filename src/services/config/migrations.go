@@ -0,0 +1,92 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SessionsVersion is the layout version written by this build. It applies to
+// the session store as well as to exported session bundles.
+const SessionsVersion = 2
+
+// sessionMigration upgrades a single decoded session document by exactly one
+// version. sessionMigrations[n] upgrades documents from version n to n+1.
+type sessionMigration func(session map[string]any) error
+
+var sessionMigrations = []sessionMigration{
+	migrateSessionV0,
+	migrateSessionV1,
+}
+
+// migrateSessions upgrades raw session documents from the given version to
+// SessionsVersion and decodes them into Session values.
+func migrateSessions(version int, documents []map[string]any) ([]Session, error) {
+	if version > SessionsVersion {
+		return nil, fmt.Errorf("sessions were written by a newer version of ServerCommander (layout %d, supported %d)", version, SessionsVersion)
+	}
+	if version < 0 {
+		return nil, fmt.Errorf("invalid sessions layout version %d", version)
+	}
+
+	sessions := make([]Session, 0, len(documents))
+	for _, document := range documents {
+		for step := version; step < SessionsVersion; step++ {
+			if err := sessionMigrations[step](document); err != nil {
+				return nil, fmt.Errorf("failed to upgrade session %v to layout %d: %w", document["alias"], step+1, err)
+			}
+		}
+
+		data, err := json.Marshal(document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upgrade session %v: %w", document["alias"], err)
+		}
+
+		var session Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			return nil, fmt.Errorf("invalid session %v: %w", document["alias"], err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// migrateSessionV0 upgrades the unversioned layout written before the version
+// field existed. Those files could contain mixed-case aliases and omitted the
+// port or authentication method when they were edited by hand.
+func migrateSessionV0(session map[string]any) error {
+	alias, _ := session["alias"].(string)
+	if alias == "" {
+		return fmt.Errorf("missing alias")
+	}
+	session["alias"] = strings.ToLower(alias)
+
+	protocol, _ := session["protocol"].(string)
+	if port, _ := session["port"].(float64); port == 0 {
+		session["port"] = defaultImportPort(Protocol(protocol))
+	}
+
+	if method, _ := session["authMethod"].(string); method == "" {
+		session["authMethod"] = string(AuthPassword)
+		if key, _ := session["keyPath"].(string); key != "" && Protocol(protocol) != ProtocolFTP {
+			session["authMethod"] = string(AuthPrivateKey)
+		}
+	}
+
+	if _, ok := session["requiresPass"]; !ok {
+		session["requiresPass"] = session["authMethod"] == string(AuthPassword)
+	}
+
+	return nil
+}
+
+// migrateSessionV1 replaces the useTls flag of FTP sessions with the TLS mode
+// introduced together with implicit FTPS support.
+func migrateSessionV1(session map[string]any) error {
+	if useTLS, _ := session["useTls"].(bool); useTLS {
+		session["tlsMode"] = string(TLSModeExplicit)
+	}
+	delete(session, "useTls")
+	return nil
+}
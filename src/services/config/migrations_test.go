@@ -0,0 +1,119 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateSessions(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  int
+		document string
+		want     Session
+		err      string
+	}{
+		{
+			name:     "v0 defaults",
+			version:  0,
+			document: `{"alias":"Web","protocol":"ssh","host":"web.example.com","username":"deploy"}`,
+			want:     Session{Alias: "web", Protocol: ProtocolSSH, Host: "web.example.com", Port: 22, Username: "deploy", AuthMethod: AuthPassword, RequiresPass: true},
+		},
+		{
+			name:     "v0 key implies key authentication",
+			version:  0,
+			document: `{"alias":"db","protocol":"sftp","host":"db","port":2222,"keyPath":"~/.ssh/id"}`,
+			want:     Session{Alias: "db", Protocol: ProtocolSFTP, Host: "db", Port: 2222, AuthMethod: AuthPrivateKey, KeyPath: "~/.ssh/id"},
+		},
+		{
+			name:     "v0 ftp with tls",
+			version:  0,
+			document: `{"alias":"files","protocol":"ftp","host":"files","keyPath":"ca.pem","useTls":true}`,
+			want:     Session{Alias: "files", Protocol: ProtocolFTP, Host: "files", Port: 21, AuthMethod: AuthPassword, KeyPath: "ca.pem", TLSMode: TLSModeExplicit, RequiresPass: true},
+		},
+		{
+			name:     "v0 keeps explicit values",
+			version:  0,
+			document: `{"alias":"a","protocol":"ssh","host":"a","port":2200,"authMethod":"agent","requiresPass":false}`,
+			want:     Session{Alias: "a", Protocol: ProtocolSSH, Host: "a", Port: 2200, AuthMethod: AuthMethod("agent")},
+		},
+		{
+			name:     "v1 plain ftp",
+			version:  1,
+			document: `{"alias":"files","protocol":"ftp","host":"files","port":21,"authMethod":"password","useTls":false,"requiresPass":true}`,
+			want:     Session{Alias: "files", Protocol: ProtocolFTP, Host: "files", Port: 21, AuthMethod: AuthPassword, RequiresPass: true},
+		},
+		{
+			name:     "current layout unchanged",
+			version:  SessionsVersion,
+			document: `{"alias":"Mixed","protocol":"ftp","host":"files","port":990,"authMethod":"password","tlsMode":"implicit"}`,
+			want:     Session{Alias: "Mixed", Protocol: ProtocolFTP, Host: "files", Port: 990, AuthMethod: AuthPassword, TLSMode: TLSModeImplicit},
+		},
+		{name: "v0 without alias", version: 0, document: `{"protocol":"ssh"}`, err: "missing alias"},
+		{name: "newer layout", version: SessionsVersion + 1, document: `{"alias":"a"}`, err: "newer version"},
+		{name: "negative layout", version: -1, document: `{"alias":"a"}`, err: "invalid sessions layout"},
+		{name: "invalid field", version: SessionsVersion, document: `{"alias":"a","port":"22"}`, err: "invalid session a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document := map[string]any{}
+			if err := json.Unmarshal([]byte(tt.document), &document); err != nil {
+				t.Fatal(err)
+			}
+
+			sessions, err := migrateSessions(tt.version, []map[string]any{document})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("migrateSessions error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("migrateSessions failed: %v", err)
+			}
+			if len(sessions) != 1 || !reflect.DeepEqual(sessions[0], tt.want) {
+				t.Errorf("migrateSessions =\n%+v\nwant\n%+v", sessions, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSessionsUpgradesOldLayout(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, err := sessionsFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := []byte(`{"sessions":{"Web":{"protocol":"ssh","host":"web"}}}`)
+	if err := os.WriteFile(path, original, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := LoadSessions()
+	if err != nil {
+		t.Fatalf("LoadSessions failed: %v", err)
+	}
+	session, ok := store.Get("web")
+	if !ok || session.Port != 22 || session.AuthMethod != AuthPassword {
+		t.Errorf("upgraded session = %+v, %v", session, ok)
+	}
+
+	backup, err := os.ReadFile(filepath.Join(filepath.Dir(path), "sessions.json.v0.bak"))
+	if err != nil || string(backup) != string(original) {
+		t.Errorf("backup = %q, %v, want the original file", backup, err)
+	}
+
+	var saved struct{ Version int }
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &saved)
+	}
+	if err != nil || saved.Version != SessionsVersion {
+		t.Errorf("saved version = %d, %v, want %d", saved.Version, err, SessionsVersion)
+	}
+}
@@ -24,9 +24,15 @@ func configRoot() (string, error) {
 }
 
 func sessionsFile() (string, error) {
+	return FilePath("sessions.json")
+}
+
+// FilePath returns the location of a file inside the configuration directory.
+// It allows other services to keep their state next to the session store.
+func FilePath(name string) (string, error) {
 	root, err := configRoot()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(root, "sessions.json"), nil
+	return filepath.Join(root, name), nil
 }
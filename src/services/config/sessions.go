@@ -27,29 +27,76 @@ const (
 	AuthPrivateKey AuthMethod = "private_key"
 )
 
+// TLSMode selects how an FTP session is protected.
+type TLSMode string
+
+const (
+	// TLSModeNone uses plain FTP. It is the default when a session sets no
+	// mode.
+	TLSModeNone TLSMode = "none"
+	// TLSModeExplicit upgrades the connection with AUTH TLS after the
+	// greeting, usually on port 21.
+	TLSModeExplicit TLSMode = "explicit"
+	// TLSModeImplicit starts the TLS handshake immediately after connecting,
+	// usually on port 990.
+	TLSModeImplicit TLSMode = "implicit"
+)
+
+// UsesTLS reports whether the mode protects the connection.
+func (m TLSMode) UsesTLS() bool {
+	return m == TLSModeExplicit || m == TLSModeImplicit
+}
+
+// DataMode selects how an FTP session opens data connections.
+type DataMode string
+
+const (
+	// DataModePassive lets the client connect to the server using EPSV with
+	// a fallback to PASV. It is the default when a session sets no mode.
+	DataModePassive DataMode = "passive"
+	// DataModeActive makes the server connect back to a local listener
+	// announced with EPRT or PORT.
+	DataModeActive DataMode = "active"
+)
+
 // Session contains the metadata needed to establish a remote connection. The
 // struct deliberately omits secret material such as passwords. These must be
 // provided at runtime to avoid storing sensitive data on disk.
 type Session struct {
-	Alias        string     `json:"alias"`
-	Protocol     Protocol   `json:"protocol"`
-	Host         string     `json:"host"`
-	Port         int        `json:"port"`
-	Username     string     `json:"username"`
-	AuthMethod   AuthMethod `json:"authMethod"`
-	KeyPath      string     `json:"keyPath,omitempty"`
-	UseTLS       bool       `json:"useTls,omitempty"`
-	Description  string     `json:"description,omitempty"`
-	RequiresPass bool       `json:"requiresPass"`
-	CreatedAt    time.Time  `json:"createdAt"`
-	UpdatedAt    time.Time  `json:"updatedAt"`
+	Alias      string     `json:"alias" yaml:"alias"`
+	Protocol   Protocol   `json:"protocol" yaml:"protocol"`
+	Host       string     `json:"host" yaml:"host"`
+	Port       int        `json:"port" yaml:"port"`
+	Username   string     `json:"username" yaml:"username"`
+	AuthMethod AuthMethod `json:"authMethod" yaml:"authMethod"`
+	KeyPath    string     `json:"keyPath,omitempty" yaml:"keyPath,omitempty"`
+	ProxyJump  string     `json:"proxyJump,omitempty" yaml:"proxyJump,omitempty"`
+	TLSMode    TLSMode    `json:"tlsMode,omitempty" yaml:"tlsMode,omitempty"`
+	CACertPath string     `json:"caCertPath,omitempty" yaml:"caCertPath,omitempty"`
+	DataMode   DataMode   `json:"dataMode,omitempty" yaml:"dataMode,omitempty"`
+	// MaxTransferSpeed limits file transfers in KB/s and overrides the
+	// protocol wide setting of config.yaml.
+	MaxTransferSpeed int64     `json:"maxTransferSpeed,omitempty" yaml:"maxTransferSpeed,omitempty"`
+	Description      string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags             []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	RequiresPass     bool      `json:"requiresPass" yaml:"requiresPass"`
+	CreatedAt        time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt" yaml:"updatedAt"`
 }
 
 // SessionStore provides CRUD operations for session definitions.
 type SessionStore struct {
+	Version  int                `json:"version"`
 	Sessions map[string]Session `json:"sessions"`
 }
 
+// storeDocument is the raw representation of the sessions file used to run
+// migrations before the data is decoded into Session values.
+type storeDocument struct {
+	Version  int                       `json:"version"`
+	Sessions map[string]map[string]any `json:"sessions"`
+}
+
 // LoadSessions reads the session registry from disk or creates an empty store
 // when the file does not yet exist.
 func LoadSessions() (*SessionStore, error) {
@@ -59,7 +106,7 @@ func LoadSessions() (*SessionStore, error) {
 	}
 
 	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-		return &SessionStore{Sessions: map[string]Session{}}, nil
+		return &SessionStore{Version: SessionsVersion, Sessions: map[string]Session{}}, nil
 	} else if err != nil {
 		return nil, fmt.Errorf("unable to stat sessions file: %w", err)
 	}
@@ -69,13 +116,39 @@ func LoadSessions() (*SessionStore, error) {
 		return nil, fmt.Errorf("unable to read sessions file: %w", err)
 	}
 
-	store := &SessionStore{}
-	if err := json.Unmarshal(data, store); err != nil {
+	document := storeDocument{}
+	if err := json.Unmarshal(data, &document); err != nil {
 		return nil, fmt.Errorf("invalid sessions file: %w", err)
 	}
 
-	if store.Sessions == nil {
-		store.Sessions = map[string]Session{}
+	documents := make([]map[string]any, 0, len(document.Sessions))
+	for key, session := range document.Sessions {
+		if alias, _ := session["alias"].(string); alias == "" {
+			session["alias"] = key
+		}
+		documents = append(documents, session)
+	}
+
+	sessions, err := migrateSessions(document.Version, documents)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sessions file: %w", err)
+	}
+
+	store := &SessionStore{Version: SessionsVersion, Sessions: map[string]Session{}}
+	for _, session := range sessions {
+		store.Sessions[strings.ToLower(session.Alias)] = session
+	}
+
+	if document.Version < SessionsVersion {
+		// Keep the original file next to the upgraded one so a downgrade
+		// remains possible.
+		backup := fmt.Sprintf("%s.v%d.bak", path, document.Version)
+		if err := os.WriteFile(backup, data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to back up sessions file before upgrade: %w", err)
+		}
+		if err := store.Save(); err != nil {
+			return nil, err
+		}
 	}
 
 	return store, nil
@@ -89,6 +162,7 @@ func (s *SessionStore) Save() error {
 		return err
 	}
 
+	s.Version = SessionsVersion
 	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to serialise sessions: %w", err)
@@ -155,3 +229,13 @@ func (s *SessionStore) List() []Session {
 
 	return sessions
 }
+
+// HasTag reports whether the session carries tag, ignoring case.
+func (s Session) HasTag(tag string) bool {
+	for _, candidate := range s.Tags {
+		if strings.EqualFold(candidate, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings holds the application wide options of config.yaml, which lives
+// next to the session store. Only implemented options are listed; unknown
+// keys are ignored so that a file written for a newer release still loads.
+type Settings struct {
+	FTP  TransferSettings `yaml:"ftp"`
+	SFTP TransferSettings `yaml:"sftp"`
+}
+
+// TransferSettings configures the file transfers of one protocol.
+type TransferSettings struct {
+	// MaxTransferSpeed limits the bandwidth of a transfer command in KB/s.
+	// Zero means unlimited.
+	MaxTransferSpeed int64 `yaml:"max_transfer_speed"`
+	// Connections is the number of connections a recursive transfer opens.
+	// Zero selects the default.
+	Connections int `yaml:"connections"`
+	// Retries is how often a file failing with a transient error is tried
+	// again. Zero selects the default.
+	Retries int `yaml:"retries"`
+	// Verify compares every uploaded or downloaded file with its source, as
+	// if --verify was given.
+	Verify bool `yaml:"verify"`
+}
+
+// LoadSettings reads config.yaml. A missing file yields the defaults.
+func LoadSettings() (Settings, error) {
+	path, err := FilePath("config.yaml")
+	if err != nil {
+		return Settings{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Settings{}, nil
+	}
+	if err != nil {
+		return Settings{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	settings := Settings{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return Settings{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// Default pool size and retry count of recursive transfers.
+const (
+	DefaultConnections = 4
+	DefaultRetries     = 3
+)
+
+// Transfer returns the settings of protocol with the defaults filled in.
+func (s Settings) Transfer(protocol Protocol) TransferSettings {
+	settings := TransferSettings{}
+	switch protocol {
+	case ProtocolFTP:
+		settings = s.FTP
+	case ProtocolSFTP:
+		settings = s.SFTP
+	}
+	if settings.Connections <= 0 {
+		settings.Connections = DefaultConnections
+	}
+	if settings.Retries <= 0 {
+		settings.Retries = DefaultRetries
+	}
+	return settings
+}
+
+// TransferLimit returns the bandwidth limit for transfers of session in bytes
+// per second. A limit set on the session takes precedence over the protocol
+// wide setting; zero means unlimited.
+func (s Settings) TransferLimit(session Session) int64 {
+	if session.MaxTransferSpeed > 0 {
+		return session.MaxTransferSpeed * 1024
+	}
+	return s.Transfer(session.Protocol).MaxTransferSpeed * 1024
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxSSHConfigIncludeDepth = 16
+
+// sshConfigBlock groups the directives that follow a Host line. The implicit
+// block preceding the first Host line uses the pattern "*".
+type sshConfigBlock struct {
+	patterns   []string
+	directives []sshConfigDirective
+}
+
+type sshConfigDirective struct {
+	key    string
+	name   string
+	value  string
+	source string
+}
+
+// sshConfigKeys lists the directives mapped onto Session fields. Every other
+// directive that applies to a host is reported as unmapped.
+var sshConfigKeys = map[string]bool{
+	"hostname":     true,
+	"port":         true,
+	"user":         true,
+	"identityfile": true,
+	"proxyjump":    true,
+}
+
+// DefaultSSHConfigPath returns the location of the user's OpenSSH client
+// configuration.
+func DefaultSSHConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "config"), nil
+}
+
+// ParseSSHConfig reads an OpenSSH client configuration file, follows Include
+// directives and returns one session per concrete Host alias. Wildcard Host
+// blocks are applied to every matching alias using OpenSSH's first-match-wins
+// semantics. The second return value lists blocks that were not evaluated.
+func ParseSSHConfig(configPath string) ([]ImportedSession, []string, error) {
+	parser := &sshConfigParser{}
+	parser.blocks = []*sshConfigBlock{{patterns: []string{"*"}}}
+	if err := parser.parseFile(configPath, 0); err != nil {
+		return nil, nil, err
+	}
+
+	aliases := []string{}
+	seen := map[string]bool{}
+	for _, block := range parser.blocks {
+		for _, pattern := range block.patterns {
+			if isSSHConfigWildcard(pattern) || seen[strings.ToLower(pattern)] {
+				continue
+			}
+			seen[strings.ToLower(pattern)] = true
+			aliases = append(aliases, pattern)
+		}
+	}
+
+	sessions := make([]ImportedSession, 0, len(aliases))
+	for _, alias := range aliases {
+		sessions = append(sessions, parser.resolve(alias))
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Session.Alias < sessions[j].Session.Alias
+	})
+
+	return sessions, parser.skipped, nil
+}
+
+type sshConfigParser struct {
+	blocks  []*sshConfigBlock
+	skipped []string
+}
+
+func (p *sshConfigParser) parseFile(configPath string, depth int) error {
+	if depth > maxSSHConfigIncludeDepth {
+		return fmt.Errorf("ssh config includes nested too deeply at %s", configPath)
+	}
+
+	file, err := os.Open(configPath)
+	if err != nil {
+		return fmt.Errorf("unable to open ssh config %s: %w", configPath, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		name, value, ok := splitSSHConfigLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		key := strings.ToLower(name)
+
+		source := fmt.Sprintf("%s:%d", configPath, lineNumber)
+		switch key {
+		case "host":
+			p.blocks = append(p.blocks, &sshConfigBlock{patterns: splitSSHConfigValues(value)})
+		case "match":
+			// Match conditions depend on runtime state and cannot be evaluated
+			// statically. Directives below it are collected into a block that
+			// never matches an alias.
+			p.blocks = append(p.blocks, &sshConfigBlock{})
+			p.skipped = append(p.skipped, fmt.Sprintf("%s: Match %s (conditional blocks are not evaluated)", source, value))
+		case "include":
+			current := p.blocks[len(p.blocks)-1]
+			if err := p.include(configPath, value, depth); err != nil {
+				return fmt.Errorf("%s: %w", source, err)
+			}
+			// Directives following the Include line still belong to the block
+			// that contained it.
+			p.blocks = append(p.blocks, &sshConfigBlock{patterns: current.patterns})
+		default:
+			current := p.blocks[len(p.blocks)-1]
+			current.directives = append(current.directives, sshConfigDirective{key: key, name: name, value: value, source: source})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read ssh config %s: %w", configPath, err)
+	}
+
+	return nil
+}
+
+func (p *sshConfigParser) include(parent, value string, depth int) error {
+	for _, pattern := range splitSSHConfigValues(value) {
+		pattern = expandSSHConfigPath(pattern)
+		if !filepath.IsAbs(pattern) {
+			// Relative includes are resolved against ~/.ssh for user
+			// configuration files as OpenSSH does.
+			base := filepath.Dir(parent)
+			if home, err := os.UserHomeDir(); err == nil {
+				base = filepath.Join(home, ".ssh")
+			}
+			pattern = filepath.Join(base, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid Include pattern %q: %w", pattern, err)
+		}
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			if err := p.parseFile(match, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *sshConfigParser) resolve(alias string) ImportedSession {
+	values := map[string]sshConfigDirective{}
+	unmapped := []string{}
+	reported := map[string]bool{}
+	source := ""
+
+	for _, block := range p.blocks {
+		if !matchSSHConfigPatterns(block.patterns, alias) {
+			continue
+		}
+		if source == "" && containsFold(block.patterns, alias) && len(block.directives) > 0 {
+			source = block.directives[0].source
+		}
+		for _, directive := range block.directives {
+			if !sshConfigKeys[directive.key] {
+				if !reported[directive.key] {
+					reported[directive.key] = true
+					unmapped = append(unmapped, fmt.Sprintf("%s %s", directive.name, directive.value))
+				}
+				continue
+			}
+			if _, exists := values[directive.key]; !exists {
+				values[directive.key] = directive
+			}
+		}
+	}
+
+	session := Session{
+		Alias:      strings.ToLower(alias),
+		Protocol:   ProtocolSSH,
+		Host:       alias,
+		Port:       22,
+		AuthMethod: AuthPassword,
+	}
+
+	if hostname, ok := values["hostname"]; ok {
+		session.Host = strings.ReplaceAll(hostname.value, "%h", alias)
+	}
+	if port, ok := values["port"]; ok {
+		if parsed, err := strconv.Atoi(port.value); err == nil && parsed > 0 {
+			session.Port = parsed
+		} else {
+			unmapped = append(unmapped, fmt.Sprintf("%s %s (invalid port)", port.name, port.value))
+		}
+	}
+	if username, ok := values["user"]; ok {
+		session.Username = username.value
+	} else if current, err := user.Current(); err == nil {
+		session.Username = path.Base(filepath.ToSlash(current.Username))
+	}
+	if identity, ok := values["identityfile"]; ok {
+		session.AuthMethod = AuthPrivateKey
+		session.KeyPath = identity.value
+	}
+	if jump, ok := values["proxyjump"]; ok && !strings.EqualFold(jump.value, "none") {
+		session.ProxyJump = jump.value
+	}
+
+	session.RequiresPass = session.AuthMethod == AuthPassword
+	session.Description = fmt.Sprintf("Imported from %s", source)
+	if source == "" {
+		session.Description = "Imported from ssh config"
+	}
+
+	return ImportedSession{Session: session, Source: source, Unmapped: unmapped}
+}
+
+// splitSSHConfigLine extracts a keyword and its value. Both "Keyword value"
+// and "Keyword=value" forms are accepted.
+func splitSSHConfigLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	index := strings.IndexAny(line, " \t=")
+	if index == -1 {
+		return line, "", true
+	}
+
+	key := line[:index]
+	value := strings.TrimSpace(line[index:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' && !strings.Contains(value[1:len(value)-1], `"`) {
+		value = value[1 : len(value)-1]
+	}
+
+	return key, value, true
+}
+
+// splitSSHConfigValues separates whitespace delimited values while honouring
+// double quotes.
+func splitSSHConfigValues(value string) []string {
+	values := []string{}
+	var current strings.Builder
+	quoted := false
+	for _, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case (r == ' ' || r == '\t') && !quoted:
+			if current.Len() > 0 {
+				values = append(values, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		values = append(values, current.String())
+	}
+	return values
+}
+
+func isSSHConfigWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?!")
+}
+
+// matchSSHConfigPatterns applies OpenSSH pattern-list semantics: the alias must
+// match at least one positive pattern and none of the negated ones.
+func matchSSHConfigPatterns(patterns []string, alias string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.ToLower(strings.TrimPrefix(pattern, "!"))
+		ok, err := path.Match(pattern, strings.ToLower(alias))
+		if err != nil || !ok {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+func containsFold(values []string, target string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func expandSSHConfigPath(value string) string {
+	if value != "~" && !strings.HasPrefix(value, "~/") {
+		return value
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return value
+	}
+	return filepath.Join(home, value[1:])
+}
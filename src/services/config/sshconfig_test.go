@@ -0,0 +1,222 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSSHConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		want     []Session
+		unmapped map[string][]string
+		skipped  int
+	}{
+		{
+			name: "concrete hosts",
+			files: map[string]string{"config": `
+# production
+Host web
+    HostName web.example.com
+    Port 2222
+    User deploy
+    IdentityFile ~/.ssh/web
+
+Host db
+    HostName=db.example.com
+    User "ops"
+`},
+			want: []Session{
+				{Alias: "db", Host: "db.example.com", Port: 22, Username: "ops", AuthMethod: AuthPassword, RequiresPass: true},
+				{Alias: "web", Host: "web.example.com", Port: 2222, Username: "deploy", AuthMethod: AuthPrivateKey, KeyPath: "~/.ssh/web"},
+			},
+		},
+		{
+			name: "first match wins",
+			files: map[string]string{"config": `
+Host app
+    User first
+
+Host *
+    User fallback
+    Port 2200
+    HostName %h.internal
+
+Host app
+    User second
+`},
+			want: []Session{
+				{Alias: "app", Host: "app.internal", Port: 2200, Username: "first", AuthMethod: AuthPassword, RequiresPass: true},
+			},
+		},
+		{
+			name: "patterns and negation",
+			files: map[string]string{"config": `
+Host Web-1 web-2 bastion
+    User admin
+
+Host web-* !web-2
+    ProxyJump bastion
+
+Host bastion
+    ProxyJump none
+`},
+			want: []Session{
+				{Alias: "bastion", Host: "bastion", Port: 22, Username: "admin", AuthMethod: AuthPassword, RequiresPass: true},
+				{Alias: "web-1", Host: "Web-1", Port: 22, Username: "admin", AuthMethod: AuthPassword, ProxyJump: "bastion", RequiresPass: true},
+				{Alias: "web-2", Host: "web-2", Port: 22, Username: "admin", AuthMethod: AuthPassword, RequiresPass: true},
+			},
+		},
+		{
+			name: "include keeps the enclosing block",
+			files: map[string]string{
+				"config": `
+Host app
+    Include conf.d/*.conf
+    User after
+`,
+				"conf.d/b.conf": "Port 2202\n",
+				"conf.d/a.conf": "Port 2201\nHost extra\n    User other\n",
+			},
+			want: []Session{
+				{Alias: "app", Host: "app", Port: 2201, Username: "after", AuthMethod: AuthPassword, RequiresPass: true},
+				{Alias: "extra", Host: "extra", Port: 2202, Username: "other", AuthMethod: AuthPassword, RequiresPass: true},
+			},
+		},
+		{
+			name: "match blocks are skipped",
+			files: map[string]string{"config": `
+Host app
+    User deploy
+
+Match host app exec "true"
+    Port 2299
+`},
+			want: []Session{
+				{Alias: "app", Host: "app", Port: 22, Username: "deploy", AuthMethod: AuthPassword, RequiresPass: true},
+			},
+			skipped: 1,
+		},
+		{
+			name: "unmapped directives",
+			files: map[string]string{"config": `
+Host app
+    User deploy
+    Port http
+    ForwardAgent yes
+    ForwardAgent no
+`},
+			want: []Session{
+				{Alias: "app", Host: "app", Port: 22, Username: "deploy", AuthMethod: AuthPassword, RequiresPass: true},
+			},
+			unmapped: map[string][]string{"app": {"ForwardAgent yes", "Port http (invalid port)"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			for name, content := range tt.files {
+				path := filepath.Join(home, ".ssh", name)
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			imported, skipped, err := ParseSSHConfig(filepath.Join(home, ".ssh", "config"))
+			if err != nil {
+				t.Fatalf("ParseSSHConfig failed: %v", err)
+			}
+			if len(skipped) != tt.skipped {
+				t.Errorf("skipped = %q, want %d entries", skipped, tt.skipped)
+			}
+
+			got := make([]Session, 0, len(imported))
+			for _, entry := range imported {
+				if !strings.HasPrefix(entry.Session.Description, "Imported from ") {
+					t.Errorf("%s: description = %q", entry.Session.Alias, entry.Session.Description)
+				}
+				if want := tt.unmapped[entry.Session.Alias]; len(entry.Unmapped)+len(want) > 0 && !reflect.DeepEqual(entry.Unmapped, want) {
+					t.Errorf("%s: unmapped = %q, want %q", entry.Session.Alias, entry.Unmapped, want)
+				}
+				session := entry.Session
+				session.Protocol = ""
+				session.Description = ""
+				got = append(got, session)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sessions =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSSHConfigIncludeLoop(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, "config")
+	if err := os.WriteFile(path, []byte("Include "+path+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ParseSSHConfig(path); err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Errorf("ParseSSHConfig error = %v, want include depth error", err)
+	}
+}
+
+func TestSplitSSHConfigLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		key   string
+		value string
+		ok    bool
+	}{
+		{line: "", ok: false},
+		{line: "   # comment", ok: false},
+		{line: "User deploy", key: "User", value: "deploy", ok: true},
+		{line: "\tPort\t 2222 ", key: "Port", value: "2222", ok: true},
+		{line: "HostName=web.example.com", key: "HostName", value: "web.example.com", ok: true},
+		{line: "HostName = web.example.com", key: "HostName", value: "web.example.com", ok: true},
+		{line: `IdentityFile "~/my keys/id"`, key: "IdentityFile", value: "~/my keys/id", ok: true},
+		{line: `Host "a b" c`, key: "Host", value: `"a b" c`, ok: true},
+		{line: "Compression", key: "Compression", value: "", ok: true},
+	}
+
+	for _, tt := range tests {
+		key, value, ok := splitSSHConfigLine(tt.line)
+		if key != tt.key || value != tt.value || ok != tt.ok {
+			t.Errorf("splitSSHConfigLine(%q) = %q, %q, %v, want %q, %q, %v", tt.line, key, value, ok, tt.key, tt.value, tt.ok)
+		}
+	}
+}
+
+func TestMatchSSHConfigPatterns(t *testing.T) {
+	tests := []struct {
+		patterns string
+		alias    string
+		want     bool
+	}{
+		{patterns: "web", alias: "web", want: true},
+		{patterns: "WEB", alias: "web", want: true},
+		{patterns: "web", alias: "web-1", want: false},
+		{patterns: "web-?", alias: "web-1", want: true},
+		{patterns: "db web-*", alias: "web-10", want: true},
+		{patterns: "* !db", alias: "db", want: false},
+		{patterns: "!db", alias: "web", want: false},
+		{patterns: "", alias: "web", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := matchSSHConfigPatterns(strings.Fields(tt.patterns), tt.alias); got != tt.want {
+			t.Errorf("matchSSHConfigPatterns(%q, %q) = %v, want %v", tt.patterns, tt.alias, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const winscpSessionPrefix = `Sessions\`
+
+// ParseWinSCP reads a WinSCP.ini configuration file. Session folders are
+// mapped onto session tags. The second return value lists sites that use a
+// protocol ServerCommander cannot handle.
+func ParseWinSCP(path string) ([]ImportedSession, []string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open WinSCP configuration %s: %w", path, err)
+	}
+	defer file.Close()
+
+	type section struct {
+		name   string
+		values map[string]string
+	}
+
+	sections := []*section{}
+	var current *section
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = nil
+			name := line[1 : len(line)-1]
+			if strings.HasPrefix(name, winscpSessionPrefix) {
+				current = &section{name: strings.TrimPrefix(name, winscpSessionPrefix), values: map[string]string{}}
+				sections = append(sections, current)
+			}
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			current.values[strings.TrimSpace(key)] = winscpUnescape(strings.TrimSpace(value))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read WinSCP configuration %s: %w", path, err)
+	}
+
+	sessions := []ImportedSession{}
+	skipped := []string{}
+	for _, section := range sections {
+		name := winscpUnescape(section.name)
+		if name == "Default Settings" {
+			continue
+		}
+
+		imported, ok := mapWinSCPSession(name, section.values)
+		if !ok {
+			skipped = append(skipped, fmt.Sprintf("%s (unsupported protocol %s)", name, section.values["FSProtocol"]))
+			continue
+		}
+		imported.Source = name
+		sessions = append(sessions, imported)
+	}
+
+	uniqueAliases(sessions)
+	return sessions, skipped, nil
+}
+
+func mapWinSCPSession(name string, values map[string]string) (ImportedSession, bool) {
+	unmapped := []string{}
+	segments := strings.Split(name, "/")
+	siteName := segments[len(segments)-1]
+
+	session := Session{
+		Alias:      importAlias(siteName),
+		Host:       values["HostName"],
+		Username:   values["UserName"],
+		AuthMethod: AuthPassword,
+	}
+
+	// FSProtocol values: 0 SCP, 1 SFTP with SCP fallback, 2 SFTP, 5 FTP,
+	// 6 WebDAV, 7 S3. WinSCP omits the key for its SFTP default.
+	switch values["FSProtocol"] {
+	case "", "1", "2":
+		session.Protocol = ProtocolSFTP
+	case "0":
+		session.Protocol = ProtocolSFTP
+		unmapped = append(unmapped, "SCP protocol (imported as SFTP)")
+	case "5":
+		session.Protocol = ProtocolFTP
+	default:
+		return ImportedSession{}, false
+	}
+
+	session.Port = defaultImportPort(session.Protocol)
+	if session.Protocol == ProtocolFTP {
+		// Ftps values: 0 none, 1 implicit, 2 explicit SSL, 3 explicit TLS.
+		switch values["Ftps"] {
+		case "1":
+			session.TLSMode = TLSModeImplicit
+			session.Port = 990
+		case "2", "3":
+			session.TLSMode = TLSModeExplicit
+		}
+	}
+
+	if port, err := strconv.Atoi(values["PortNumber"]); err == nil && port > 0 {
+		session.Port = port
+	}
+
+	if key := values["PublicKeyFile"]; key != "" {
+		if session.Protocol == ProtocolFTP {
+			unmapped = append(unmapped, "key file "+key)
+		} else {
+			session.AuthMethod = AuthPrivateKey
+			session.KeyPath = key
+			if strings.HasSuffix(strings.ToLower(key), ".ppk") {
+				unmapped = append(unmapped, "PuTTY key format (convert the key to OpenSSH format)")
+			}
+		}
+	}
+
+	if values["Tunnel"] == "1" && values["TunnelHostName"] != "" {
+		if session.Protocol == ProtocolFTP {
+			unmapped = append(unmapped, "SSH tunnel "+values["TunnelHostName"])
+		} else {
+			jump := values["TunnelHostName"]
+			if port := values["TunnelPortNumber"]; port != "" && port != "22" {
+				jump = net.JoinHostPort(jump, port)
+			}
+			if user := values["TunnelUserName"]; user != "" {
+				jump = user + "@" + jump
+			}
+			session.ProxyJump = jump
+		}
+	}
+
+	if values["Password"] != "" {
+		unmapped = append(unmapped, "stored password")
+	}
+	if session.Protocol == ProtocolFTP && values["FtpPasvMode"] == "0" {
+		session.DataMode = DataModeActive
+	}
+	if values["ProxyMethod"] != "" && values["ProxyMethod"] != "0" {
+		unmapped = append(unmapped, "proxy "+values["ProxyHost"])
+	}
+	if dir := values["RemoteDirectory"]; dir != "" {
+		unmapped = append(unmapped, "remote directory "+dir)
+	}
+	if dir := values["LocalDirectory"]; dir != "" {
+		unmapped = append(unmapped, "local directory "+dir)
+	}
+
+	for _, folder := range segments[:len(segments)-1] {
+		if tag := importAlias(folder); tag != "" {
+			session.Tags = append(session.Tags, tag)
+		}
+	}
+
+	session.RequiresPass = session.AuthMethod == AuthPassword
+	return ImportedSession{Session: session, Unmapped: unmapped}, true
+}
+
+// winscpUnescape decodes the percent encoding WinSCP applies to section names
+// and string values. Invalid sequences are returned unchanged.
+func winscpUnescape(value string) string {
+	decoded, err := url.PathUnescape(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
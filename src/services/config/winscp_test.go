@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseWinSCP(t *testing.T) {
+	tests := []struct {
+		name     string
+		section  string
+		want     Session
+		source   string
+		unmapped []string
+		skipped  []string
+	}{
+		{
+			name: "sftp default protocol",
+			section: `[Sessions\deploy@web]
+HostName=web.example.com
+UserName=deploy
+PortNumber=2222`,
+			want:   Session{Alias: "deploy-web", Protocol: ProtocolSFTP, Host: "web.example.com", Port: 2222, Username: "deploy", AuthMethod: AuthPassword, RequiresPass: true},
+			source: "deploy@web",
+		},
+		{
+			name: "folders and escaped names",
+			section: `[Sessions\Customers/ACME%20Corp/db%20primary]
+HostName=db
+UserName=root
+FSProtocol=0
+PublicKeyFile=C:%5Ckeys%5Cdb.ppk`,
+			want:     Session{Alias: "db-primary", Protocol: ProtocolSFTP, Host: "db", Port: 22, Username: "root", AuthMethod: AuthPrivateKey, KeyPath: `C:\keys\db.ppk`, Tags: []string{"customers", "acme-corp"}},
+			source:   "Customers/ACME Corp/db primary",
+			unmapped: []string{"SCP protocol (imported as SFTP)", "PuTTY key format (convert the key to OpenSSH format)"},
+		},
+		{
+			name: "tunnel becomes proxy jump",
+			section: `[Sessions\app]
+HostName=10.0.0.5
+UserName=app
+Tunnel=1
+TunnelHostName=bastion.example.com
+TunnelPortNumber=2200
+TunnelUserName=jump`,
+			want:   Session{Alias: "app", Protocol: ProtocolSFTP, Host: "10.0.0.5", Port: 22, Username: "app", AuthMethod: AuthPassword, ProxyJump: "jump@bastion.example.com:2200", RequiresPass: true},
+			source: "app",
+		},
+		{
+			name: "ftp keeps unmapped settings",
+			section: `[Sessions\files]
+HostName=files
+FSProtocol=5
+Password=secret
+PublicKeyFile=/k
+Tunnel=1
+TunnelHostName=bastion
+ProxyMethod=2
+ProxyHost=proxy
+RemoteDirectory=/srv
+LocalDirectory=C:%5Cdata`,
+			want:     Session{Alias: "files", Protocol: ProtocolFTP, Host: "files", Port: 21, AuthMethod: AuthPassword, RequiresPass: true},
+			source:   "files",
+			unmapped: []string{"key file /k", "SSH tunnel bastion", "stored password", "proxy proxy", "remote directory /srv", `local directory C:\data`},
+		},
+		{
+			name: "ftp active mode",
+			section: `[Sessions\files]
+HostName=files
+FSProtocol=5
+FtpPasvMode=0`,
+			want:   Session{Alias: "files", Protocol: ProtocolFTP, Host: "files", Port: 21, AuthMethod: AuthPassword, DataMode: DataModeActive, RequiresPass: true},
+			source: "files",
+		},
+		{
+			name: "implicit ftps",
+			section: `[Sessions\secure]
+HostName=files
+FSProtocol=5
+Ftps=1`,
+			want:   Session{Alias: "secure", Protocol: ProtocolFTP, Host: "files", Port: 990, AuthMethod: AuthPassword, TLSMode: TLSModeImplicit, RequiresPass: true},
+			source: "secure",
+		},
+		{
+			name: "explicit ftps on custom port",
+			section: `[Sessions\secure]
+HostName=files
+FSProtocol=5
+Ftps=3
+PortNumber=2121`,
+			want:   Session{Alias: "secure", Protocol: ProtocolFTP, Host: "files", Port: 2121, AuthMethod: AuthPassword, TLSMode: TLSModeExplicit, RequiresPass: true},
+			source: "secure",
+		},
+		{
+			name: "unsupported protocol",
+			section: `[Sessions\bucket]
+HostName=s3.amazonaws.com
+FSProtocol=7`,
+			skipped: []string{"bucket (unsupported protocol 7)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "WinSCP.ini")
+			content := "\ufeff[Configuration]\nHostName=ignored\n\n[Sessions\\Default%20Settings]\nHostName=default\n\n; comment\n" + tt.section + "\n"
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			sessions, skipped, err := ParseWinSCP(path)
+			if err != nil {
+				t.Fatalf("ParseWinSCP failed: %v", err)
+			}
+			if !reflect.DeepEqual(skipped, append([]string{}, tt.skipped...)) {
+				t.Errorf("skipped = %q, want %q", skipped, tt.skipped)
+			}
+			if tt.skipped != nil {
+				if len(sessions) != 0 {
+					t.Errorf("imported %d sessions from an unsupported site", len(sessions))
+				}
+				return
+			}
+			if len(sessions) != 1 {
+				t.Fatalf("imported %d sessions, want 1", len(sessions))
+			}
+			if got := sessions[0]; !reflect.DeepEqual(got.Session, tt.want) || got.Source != tt.source || !reflect.DeepEqual(got.Unmapped, append([]string{}, tt.unmapped...)) {
+				t.Errorf("imported\n%+v\n%q %q\nwant\n%+v\n%q %q", got.Session, got.Source, got.Unmapped, tt.want, tt.source, tt.unmapped)
+			}
+		})
+	}
+}
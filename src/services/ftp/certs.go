@@ -0,0 +1,197 @@
+package ftp
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"servercommander/src/services/config"
+	"servercommander/src/utils"
+)
+
+// pinnedCertificatesFile stores the fingerprints of certificates the user
+// trusted explicitly because they could not be verified against a CA.
+const pinnedCertificatesFile = "ftp_known_certificates"
+
+// trustMu serialises certificate prompts and updates of the pin file when
+// several connections are opened at once.
+var trustMu sync.Mutex
+
+// newTLSConfig returns the TLS configuration for the control and data
+// connections of session. Certificates are verified against the session's CA
+// bundle or the system pool. Certificates that fail verification, such as the
+// self-signed ones many FTP servers use, are trusted on first use after
+// confirmation and pinned by fingerprint; a later change is reported and needs
+// to be confirmed again.
+func newTLSConfig(session config.Session) (*tls.Config, error) {
+	roots, err := loadCertPool(session.CACertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	address := net.JoinHostPort(session.Host, strconv.Itoa(session.Port))
+	return &tls.Config{
+		ServerName: session.Host,
+		// The standard verification cannot fall back to pinned certificates,
+		// so it is replaced by verifyCertificate.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return verifyCertificate(address, session.Host, roots, state)
+		},
+		// Many servers require data connections to resume the TLS session of
+		// the control connection.
+		ClientSessionCache: tls.NewLRUClientSessionCache(0),
+	}, nil
+}
+
+// loadCertPool reads a PEM bundle or returns the system pool when path is empty.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load system certificates: %w", err)
+		}
+		return pool, nil
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve home directory: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle %s: %w", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA bundle %s contains no certificates", path)
+	}
+	return pool, nil
+}
+
+func verifyCertificate(address, host string, roots *x509.CertPool, state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+
+	leaf := state.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, verifyErr := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, Intermediates: intermediates})
+	if verifyErr == nil {
+		return nil
+	}
+
+	trustMu.Lock()
+	defer trustMu.Unlock()
+
+	path, err := config.FilePath(pinnedCertificatesFile)
+	if err != nil {
+		return err
+	}
+	pins, err := loadPins(path)
+	if err != nil {
+		return err
+	}
+
+	fingerprint := certificateFingerprint(leaf)
+	pinned, known := pins[address]
+	if known && pinned == fingerprint {
+		return nil
+	}
+
+	if known {
+		fmt.Printf("%sWARNING: the certificate of %s has changed since it was trusted. The server may have been reconfigured or the connection is being intercepted.%s\n", utils.Red, address, utils.Reset)
+		fmt.Printf("Previous fingerprint: %s\n", pinned)
+	} else {
+		fmt.Printf("%sThe certificate of %s could not be verified: %v%s\n", utils.Yellow, address, verifyErr, utils.Reset)
+	}
+	fmt.Printf("Subject:     %s\n", leaf.Subject)
+	fmt.Printf("Issuer:      %s\n", leaf.Issuer)
+	fmt.Printf("Valid until: %s\n", leaf.NotAfter.Format(time.RFC3339))
+	fmt.Printf("Fingerprint: %s\n", fingerprint)
+
+	trust, err := utils.PromptBool("Trust this certificate and remember it", false)
+	if err != nil {
+		return err
+	}
+	if !trust {
+		return fmt.Errorf("certificate for %s rejected", address)
+	}
+
+	pins[address] = fingerprint
+	return savePins(path, pins)
+}
+
+// certificateFingerprint formats the SHA-256 digest of the certificate the
+// way browsers and most FTP servers display it.
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return "SHA256:" + strings.Join(parts, ":")
+}
+
+// loadPins reads the pin file, which holds one "host:port fingerprint" pair
+// per line.
+func loadPins(path string) (map[string]string, error) {
+	pins := map[string]string{}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return pins, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		pins[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return pins, nil
+}
+
+func savePins(path string, pins map[string]string) error {
+	addresses := make([]string, 0, len(pins))
+	for address := range pins {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	var builder strings.Builder
+	for _, address := range addresses {
+		fmt.Fprintf(&builder, "%s %s\n", address, pins[address])
+	}
+
+	if err := os.WriteFile(path, []byte(builder.String()), 0600); err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	return nil
+}
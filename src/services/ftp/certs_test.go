@@ -0,0 +1,224 @@
+package ftp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"servercommander/src/services/config"
+	"servercommander/src/utils"
+)
+
+// newTestCertificate creates a certificate for host signed by parent, or a
+// self-signed one when parent is nil.
+func newTestCertificate(t *testing.T, host string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	const address = "ftp.example.com:21"
+	ca, caKey := newTestCertificate(t, "Test CA", nil, nil)
+	signed, _ := newTestCertificate(t, "ftp.example.com", ca, caKey)
+	selfSigned, _ := newTestCertificate(t, "ftp.example.com", nil, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	tests := []struct {
+		name  string
+		host  string
+		chain []*x509.Certificate
+		pins  map[string]string
+		// answer is the reply to the trust prompt; an empty answer makes the
+		// prompt fail, so cases that must not prompt leave it empty.
+		answer string
+		err    string
+		want   map[string]string
+	}{
+		{name: "signed by trusted CA", host: "ftp.example.com", chain: []*x509.Certificate{signed}, want: map[string]string{}},
+		{name: "no certificate", host: "ftp.example.com", err: "no certificate", want: map[string]string{}},
+		{
+			name:  "pinned certificate",
+			host:  "ftp.example.com",
+			chain: []*x509.Certificate{selfSigned},
+			pins:  map[string]string{address: certificateFingerprint(selfSigned)},
+			want:  map[string]string{address: certificateFingerprint(selfSigned)},
+		},
+		{
+			name:   "unknown certificate trusted",
+			host:   "ftp.example.com",
+			chain:  []*x509.Certificate{selfSigned},
+			pins:   map[string]string{"other:21": "SHA256:AA"},
+			answer: "y\n",
+			want:   map[string]string{address: certificateFingerprint(selfSigned), "other:21": "SHA256:AA"},
+		},
+		{
+			name:   "unknown certificate rejected",
+			host:   "ftp.example.com",
+			chain:  []*x509.Certificate{selfSigned},
+			answer: "\n",
+			err:    "certificate for ftp.example.com:21 rejected",
+			want:   map[string]string{},
+		},
+		{
+			name:   "signed certificate for another host",
+			host:   "files.example.com",
+			chain:  []*x509.Certificate{signed},
+			answer: "yes\n",
+			want:   map[string]string{address: certificateFingerprint(signed)},
+		},
+		{
+			name:   "changed certificate rejected",
+			host:   "ftp.example.com",
+			chain:  []*x509.Certificate{selfSigned},
+			pins:   map[string]string{address: "SHA256:AA"},
+			answer: "n\n",
+			err:    "rejected",
+			want:   map[string]string{address: "SHA256:AA"},
+		},
+		{
+			name:   "changed certificate trusted again",
+			host:   "ftp.example.com",
+			chain:  []*x509.Certificate{selfSigned},
+			pins:   map[string]string{address: "SHA256:AA"},
+			answer: "y\n",
+			want:   map[string]string{address: certificateFingerprint(selfSigned)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			path, err := config.FilePath(pinnedCertificatesFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.pins != nil {
+				if err := savePins(path, tt.pins); err != nil {
+					t.Fatal(err)
+				}
+			}
+			utils.SetPromptReader(strings.NewReader(tt.answer))
+
+			err = verifyCertificate(address, tt.host, roots, tls.ConnectionState{PeerCertificates: tt.chain})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("verifyCertificate error = %v, want %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Errorf("verifyCertificate failed: %v", err)
+			}
+
+			pins, err := loadPins(path)
+			if err != nil || !reflect.DeepEqual(pins, tt.want) {
+				t.Errorf("pins = %v, %v, want %v", pins, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCertificateFingerprint(t *testing.T) {
+	cert, _ := newTestCertificate(t, "ftp.example.com", nil, nil)
+	fingerprint := certificateFingerprint(cert)
+	if !regexp.MustCompile(`^SHA256:[0-9A-F]{2}(:[0-9A-F]{2}){31}$`).MatchString(fingerprint) {
+		t.Errorf("certificateFingerprint = %q", fingerprint)
+	}
+
+	other, _ := newTestCertificate(t, "ftp.example.com", nil, nil)
+	if certificateFingerprint(other) == fingerprint {
+		t.Error("different certificates have the same fingerprint")
+	}
+}
+
+func TestLoadPins(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{name: "missing file", want: map[string]string{}},
+		{
+			name:    "entries",
+			content: "b:21 SHA256:BB\na:990 SHA256:AA\n",
+			want:    map[string]string{"a:990": "SHA256:AA", "b:21": "SHA256:BB"},
+		},
+		{
+			name:    "comments and malformed lines",
+			content: "# pinned certificates\n\na:21\na:21 SHA256:AA extra\n  c:21   SHA256:CC  \n",
+			want:    map[string]string{"c:21": "SHA256:CC"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), pinnedCertificatesFile)
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			pins, err := loadPins(path)
+			if err != nil || !reflect.DeepEqual(pins, tt.want) {
+				t.Errorf("loadPins = %v, %v, want %v", pins, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSavePins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), pinnedCertificatesFile)
+	if err := savePins(path, map[string]string{"b:21": "SHA256:BB", "a:990": "SHA256:AA"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a:990 SHA256:AA\nb:21 SHA256:BB\n"; string(data) != want {
+		t.Errorf("pin file = %q, want %q", data, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("pin file mode = %v, %v", info.Mode(), err)
+	}
+}
@@ -0,0 +1,135 @@
+package ftp
+
+import (
+	"fmt"
+	"strings"
+
+	"servercommander/src/services/transfer"
+)
+
+// hashPreference lists the algorithms of the HASH command in the order they
+// are chosen when the server offers several.
+var hashPreference = []string{"SHA-256", "SHA-512", "SHA-1", "MD5"}
+
+// legacyHashCommands are the pre-standard hash commands, tried when the
+// server does not implement HASH.
+var legacyHashCommands = []struct {
+	command   string
+	algorithm string
+}{
+	{"XSHA256", "SHA-256"},
+	{"XMD5", "MD5"},
+}
+
+// Checksum asks the server for the digest of a remote file. HASH is used
+// when advertised, otherwise XSHA256 or XMD5. It returns
+// transfer.ErrNoChecksum when the server implements none of them.
+func (c *Client) Checksum(remotePath string) (transfer.Checksum, error) {
+	if algorithm := c.hashAlgorithm(); algorithm != "" {
+		if err := c.selectHashAlgorithm(algorithm); err != nil {
+			return transfer.Checksum{}, err
+		}
+		return c.requestChecksum("HASH", algorithm, remotePath, 213)
+	}
+
+	for _, legacy := range legacyHashCommands {
+		if c.supports(legacy.command) {
+			return c.requestChecksum(legacy.command, legacy.algorithm, remotePath, 250, 213)
+		}
+	}
+	return transfer.Checksum{}, transfer.ErrNoChecksum
+}
+
+// hashAlgorithm returns the preferred algorithm among those advertised for
+// HASH, or an empty string when HASH is not supported.
+func (c *Client) hashAlgorithm() string {
+	params, ok := c.features["HASH"]
+	if !ok {
+		return ""
+	}
+
+	offered := map[string]bool{}
+	for _, name := range strings.Split(params, ";") {
+		offered[strings.ToUpper(strings.TrimSuffix(strings.TrimSpace(name), "*"))] = true
+	}
+	for _, algorithm := range hashPreference {
+		if offered[algorithm] {
+			return algorithm
+		}
+	}
+	return ""
+}
+
+// selectHashAlgorithm makes algorithm the one used by HASH. The FEAT reply
+// marks the selected algorithm with an asterisk, so OPTS is only sent when
+// another one is active.
+func (c *Client) selectHashAlgorithm(algorithm string) error {
+	for _, name := range strings.Split(c.features["HASH"], ";") {
+		if strings.EqualFold(strings.TrimSpace(name), algorithm+"*") {
+			return nil
+		}
+	}
+
+	if err := c.control.PrintfLine("OPTS HASH %s", algorithm); err != nil {
+		return err
+	}
+	if _, _, err := c.read(200); err != nil {
+		return fmt.Errorf("server rejected hash algorithm %s: %w", algorithm, err)
+	}
+
+	// Remember the selection for the following files.
+	names := strings.Split(c.features["HASH"], ";")
+	for i, name := range names {
+		names[i] = strings.TrimSuffix(strings.TrimSpace(name), "*")
+		if strings.EqualFold(names[i], algorithm) {
+			names[i] += "*"
+		}
+	}
+	c.features["HASH"] = strings.Join(names, ";")
+	return nil
+}
+
+// requestChecksum sends a hash command and extracts the digest from the
+// reply. Servers differ in what they send around the digest, e.g.
+// "213 SHA-256 0-49 169cd2... file" for HASH and "250 169cd2..." for the X
+// commands, so the first token of the expected length is taken.
+func (c *Client) requestChecksum(command, algorithm, remotePath string, expected ...int) (transfer.Checksum, error) {
+	if err := c.control.PrintfLine("%s %s", command, remotePath); err != nil {
+		return transfer.Checksum{}, err
+	}
+	_, message, err := c.read(expected...)
+	if err != nil {
+		return transfer.Checksum{}, fmt.Errorf("failed to hash %s: %w", remotePath, err)
+	}
+
+	length := digestLength(algorithm)
+	for _, token := range strings.Fields(message) {
+		if len(token) == length && isHex(token) {
+			return transfer.Checksum{Algorithm: algorithm, Sum: strings.ToLower(token)}, nil
+		}
+	}
+	return transfer.Checksum{}, fmt.Errorf("invalid %s response: %s", command, message)
+}
+
+// digestLength returns the length of a hex encoded digest of algorithm.
+func digestLength(algorithm string) int {
+	switch algorithm {
+	case "SHA-512":
+		return 128
+	case "SHA-256":
+		return 64
+	case "SHA-1":
+		return 40
+	default:
+		return 32
+	}
+}
+
+func isHex(value string) bool {
+	for _, r := range value {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package ftp
+
+import (
+	"strings"
+	"testing"
+
+	"servercommander/src/services/config"
+	"servercommander/src/services/transfer"
+)
+
+const (
+	testSHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	testMD5    = "098f6bcd4621d373cade4e832627b4f6"
+)
+
+func TestHashAlgorithm(t *testing.T) {
+	tests := []struct {
+		name     string
+		features map[string]string
+		want     string
+	}{
+		{name: "no hash support", features: map[string]string{"MLST": ""}, want: ""},
+		{name: "sha-256 preferred", features: map[string]string{"HASH": "SHA-1*;SHA-256;MD5"}, want: "SHA-256"},
+		{name: "sha-512 before sha-1", features: map[string]string{"HASH": "MD5;SHA-1;SHA-512"}, want: "SHA-512"},
+		{name: "case and spacing", features: map[string]string{"HASH": " md5* ; sha-1 "}, want: "SHA-1"},
+		{name: "only unknown algorithms", features: map[string]string{"HASH": "CRC32;BLAKE2"}, want: ""},
+		{name: "empty parameters", features: map[string]string{"HASH": ""}, want: ""},
+	}
+
+	for _, tt := range tests {
+		client := &Client{features: tt.features}
+		if got := client.hashAlgorithm(); got != tt.want {
+			t.Errorf("%s: hashAlgorithm() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		features map[string]string
+		script   []exchange
+		want     transfer.Checksum
+		err      string
+		// selected is the HASH feature after the request.
+		selected string
+	}{
+		{
+			name:     "hash with selected algorithm",
+			features: map[string]string{"HASH": "SHA-1;SHA-256*"},
+			script:   []exchange{{"HASH /f.txt", "213 SHA-256 0-4 " + strings.ToUpper(testSHA256) + " /f.txt"}},
+			want:     transfer.Checksum{Algorithm: "SHA-256", Sum: testSHA256},
+			selected: "SHA-1;SHA-256*",
+		},
+		{
+			name:     "hash selects the preferred algorithm",
+			features: map[string]string{"HASH": "SHA-1*;SHA-256;MD5"},
+			script: []exchange{
+				{"OPTS HASH SHA-256", "200 SHA-256"},
+				{"HASH /f.txt", "213 SHA-256 0-4 " + testSHA256 + " /f.txt"},
+			},
+			want:     transfer.Checksum{Algorithm: "SHA-256", Sum: testSHA256},
+			selected: "SHA-1;SHA-256*;MD5",
+		},
+		{
+			name:     "algorithm selection rejected",
+			features: map[string]string{"HASH": "SHA-1*;SHA-256"},
+			script:   []exchange{{"OPTS HASH SHA-256", "501 Unsupported"}},
+			err:      "server rejected hash algorithm SHA-256",
+			selected: "SHA-1*;SHA-256",
+		},
+		{
+			name:     "hash of a directory",
+			features: map[string]string{"HASH": "SHA-256*"},
+			script:   []exchange{{"HASH /f.txt", "550 Is a directory"}},
+			err:      "failed to hash /f.txt",
+			selected: "SHA-256*",
+		},
+		{
+			name:     "hash reply without digest",
+			features: map[string]string{"HASH": "SHA-256*"},
+			script:   []exchange{{"HASH /f.txt", "213 SHA-256 0-4 " + testMD5 + " /f.txt"}},
+			err:      "invalid HASH response",
+			selected: "SHA-256*",
+		},
+		{
+			name:     "legacy sha-256",
+			features: map[string]string{"XSHA256": "", "XMD5": ""},
+			script:   []exchange{{"XSHA256 /f.txt", "250 " + testSHA256}},
+			want:     transfer.Checksum{Algorithm: "SHA-256", Sum: testSHA256},
+		},
+		{
+			name:     "legacy md5 with 213 reply",
+			features: map[string]string{"XMD5": ""},
+			script:   []exchange{{"XMD5 /f.txt", "213 /f.txt " + testMD5}},
+			want:     transfer.Checksum{Algorithm: "MD5", Sum: testMD5},
+		},
+		{
+			name:     "no checksum support",
+			features: map[string]string{"MDTM": ""},
+			err:      transfer.ErrNoChecksum.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newScriptedClient(t, config.Session{Host: "ftp.example.com"}, tt.script)
+			client.features = tt.features
+
+			got, err := client.Checksum("/f.txt")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Checksum error = %v, want %q", err, tt.err)
+				}
+			} else if err != nil || got != tt.want {
+				t.Errorf("Checksum = %+v, %v, want %+v", got, err, tt.want)
+			}
+			if client.features["HASH"] != tt.selected {
+				t.Errorf("HASH feature = %q, want %q", client.features["HASH"], tt.selected)
+			}
+		})
+	}
+}
@@ -3,39 +3,77 @@ package ftp
 import (
 	"bufio"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"net/textproto"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"servercommander/src/services/config"
+	"servercommander/src/services/transfer"
 )
 
 // Entry describes a file or directory returned by the FTP server.
 type Entry struct {
-	Name    string
-	Size    int64
-	ModTime time.Time
-	IsDir   bool
-	Raw     string
+	Name    string    `json:"name" yaml:"name"`
+	Size    int64     `json:"size" yaml:"size"`
+	ModTime time.Time `json:"modTime" yaml:"modTime"`
+	IsDir   bool      `json:"isDir" yaml:"isDir"`
+	// Permissions holds the mode in ls notation when the server reveals it,
+	// or the RFC 3659 perm facts for MLSD listings without a Unix mode.
+	Permissions string `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+	Owner       string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Group       string `json:"group,omitempty" yaml:"group,omitempty"`
+	// Target is the destination of a symbolic link. It is empty for other
+	// entries and when the server does not reveal it.
+	Target string `json:"target,omitempty" yaml:"target,omitempty"`
+	Raw    string `json:"-" yaml:"-"`
 }
 
-// Client implements a minimal FTP/FTPS client with passive mode support.
+// Client implements a minimal FTP/FTPS client with passive and active mode
+// support.
 type Client struct {
 	session   config.Session
 	control   *textproto.Conn
 	conn      net.Conn
 	tlsConfig *tls.Config
+	// epsvRejected and eprtRejected remember that the server does not
+	// implement the extended data connection commands, so later transfers
+	// go straight to PASV or PORT.
+	epsvRejected bool
+	eprtRejected bool
+	// features maps the extensions advertised by FEAT to their parameters.
+	features map[string]string
+	// knownDirs caches remote directories that exist or were created so that
+	// recursive uploads do not repeat MKD for every file.
+	knownDirs map[string]bool
+	// progress observes the data copied by Upload and Download.
+	progress transfer.ProgressFactory
+	// limiter throttles the data copied by Upload and Download.
+	limiter *transfer.Limiter
+	// renameRefused remembers that the server does not allow RNFR, so later
+	// uploads store the file under its final name right away.
+	renameRefused bool
 }
 
 // Connect establishes a control connection and authenticates the user.
 func Connect(session config.Session, password string) (*Client, error) {
 	address := net.JoinHostPort(session.Host, strconv.Itoa(session.Port))
+	var tlsConfig *tls.Config
+	if session.TLSMode.UsesTLS() {
+		var err error
+		if tlsConfig, err = newTLSConfig(session); err != nil {
+			return nil, err
+		}
+	}
+
 	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
@@ -47,13 +85,21 @@ func Connect(session config.Session, password string) (*Client, error) {
 		control: textproto.NewConn(conn),
 	}
 
+	// Implicit FTPS expects the handshake before the server sends its greeting.
+	if session.TLSMode == config.TLSModeImplicit {
+		if err := client.secureControlConnection(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
 	if _, _, err := client.read(220); err != nil {
 		client.Close()
 		return nil, err
 	}
 
-	if session.UseTLS {
-		if err := client.startTLS(); err != nil {
+	if session.TLSMode == config.TLSModeExplicit {
+		if err := client.startTLS(tlsConfig); err != nil {
 			client.Close()
 			return nil, err
 		}
@@ -71,6 +117,25 @@ func Connect(session config.Session, password string) (*Client, error) {
 		}
 	}
 
+	if client.tlsConfig != nil {
+		if err := client.protectDataChannel(); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	// Binary mode keeps files byte for byte identical, which size based
+	// comparisons rely on.
+	if err := client.control.PrintfLine("TYPE I"); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if _, _, err := client.read(200); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	client.loadFeatures()
 	return client, nil
 }
 
@@ -86,7 +151,81 @@ func (c *Client) Close() error {
 	return nil
 }
 
-// Upload stores a local file on the remote server.
+// loadFeatures records the extensions the server advertises with FEAT.
+// Servers that do not implement FEAT are treated as supporting none.
+func (c *Client) loadFeatures() {
+	c.features = map[string]string{}
+	if err := c.control.PrintfLine("FEAT"); err != nil {
+		return
+	}
+	_, message, err := c.read(211)
+	if err != nil {
+		return
+	}
+
+	lines := strings.Split(message, "\n")
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line, " ") {
+			continue
+		}
+		name, params, _ := strings.Cut(strings.TrimSpace(line), " ")
+		c.features[strings.ToUpper(name)] = params
+	}
+}
+
+func (c *Client) supports(feature string) bool {
+	_, ok := c.features[feature]
+	return ok
+}
+
+// Ping sends NOOP to verify that the control connection is still usable.
+func (c *Client) Ping() error {
+	if err := c.control.PrintfLine("NOOP"); err != nil {
+		return err
+	}
+	_, _, err := c.read(200)
+	return err
+}
+
+// SetLimit caps the bandwidth of uploads and downloads in bytes per second.
+// Zero removes the limit.
+func (c *Client) SetLimit(bytesPerSecond int64) {
+	c.limiter = transfer.NewLimiter(bytesPerSecond)
+}
+
+// SetLimiter makes the client draw from a limiter shared with other
+// connections, so that their combined bandwidth stays within its rate.
+func (c *Client) SetLimiter(limiter *transfer.Limiter) {
+	c.limiter = limiter
+}
+
+// SetProgress installs the factory used to report the progress of uploads
+// and downloads. nil disables progress reporting.
+func (c *Client) SetProgress(progress transfer.ProgressFactory) {
+	c.progress = progress
+}
+
+// partSuffix is appended to the destination name while a transfer is in
+// progress. A partial file left behind by an interrupted transfer is picked up
+// by the next attempt, which continues where the previous one stopped, as
+// long as the source did not change in the meantime.
+const partSuffix = ".part"
+
+// sourceSuffix names the file next to a partial transfer that records the size
+// and modification time of the file it was started from.
+const sourceSuffix = ".source"
+
+// backupSuffix names the copy of a file that is being replaced on servers
+// that do not rename onto an existing file.
+const backupSuffix = ".old"
+
+// errRenameRefused is returned by Rename when the server rejects RNFR, e.g.
+// because it does not implement renaming or forbids it for the user.
+var errRenameRefused = errors.New("server refused to rename")
+
+// Upload stores a local file on the remote server. The data is written to a
+// partial file first and only renamed to remotePath once the size reported by
+// the server matches the local file.
 func (c *Client) Upload(localPath, remotePath string) error {
 	file, err := os.Open(localPath)
 	if err != nil {
@@ -94,62 +233,267 @@ func (c *Client) Upload(localPath, remotePath string) error {
 	}
 	defer file.Close()
 
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read local file: %w", err)
+	}
+	localSize := info.Size()
+
 	if err := c.ensureRemoteDir(path.Dir(remotePath)); err != nil {
 		return err
 	}
 
+	if c.renameRefused {
+		return c.store(file, "STOR "+remotePath, remotePath, 0, localSize)
+	}
+
+	// Like for downloads, the size and modification time recorded next to the
+	// partial file identify the version of the local file it belongs to.
+	source := fmt.Sprintf("%d %d\n", localSize, info.ModTime().UnixNano())
+	partPath := remotePath + partSuffix
+	sourcePath := partPath + sourceSuffix
+	offset := c.uploadOffset(partPath, sourcePath, source, localSize)
+	if offset == 0 {
+		// Without the record the partial file is not resumed next time, so
+		// a server refusing it only costs the resume.
+		c.storeRecord(sourcePath, source)
+	}
+
+	if offset < localSize || localSize == 0 {
+		command := "STOR " + partPath
+		if offset > 0 {
+			command = "APPE " + partPath
+		}
+		if err := c.store(file, command, remotePath, offset, localSize); err != nil {
+			return err
+		}
+	}
+
+	if size, err := c.Size(partPath); err == nil && size != localSize {
+		if size > localSize {
+			// The partial file cannot be resumed; start over next time.
+			c.Remove(partPath)
+			c.Remove(sourcePath)
+		}
+		return fmt.Errorf("upload of %s is incomplete: server reports %d of %d bytes", remotePath, size, localSize)
+	}
+
+	err = c.replace(partPath, remotePath)
+	if errors.Is(err, errRenameRefused) {
+		// The partial file cannot be moved into place, so the file is stored
+		// under its final name instead.
+		c.renameRefused = true
+		if err := c.store(file, "STOR "+remotePath, remotePath, 0, localSize); err != nil {
+			return err
+		}
+		c.Remove(partPath)
+		c.Remove(sourcePath)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move upload into place: %w", err)
+	}
+	c.Remove(sourcePath)
+	return nil
+}
+
+// storeRecord writes the source record of a partial upload to remotePath.
+func (c *Client) storeRecord(remotePath, record string) error {
 	dataConn, err := c.openDataConnection("STOR " + remotePath)
 	if err != nil {
 		return err
 	}
-	defer dataConn.Close()
+	if _, err := io.WriteString(dataConn, record); err != nil {
+		dataConn.Close()
+		return err
+	}
+	if err := dataConn.Close(); err != nil {
+		return err
+	}
+	return c.readTransferResponse()
+}
+
+// store sends the local file from offset on with command, which is STOR or
+// APPE, and waits for the server to confirm the transfer.
+func (c *Client) store(file *os.File, command, remotePath string, offset, size int64) error {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to resume upload: %w", err)
+	}
 
-	if _, err := io.Copy(dataConn, file); err != nil {
-		return fmt.Errorf("failed to upload file: %w", err)
+	dataConn, err := c.openDataConnection(command)
+	if err != nil {
+		return err
 	}
 
+	progress := c.progress.Start(path.Base(remotePath), size, offset)
+	_, err = io.Copy(dataConn, transfer.NewProgressReader(transfer.NewLimitedReader(file, c.limiter), offset, progress))
+	progress.Done(err)
+	if err != nil {
+		dataConn.Close()
+		return fmt.Errorf("failed to upload file, run the command again to resume: %w", err)
+	}
+
+	// The server only confirms the transfer once the data connection is closed.
+	if err := dataConn.Close(); err != nil {
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
 	return c.readTransferResponse()
 }
 
-// Download retrieves a remote file and stores it locally.
+// Download retrieves a remote file and stores it locally. Like Upload it
+// writes to a partial file, resumes it with REST when one was left behind for
+// the same version of the remote file and replaces localPath only after the
+// received size matches the remote size.
 func (c *Client) Download(remotePath, localPath string) error {
-	dataConn, err := c.openDataConnection("RETR " + remotePath)
+	// Servers without SIZE support still work, but cannot resume or verify.
+	remoteSize, err := c.Size(remotePath)
 	if err != nil {
-		return err
+		remoteSize = -1
 	}
-	defer dataConn.Close()
 
-	if err := os.MkdirAll(path.Dir(localPath), 0750); err != nil {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0750); err != nil {
 		return fmt.Errorf("failed to create local directories: %w", err)
 	}
 
-	file, err := os.Create(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to create local file: %w", err)
+	// The modification time identifies the version of the remote file a
+	// partial download belongs to; servers without MDTM are matched by size.
+	remoteTime, _ := c.ModTime(remotePath)
+	source := fmt.Sprintf("%d %d\n", remoteSize, remoteTime.Unix())
+
+	partPath := localPath + partSuffix
+	sourcePath := partPath + sourceSuffix
+	offset := downloadOffset(partPath, sourcePath, source, remoteSize)
+	if offset == 0 {
+		if err := os.WriteFile(sourcePath, []byte(source), 0640); err != nil {
+			return fmt.Errorf("failed to create local file: %w", err)
+		}
 	}
-	defer file.Close()
 
-	if _, err := io.Copy(file, dataConn); err != nil {
-		return fmt.Errorf("failed to download file: %w", err)
+	if offset < remoteSize || remoteSize <= 0 {
+		dataConn, err := c.openTransfer("RETR "+remotePath, offset)
+		if err == errRestartRejected {
+			offset = 0
+			dataConn, err = c.openTransfer("RETR "+remotePath, 0)
+		}
+		if err != nil {
+			return err
+		}
+		defer dataConn.Close()
+
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if offset > 0 {
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+		file, err := os.OpenFile(partPath, flags, 0640)
+		if err != nil {
+			return fmt.Errorf("failed to create local file: %w", err)
+		}
+		defer file.Close()
+
+		progress := c.progress.Start(path.Base(remotePath), remoteSize, offset)
+		_, err = io.Copy(transfer.NewProgressWriter(transfer.NewLimitedWriter(file, c.limiter), offset, progress), dataConn)
+		progress.Done(err)
+		if err != nil {
+			return fmt.Errorf("failed to download file, run the command again to resume: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write local file: %w", err)
+		}
+
+		if err := c.readTransferResponse(); err != nil {
+			return err
+		}
 	}
 
-	return c.readTransferResponse()
+	if remoteSize >= 0 {
+		info, err := os.Stat(partPath)
+		if err != nil {
+			return fmt.Errorf("failed to read local file: %w", err)
+		}
+		if info.Size() != remoteSize {
+			return fmt.Errorf("download of %s is incomplete: received %d of %d bytes", remotePath, info.Size(), remoteSize)
+		}
+	}
+
+	if err := os.Rename(partPath, localPath); err != nil {
+		return fmt.Errorf("failed to move download into place: %w", err)
+	}
+	os.Remove(sourcePath)
+	return nil
+}
+
+// uploadOffset returns how much of a partial upload can be kept. It is only
+// continued when the record in sourcePath shows that it was started from the
+// same size and modification time of the local file; otherwise the upload
+// starts over.
+func (c *Client) uploadOffset(partPath, sourcePath, source string, localSize int64) int64 {
+	var recorded strings.Builder
+	if err := c.Retrieve(sourcePath, &recorded); err != nil || recorded.String() != source {
+		return 0
+	}
+	size, err := c.Size(partPath)
+	if err != nil || size > localSize {
+		return 0
+	}
+	return size
+}
+
+// downloadOffset returns how much of a partial download can be kept. It is
+// only continued when the remote file still has the size and modification
+// time recorded in sourcePath when the download started.
+func downloadOffset(partPath, sourcePath, source string, remoteSize int64) int64 {
+	if remoteSize < 0 {
+		return 0
+	}
+	recorded, err := os.ReadFile(sourcePath)
+	if err != nil || string(recorded) != source {
+		return 0
+	}
+	info, err := os.Stat(partPath)
+	if err != nil || info.Size() > remoteSize {
+		return 0
+	}
+	return info.Size()
+}
+
+// Size returns the size of a remote file as reported by the SIZE command.
+func (c *Client) Size(remotePath string) (int64, error) {
+	if err := c.control.PrintfLine("SIZE %s", remotePath); err != nil {
+		return 0, err
+	}
+	_, message, err := c.read(213)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(message), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid SIZE response: %s", message)
+	}
+	return size, nil
 }
 
-// List returns a directory listing.
+// List returns a directory listing. MLSD is used when the server advertises
+// it because its output is machine readable; otherwise the LIST output is
+// parsed heuristically.
 func (c *Client) List(path string) ([]Entry, error) {
-	dataConn, err := c.openDataConnection("LIST " + path)
+	command, parse := "LIST ", parseListLine
+	if c.supports("MLST") {
+		command, parse = "MLSD ", parseMLSxLine
+	}
+
+	dataConn, err := c.openDataConnection(command + path)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 	defer dataConn.Close()
 
 	scanner := bufio.NewScanner(dataConn)
 	entries := []Entry{}
 	for scanner.Scan() {
-		raw := scanner.Text()
-		entry := parseListLine(raw)
-		entries = append(entries, entry)
+		if entry, ok := parse(scanner.Text()); ok {
+			entries = append(entries, entry)
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -163,113 +507,284 @@ func (c *Client) List(path string) ([]Entry, error) {
 	return entries, nil
 }
 
-func (c *Client) startTLS() error {
-	if err := c.control.PrintfLine("AUTH TLS"); err != nil {
-		return err
+// Stat returns the metadata of a single remote path. MLST is used when the
+// server supports it; otherwise the entry is looked up in the listing of the
+// parent directory.
+func (c *Client) Stat(remotePath string) (Entry, error) {
+	if !c.supports("MLST") {
+		return c.statFromList(remotePath)
 	}
-	if _, _, err := c.read(234); err != nil {
+
+	if err := c.control.PrintfLine("MLST %s", remotePath); err != nil {
+		return Entry{}, err
+	}
+	_, message, err := c.read(250)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to stat %s: %w", remotePath, err)
+	}
+
+	// The facts are sent on the single indented line between the first and
+	// the last line of the reply.
+	for _, line := range strings.Split(message, "\n")[1:] {
+		if !strings.HasPrefix(line, " ") {
+			continue
+		}
+		if entry, ok := parseMLSxLine(strings.TrimPrefix(line, " ")); ok {
+			entry.Name = remotePath
+			return entry, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("invalid MLST response: %s", message)
+}
+
+// statFromList finds remotePath in the LIST output of its parent directory.
+// The size and modification time of files are taken from SIZE and MDTM when
+// the server supports them, as they are more precise than the listing.
+func (c *Client) statFromList(remotePath string) (Entry, error) {
+	cleaned := path.Clean(remotePath)
+	if cleaned == "/" {
+		return Entry{Name: remotePath, IsDir: true}, nil
+	}
+
+	entries, err := c.List(path.Dir(cleaned))
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to stat %s: %w", remotePath, err)
+	}
+	for _, entry := range entries {
+		if entry.Name != path.Base(cleaned) {
+			continue
+		}
+		entry.Name = remotePath
+		if !entry.IsDir {
+			if size, err := c.Size(remotePath); err == nil {
+				entry.Size = size
+			}
+			if modTime, err := c.ModTime(remotePath); err == nil {
+				entry.ModTime = modTime
+			}
+		}
+		return entry, nil
+	}
+	return Entry{}, fmt.Errorf("failed to stat %s: %w", remotePath, fs.ErrNotExist)
+}
+
+// Retrieve streams a remote file into w.
+func (c *Client) Retrieve(remotePath string, w io.Writer) error {
+	dataConn, err := c.openDataConnection("RETR " + remotePath)
+	if err != nil {
 		return err
 	}
+	defer dataConn.Close()
 
-	tlsConfig := &tls.Config{InsecureSkipVerify: true}
-	tlsConn := tls.Client(c.conn, tlsConfig)
-	if err := tlsConn.Handshake(); err != nil {
+	if _, err := io.Copy(w, dataConn); err != nil {
+		return fmt.Errorf("failed to read remote file: %w", err)
+	}
+
+	return c.readTransferResponse()
+}
+
+// Remove deletes a remote file.
+func (c *Client) Remove(remotePath string) error {
+	if err := c.control.PrintfLine("DELE %s", remotePath); err != nil {
 		return err
 	}
+	if _, _, err := c.read(250); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", remotePath, err)
+	}
+	return nil
+}
 
-	c.conn = tlsConn
-	c.control = textproto.NewConn(tlsConn)
-	c.tlsConfig = tlsConfig
+// RemoveDir deletes an empty remote directory.
+func (c *Client) RemoveDir(dir string) error {
+	if err := c.control.PrintfLine("RMD %s", dir); err != nil {
+		return err
+	}
+	if _, _, err := c.read(250); err != nil {
+		return fmt.Errorf("failed to remove directory %s: %w", dir, err)
+	}
+	for known := range c.knownDirs {
+		if known == dir || strings.HasPrefix(known, dir+"/") {
+			delete(c.knownDirs, known)
+		}
+	}
 	return nil
 }
 
-func (c *Client) sendUser(username string) (int, error) {
-	if err := c.control.PrintfLine("USER %s", username); err != nil {
-		return 0, err
+// RemoveAll deletes a remote directory together with its contents.
+func (c *Client) RemoveAll(dir string) error {
+	entries, err := c.List(dir)
+	if err != nil {
+		return err
 	}
-	code, _, err := c.read(331, 230)
-	return code, err
+
+	for _, entry := range entries {
+		name := path.Base(entry.Name)
+		if name == "." || name == ".." {
+			continue
+		}
+		child := path.Join(dir, name)
+		if entry.IsDir {
+			err = c.RemoveAll(child)
+		} else {
+			err = c.Remove(child)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.RemoveDir(dir)
 }
 
-func (c *Client) sendPass(password string) error {
-	if password == "" {
-		return fmt.Errorf("password is required for FTP session '%s'", c.session.Alias)
+// Mkdir creates a remote directory including any missing parents.
+func (c *Client) Mkdir(dir string) error {
+	return c.ensureRemoteDir(dir)
+}
+
+// Rename moves a remote file or directory.
+func (c *Client) Rename(oldPath, newPath string) error {
+	if err := c.sendRename(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %w", oldPath, newPath, err)
 	}
-	if err := c.control.PrintfLine("PASS %s", password); err != nil {
+	return nil
+}
+
+// replace moves oldPath to newPath. Servers that refuse to overwrite an
+// existing file get the target moved aside first; it is only deleted once the
+// new file took its place and restored otherwise. An error wrapping
+// errRenameRefused means the server does not allow renaming oldPath at all.
+func (c *Client) replace(oldPath, newPath string) error {
+	err := c.Rename(oldPath, newPath)
+	if err == nil || errors.Is(err, errRenameRefused) {
 		return err
 	}
-	_, _, err := c.read(230)
-	return err
+
+	backup := newPath + backupSuffix
+	if c.sendRename(newPath, backup) != nil {
+		return err
+	}
+	if err := c.Rename(oldPath, newPath); err != nil {
+		c.sendRename(backup, newPath)
+		return err
+	}
+	c.Remove(backup)
+	return nil
 }
 
-func (c *Client) openDataConnection(command string) (net.Conn, error) {
-	host, port, err := c.enterPassiveMode()
+// Chmod changes the permission bits of a remote path with SITE CHMOD, which
+// most Unix servers support.
+func (c *Client) Chmod(remotePath string, mode os.FileMode) error {
+	if err := c.control.PrintfLine("SITE CHMOD %o %s", mode.Perm(), remotePath); err != nil {
+		return err
+	}
+	if _, _, err := c.read(200); err != nil {
+		return fmt.Errorf("failed to change mode of %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// ModTime returns the modification time of a remote file using MDTM.
+func (c *Client) ModTime(remotePath string) (time.Time, error) {
+	if err := c.control.PrintfLine("MDTM %s", remotePath); err != nil {
+		return time.Time{}, err
+	}
+	_, message, err := c.read(213)
 	if err != nil {
-		return nil, err
+		return time.Time{}, err
 	}
 
-	dataConn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), 10*time.Second)
+	value := strings.TrimSpace(message)
+	if len(value) < 14 {
+		return time.Time{}, fmt.Errorf("invalid MDTM response: %s", message)
+	}
+	t, err := time.Parse("20060102150405", value[:14])
 	if err != nil {
-		return nil, fmt.Errorf("failed to open data connection: %w", err)
+		return time.Time{}, fmt.Errorf("invalid MDTM response: %s", message)
 	}
+	return t, nil
+}
 
-	if c.tlsConfig != nil {
-		dataConn = tls.Client(dataConn, c.tlsConfig)
-		if err := dataConn.(*tls.Conn).Handshake(); err != nil {
-			dataConn.Close()
-			return nil, fmt.Errorf("failed to establish TLS data connection: %w", err)
+func (c *Client) sendRename(oldPath, newPath string) error {
+	if err := c.control.PrintfLine("RNFR %s", oldPath); err != nil {
+		return err
+	}
+	if _, _, err := c.read(350); err != nil {
+		if _, ok := err.(*textproto.Error); ok {
+			return fmt.Errorf("%w: %w", errRenameRefused, err)
 		}
+		return err
 	}
-
-	if err := c.control.PrintfLine(command); err != nil {
-		dataConn.Close()
-		return nil, err
+	if err := c.control.PrintfLine("RNTO %s", newPath); err != nil {
+		return err
 	}
+	_, _, err := c.read(250)
+	return err
+}
 
-	if _, _, err := c.read(125, 150); err != nil {
-		dataConn.Close()
-		return nil, err
+func (c *Client) startTLS(tlsConfig *tls.Config) error {
+	if err := c.control.PrintfLine("AUTH TLS"); err != nil {
+		return err
+	}
+	if _, _, err := c.read(234); err != nil {
+		return err
 	}
 
-	return dataConn, nil
+	return c.secureControlConnection(tlsConfig)
 }
 
-func (c *Client) readTransferResponse() error {
-	_, _, err := c.read(226, 250)
-	return err
+func (c *Client) secureControlConnection(tlsConfig *tls.Config) error {
+	tlsConn := tls.Client(c.conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake failed: %w", err)
+	}
+
+	c.conn = tlsConn
+	c.control = textproto.NewConn(tlsConn)
+	c.tlsConfig = tlsConfig
+	return nil
 }
 
-func (c *Client) enterPassiveMode() (string, int, error) {
-	if err := c.control.PrintfLine("PASV"); err != nil {
-		return "", 0, err
+// protectDataChannel asks the server to encrypt data connections as well.
+// Without PROT P, servers transfer file contents in clear text even when the
+// control connection uses TLS.
+func (c *Client) protectDataChannel() error {
+	if err := c.control.PrintfLine("PBSZ 0"); err != nil {
+		return err
 	}
-	_, message, err := c.read(227)
-	if err != nil {
-		return "", 0, err
+	if _, _, err := c.read(200); err != nil {
+		return fmt.Errorf("server rejected PBSZ: %w", err)
 	}
-
-	start := strings.Index(message, "(")
-	end := strings.Index(message, ")")
-	if start == -1 || end == -1 || end <= start+1 {
-		return "", 0, fmt.Errorf("invalid PASV response: %s", message)
+	if err := c.control.PrintfLine("PROT P"); err != nil {
+		return err
+	}
+	if _, _, err := c.read(200); err != nil {
+		return fmt.Errorf("server rejected PROT P: %w", err)
 	}
+	return nil
+}
 
-	parts := strings.Split(message[start+1:end], ",")
-	if len(parts) != 6 {
-		return "", 0, fmt.Errorf("unexpected PASV response: %s", message)
+func (c *Client) sendUser(username string) (int, error) {
+	if err := c.control.PrintfLine("USER %s", username); err != nil {
+		return 0, err
 	}
+	code, _, err := c.read(331, 230)
+	return code, err
+}
 
-	host := strings.Join(parts[0:4], ".")
-	p1, err := strconv.Atoi(parts[4])
-	if err != nil {
-		return "", 0, fmt.Errorf("invalid PASV port: %w", err)
+func (c *Client) sendPass(password string) error {
+	if password == "" {
+		return fmt.Errorf("password is required for FTP session '%s'", c.session.Alias)
 	}
-	p2, err := strconv.Atoi(parts[5])
-	if err != nil {
-		return "", 0, fmt.Errorf("invalid PASV port: %w", err)
+	if err := c.control.PrintfLine("PASS %s", password); err != nil {
+		return err
 	}
+	_, _, err := c.read(230)
+	return err
+}
 
-	return host, p1*256 + p2, nil
+func (c *Client) readTransferResponse() error {
+	_, _, err := c.read(226, 250)
+	return err
 }
 
 func (c *Client) ensureRemoteDir(dir string) error {
@@ -277,61 +792,49 @@ func (c *Client) ensureRemoteDir(dir string) error {
 		return nil
 	}
 
-	segments := strings.Split(dir, "/")
-	path := ""
-	for _, segment := range segments {
-		if segment == "" {
+	if c.knownDirs == nil {
+		c.knownDirs = map[string]bool{}
+	}
+
+	// Relative directories are created below the working directory.
+	current := ""
+	if strings.HasPrefix(dir, "/") {
+		current = "/"
+	}
+	for _, segment := range strings.Split(dir, "/") {
+		if segment == "" || segment == "." {
 			continue
 		}
-		path += "/" + segment
-		if err := c.control.PrintfLine("MKD %s", path); err != nil {
+		current = path.Join(current, segment)
+		if c.knownDirs[current] {
+			continue
+		}
+		if err := c.control.PrintfLine("MKD %s", current); err != nil {
 			return err
 		}
 		if _, _, err := c.read(257, 550); err != nil {
 			return err
 		}
+		c.knownDirs[current] = true
 	}
 	return nil
 }
 
-func parseListLine(raw string) Entry {
-	entry := Entry{Raw: raw}
-	fields := strings.Fields(raw)
-	if len(fields) >= 9 {
-		entry.Name = strings.Join(fields[8:], " ")
-		entry.IsDir = strings.HasPrefix(fields[0], "d")
-		size, _ := strconv.ParseInt(fields[4], 10, 64)
-		entry.Size = size
-		dateParts := fields[5:8]
-		parsedTime := parseListTime(dateParts)
-		if !parsedTime.IsZero() {
-			entry.ModTime = parsedTime
-		}
-	} else {
-		entry.Name = raw
-	}
-	return entry
-}
-
-func parseListTime(parts []string) time.Time {
-	if len(parts) != 3 {
-		return time.Time{}
+// notFound marks a reply reporting a missing path with fs.ErrNotExist.
+// Servers use 450 and 550 for permission problems as well, so only replies
+// whose text says that the path does not exist qualify.
+func notFound(err error) error {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) || (protoErr.Code != 450 && protoErr.Code != 550) {
+		return err
 	}
-	layout := "Jan 2 15:04"
-	value := strings.Join(parts, " ")
-	if strings.Contains(parts[2], ":") {
-		t, err := time.Parse(layout, value)
-		if err == nil {
-			return t
-		}
-	} else {
-		layout = "Jan 2 2006"
-		t, err := time.Parse(layout, value)
-		if err == nil {
-			return t
+	text := strings.ToLower(protoErr.Msg)
+	for _, phrase := range []string{"no such file", "not found", "does not exist", "doesn't exist", "not exist", "cannot find", "can't find"} {
+		if strings.Contains(text, phrase) {
+			return fmt.Errorf("%w (%w)", err, fs.ErrNotExist)
 		}
 	}
-	return time.Time{}
+	return err
 }
 
 func (c *Client) read(expected ...int) (int, string, error) {
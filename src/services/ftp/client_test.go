@@ -0,0 +1,183 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"servercommander/src/services/config"
+)
+
+func TestDownloadOffset(t *testing.T) {
+	const source = "10 1700000000\n"
+
+	tests := []struct {
+		name       string
+		part       string
+		recorded   string
+		source     string
+		remoteSize int64
+		want       int64
+	}{
+		{name: "same version", part: "abcd", recorded: source, source: source, remoteSize: 10, want: 4},
+		{name: "complete part", part: "0123456789", recorded: source, source: source, remoteSize: 10, want: 10},
+		{name: "remote file changed", part: "abcd", recorded: source, source: "10 1700000060\n", remoteSize: 10, want: 0},
+		{name: "part larger than remote file", part: strings.Repeat("x", 11), recorded: source, source: source, remoteSize: 10, want: 0},
+		{name: "no source record", part: "abcd", source: source, remoteSize: 10, want: 0},
+		{name: "no part file", recorded: source, source: source, remoteSize: 10, want: 0},
+		{name: "unknown remote size", part: "abcd", recorded: "-1 0\n", source: "-1 0\n", remoteSize: -1, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			partPath := filepath.Join(dir, "file"+partSuffix)
+			sourcePath := partPath + sourceSuffix
+			if tt.part != "" {
+				if err := os.WriteFile(partPath, []byte(tt.part), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.recorded != "" {
+				if err := os.WriteFile(sourcePath, []byte(tt.recorded), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := downloadOffset(partPath, sourcePath, tt.source, tt.remoteSize); got != tt.want {
+				t.Errorf("downloadOffset = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// serveData accepts a single data connection on a local port, sends data on
+// it and returns the port.
+func serveData(t *testing.T, data string) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte(data))
+		conn.Close()
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestUploadOffset(t *testing.T) {
+	const source = "10 1700000000000000000\n"
+
+	tests := []struct {
+		name     string
+		recorded string
+		// retr and size are the replies to RETR of the record and SIZE of
+		// the partial file; an empty size means it is not asked for.
+		retr string
+		size string
+		want int64
+	}{
+		{name: "same version", recorded: source, retr: "150 Opening\r\n226 Done", size: "213 4", want: 4},
+		{name: "local file changed", recorded: "10 1700000060000000000\n", retr: "150 Opening\r\n226 Done", want: 0},
+		{name: "no source record", retr: "550 Not found", want: 0},
+		{name: "part larger than local file", recorded: source, retr: "150 Opening\r\n226 Done", size: "213 11", want: 0},
+		{name: "part without size", recorded: source, retr: "150 Opening\r\n226 Done", size: "550 Not found", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serveData(t, tt.recorded)
+			script := []exchange{
+				{"EPSV", fmt.Sprintf("229 Entering Extended Passive Mode (|||%d|)", port)},
+				{"RETR /f.part.source", tt.retr},
+			}
+			if tt.size != "" {
+				script = append(script, exchange{"SIZE /f.part", tt.size})
+			}
+			client := newScriptedClient(t, config.Session{Host: "127.0.0.1"}, script)
+
+			if got := client.uploadOffset("/f.part", "/f.part.source", source, 10); got != tt.want {
+				t.Errorf("uploadOffset = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatWithoutMLST(t *testing.T) {
+	const listing = "drwxr-xr-x 2 www staff 4096 Jan 3 2024 html\r\n" +
+		"-rw-r--r-- 1 www staff 12 Jan 3 2024 index.html\r\n"
+
+	tests := []struct {
+		name   string
+		remote string
+		// extra are the exchanges after the listing.
+		extra []exchange
+		want  Entry
+		err   error
+	}{
+		{
+			name:   "directory",
+			remote: "/www/html/",
+			want:   Entry{Name: "/www/html/", Size: 4096, ModTime: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), IsDir: true, Permissions: "drwxr-xr-x", Owner: "www", Group: "staff"},
+		},
+		{
+			name:   "file with size and mdtm",
+			remote: "/www/index.html",
+			extra:  []exchange{{"SIZE /www/index.html", "213 12"}, {"MDTM /www/index.html", "213 20240103044100"}},
+			want:   Entry{Name: "/www/index.html", Size: 12, ModTime: time.Date(2024, 1, 3, 4, 41, 0, 0, time.UTC), Permissions: "-rw-r--r--", Owner: "www", Group: "staff"},
+		},
+		{
+			name:   "file without size and mdtm",
+			remote: "/www/index.html",
+			extra:  []exchange{{"SIZE /www/index.html", "502 Not implemented"}, {"MDTM /www/index.html", "502 Not implemented"}},
+			want:   Entry{Name: "/www/index.html", Size: 12, ModTime: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Permissions: "-rw-r--r--", Owner: "www", Group: "staff"},
+		},
+		{
+			name:   "missing entry",
+			remote: "/www/missing",
+			err:    fs.ErrNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serveData(t, listing)
+			script := append([]exchange{
+				{"EPSV", fmt.Sprintf("229 Entering Extended Passive Mode (|||%d|)", port)},
+				{"LIST /www", "150 Opening\r\n226 Done"},
+			}, tt.extra...)
+			client := newScriptedClient(t, config.Session{Host: "127.0.0.1"}, script)
+
+			got, err := client.Stat(tt.remote)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Stat error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			got.Raw = ""
+			if err != nil || got != tt.want {
+				t.Errorf("Stat = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatRoot(t *testing.T) {
+	client := newScriptedClient(t, config.Session{Host: "127.0.0.1"}, nil)
+	if got, err := client.Stat("/"); err != nil || !got.IsDir {
+		t.Errorf("Stat(/) = %+v, %v, want a directory", got, err)
+	}
+}
@@ -0,0 +1,249 @@
+package ftp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"servercommander/src/services/config"
+)
+
+// errRestartRejected is returned by openTransfer when the server does not
+// accept the REST offset, in which case the transfer has to start over.
+var errRestartRejected = errors.New("server rejected the restart offset")
+
+func (c *Client) openDataConnection(command string) (net.Conn, error) {
+	return c.openTransfer(command, 0)
+}
+
+// openTransfer opens a data connection for command. A non-zero offset is sent
+// with REST directly before the command so the transfer starts at that byte.
+func (c *Client) openTransfer(command string, offset int64) (net.Conn, error) {
+	if c.session.DataMode == config.DataModeActive {
+		return c.openActiveTransfer(command, offset)
+	}
+
+	address, err := c.passiveAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	dataConn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data connection: %w", err)
+	}
+
+	if err := c.sendTransferCommand(command, offset); err != nil {
+		dataConn.Close()
+		return nil, err
+	}
+
+	// Servers only start the TLS handshake on the data connection once they
+	// have accepted the transfer command.
+	return c.secureDataConnection(dataConn)
+}
+
+// openActiveTransfer listens on the local address of the control connection,
+// announces it with EPRT or PORT and waits for the server to connect.
+func (c *Client) openActiveTransfer(command string, offset int64) (net.Conn, error) {
+	localHost, _, err := net.SplitHostPort(c.conn.LocalAddr().String())
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(localHost, "0"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for data connection: %w", err)
+	}
+	defer listener.Close()
+
+	if err := c.announceActivePort(listener.Addr().(*net.TCPAddr)); err != nil {
+		return nil, err
+	}
+
+	if err := c.sendTransferCommand(command, offset); err != nil {
+		return nil, err
+	}
+
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(10 * time.Second))
+	dataConn, err := listener.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("server did not open the data connection: %w", err)
+	}
+
+	return c.secureDataConnection(dataConn)
+}
+
+// secureDataConnection wraps the data connection in TLS when the control
+// connection is protected.
+func (c *Client) secureDataConnection(dataConn net.Conn) (net.Conn, error) {
+	if c.tlsConfig == nil {
+		return dataConn, nil
+	}
+
+	tlsConn := tls.Client(dataConn, c.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		dataConn.Close()
+		return nil, fmt.Errorf("failed to establish TLS data connection: %w", err)
+	}
+	return tlsConn, nil
+}
+
+func (c *Client) sendTransferCommand(command string, offset int64) error {
+	if offset > 0 {
+		if err := c.control.PrintfLine("REST %d", offset); err != nil {
+			return err
+		}
+		if _, _, err := c.read(350); err != nil {
+			if _, ok := err.(*textproto.Error); ok {
+				return errRestartRejected
+			}
+			return err
+		}
+	}
+
+	if err := c.control.PrintfLine(command); err != nil {
+		return err
+	}
+
+	_, _, err := c.read(125, 150)
+	return err
+}
+
+// passiveAddress asks the server for a passive data port using EPSV and falls
+// back to PASV for servers that do not support it.
+func (c *Client) passiveAddress() (string, error) {
+	if !c.epsvRejected {
+		address, err := c.extendedPassiveAddress()
+		if err == nil {
+			return address, nil
+		}
+		if _, ok := err.(*textproto.Error); !ok {
+			return "", err
+		}
+		c.epsvRejected = true
+	}
+
+	return c.enterPassiveMode()
+}
+
+// extendedPassiveAddress issues EPSV. The response only carries a port; the
+// host is the one the control connection is connected to.
+func (c *Client) extendedPassiveAddress() (string, error) {
+	if err := c.control.PrintfLine("EPSV"); err != nil {
+		return "", err
+	}
+	_, message, err := c.read(229)
+	if err != nil {
+		return "", err
+	}
+
+	start := strings.Index(message, "(")
+	end := strings.LastIndex(message, ")")
+	if start == -1 || end <= start+1 {
+		return "", fmt.Errorf("invalid EPSV response: %s", message)
+	}
+
+	// The port is enclosed in a delimiter character, usually "|||port|".
+	fields := strings.Split(message[start+1:end], message[start+1:start+2])
+	if len(fields) != 5 {
+		return "", fmt.Errorf("unexpected EPSV response: %s", message)
+	}
+	port, err := strconv.Atoi(fields[3])
+	if err != nil || port <= 0 || port > 65535 {
+		return "", fmt.Errorf("invalid EPSV port: %s", fields[3])
+	}
+
+	return net.JoinHostPort(c.controlHost(), strconv.Itoa(port)), nil
+}
+
+func (c *Client) enterPassiveMode() (string, error) {
+	if err := c.control.PrintfLine("PASV"); err != nil {
+		return "", err
+	}
+	_, message, err := c.read(227)
+	if err != nil {
+		return "", err
+	}
+
+	start := strings.Index(message, "(")
+	end := strings.Index(message, ")")
+	if start == -1 || end == -1 || end <= start+1 {
+		return "", fmt.Errorf("invalid PASV response: %s", message)
+	}
+
+	parts := strings.Split(message[start+1:end], ",")
+	if len(parts) != 6 {
+		return "", fmt.Errorf("unexpected PASV response: %s", message)
+	}
+
+	host := strings.Join(parts[0:4], ".")
+	p1, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return "", fmt.Errorf("invalid PASV port: %w", err)
+	}
+	p2, err := strconv.Atoi(parts[5])
+	if err != nil {
+		return "", fmt.Errorf("invalid PASV port: %w", err)
+	}
+
+	// Servers behind NAT often announce their internal address. It is
+	// unreachable from outside, so the address of the control connection is
+	// used instead.
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() || (isInternalIP(ip) && !isInternalIP(net.ParseIP(c.controlHost()))) {
+		host = c.controlHost()
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(p1*256+p2)), nil
+}
+
+// announceActivePort tells the server where to connect using EPRT, or PORT
+// for servers that only support IPv4 commands.
+func (c *Client) announceActivePort(address *net.TCPAddr) error {
+	if !c.eprtRejected {
+		family := 1
+		if address.IP.To4() == nil {
+			family = 2
+		}
+		if err := c.control.PrintfLine("EPRT |%d|%s|%d|", family, address.IP.String(), address.Port); err != nil {
+			return err
+		}
+		_, _, err := c.read(200)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(*textproto.Error); !ok {
+			return err
+		}
+		c.eprtRejected = true
+	}
+
+	ip := address.IP.To4()
+	if ip == nil {
+		return fmt.Errorf("server does not support EPRT, which active mode over IPv6 requires")
+	}
+	if err := c.control.PrintfLine("PORT %d,%d,%d,%d,%d,%d", ip[0], ip[1], ip[2], ip[3], address.Port/256, address.Port%256); err != nil {
+		return err
+	}
+	_, _, err := c.read(200)
+	return err
+}
+
+// controlHost returns the address of the server the control connection is
+// connected to.
+func (c *Client) controlHost() string {
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return c.session.Host
+	}
+	return host
+}
+
+func isInternalIP(ip net.IP) bool {
+	return ip != nil && (ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast())
+}
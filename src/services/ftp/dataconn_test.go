@@ -0,0 +1,211 @@
+package ftp
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"servercommander/src/services/config"
+)
+
+// exchange is a command the scripted server expects and the reply it sends.
+type exchange struct {
+	command string
+	reply   string
+}
+
+// newScriptedClient returns a client whose control connection is answered by
+// script. The pipe has no network address, so controlHost falls back to the
+// session host.
+func newScriptedClient(t *testing.T, session config.Session, script []exchange) *Client {
+	t.Helper()
+	clientSide, serverSide := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server := textproto.NewConn(serverSide)
+		for _, step := range script {
+			line, err := server.ReadLine()
+			if err != nil {
+				t.Errorf("server expected %q: %v", step.command, err)
+				return
+			}
+			if line != step.command {
+				t.Errorf("server received %q, want %q", line, step.command)
+			}
+			if err := server.PrintfLine("%s", step.reply); err != nil {
+				t.Errorf("server reply to %q: %v", step.command, err)
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		clientSide.Close()
+		serverSide.Close()
+		<-done
+	})
+
+	return &Client{session: session, control: textproto.NewConn(clientSide), conn: clientSide}
+}
+
+func TestPassiveAddress(t *testing.T) {
+	tests := []struct {
+		name   string
+		host   string
+		script []exchange
+		want   string
+		err    string
+		// rejected reports whether EPSV must be skipped afterwards.
+		rejected bool
+	}{
+		{
+			name:   "epsv",
+			host:   "ftp.example.com",
+			script: []exchange{{"EPSV", "229 Entering Extended Passive Mode (|||6446|)"}},
+			want:   "ftp.example.com:6446",
+		},
+		{
+			name:   "epsv with other delimiter",
+			host:   "2001:db8::1",
+			script: []exchange{{"EPSV", "229 Extended Passive (!!!50000!)"}},
+			want:   "[2001:db8::1]:50000",
+		},
+		{
+			name:   "epsv invalid port",
+			host:   "ftp.example.com",
+			script: []exchange{{"EPSV", "229 Entering Extended Passive Mode (|||0|)"}},
+			err:    "invalid EPSV port",
+		},
+		{
+			name:   "epsv without port",
+			host:   "ftp.example.com",
+			script: []exchange{{"EPSV", "229 Entering Extended Passive Mode"}},
+			err:    "invalid EPSV response",
+		},
+		{
+			name: "epsv rejected falls back to pasv",
+			host: "203.0.113.5",
+			script: []exchange{
+				{"EPSV", "500 Unknown command"},
+				{"PASV", "227 Entering Passive Mode (198,51,100,7,19,137)"},
+			},
+			want:     "198.51.100.7:5001",
+			rejected: true,
+		},
+		{
+			name: "pasv internal address behind nat",
+			host: "203.0.113.5",
+			script: []exchange{
+				{"EPSV", "502 Not implemented"},
+				{"PASV", "227 Entering Passive Mode (192,168,1,10,19,137)"},
+			},
+			want:     "203.0.113.5:5001",
+			rejected: true,
+		},
+		{
+			name: "pasv internal address on internal network",
+			host: "10.0.0.1",
+			script: []exchange{
+				{"EPSV", "502 Not implemented"},
+				{"PASV", "227 Entering Passive Mode (192,168,1,10,4,1)"},
+			},
+			want:     "192.168.1.10:1025",
+			rejected: true,
+		},
+		{
+			name: "pasv without parentheses",
+			host: "ftp.example.com",
+			script: []exchange{
+				{"EPSV", "502 Not implemented"},
+				{"PASV", "227 =0,0,0,0,0,21"},
+			},
+			err:      "invalid PASV response",
+			rejected: true,
+		},
+		{
+			name: "pasv zero address uses control host",
+			host: "ftp.example.com",
+			script: []exchange{
+				{"EPSV", "502 Not implemented"},
+				{"PASV", "227 Entering Passive Mode (0,0,0,0,0,21)."},
+			},
+			want:     "ftp.example.com:21",
+			rejected: true,
+		},
+		{
+			name: "pasv malformed",
+			host: "ftp.example.com",
+			script: []exchange{
+				{"EPSV", "502 Not implemented"},
+				{"PASV", "227 Entering Passive Mode (10,0,0,1,19)"},
+			},
+			err:      "unexpected PASV response",
+			rejected: true,
+		},
+		{
+			name: "pasv rejected",
+			host: "ftp.example.com",
+			script: []exchange{
+				{"EPSV", "502 Not implemented"},
+				{"PASV", "421 Too many connections"},
+			},
+			err:      "Too many connections",
+			rejected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newScriptedClient(t, config.Session{Host: tt.host}, tt.script)
+			got, err := client.passiveAddress()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("passiveAddress error = %v, want %q", err, tt.err)
+				}
+			} else if err != nil || got != tt.want {
+				t.Errorf("passiveAddress = %q, %v, want %q", got, err, tt.want)
+			}
+			if client.epsvRejected != tt.rejected {
+				t.Errorf("epsvRejected = %v, want %v", client.epsvRejected, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestPassiveAddressRemembersRejectedEPSV(t *testing.T) {
+	client := newScriptedClient(t, config.Session{Host: "ftp.example.com"}, []exchange{
+		{"EPSV", "500 Unknown command"},
+		{"PASV", "227 Entering Passive Mode (0,0,0,0,4,0)"},
+		{"PASV", "227 Entering Passive Mode (0,0,0,0,4,1)"},
+	})
+
+	for _, want := range []string{"ftp.example.com:1024", "ftp.example.com:1025"} {
+		if got, err := client.passiveAddress(); err != nil || got != want {
+			t.Errorf("passiveAddress = %q, %v, want %q", got, err, want)
+		}
+	}
+}
+
+func TestIsInternalIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "10.1.2.3", want: true},
+		{ip: "172.16.0.1", want: true},
+		{ip: "192.168.0.1", want: true},
+		{ip: "127.0.0.1", want: true},
+		{ip: "169.254.1.1", want: true},
+		{ip: "fd00::1", want: true},
+		{ip: "203.0.113.5", want: false},
+		{ip: "2001:db8::1", want: false},
+		{ip: "not an ip", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isInternalIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isInternalIP(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
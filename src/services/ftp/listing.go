@@ -0,0 +1,319 @@
+package ftp
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseListLine parses one line of LIST output. The format of LIST is not
+// standardised, so the common formats are tried in turn: Unix "ls -l" style,
+// the DOS style used by IIS and EPLF. Lines in an unknown format are kept
+// with the whole line as name so they remain visible.
+func parseListLine(raw string) (Entry, bool) {
+	line := strings.TrimRight(raw, "\r")
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "total ") {
+		return Entry{}, false
+	}
+
+	for _, parse := range []func(string) (Entry, bool){parseUnixLine, parseDOSLine, parseEPLFLine} {
+		if entry, ok := parse(line); ok {
+			entry.Raw = raw
+			return entry, true
+		}
+	}
+
+	return Entry{Name: strings.TrimSpace(line), Raw: raw}, true
+}
+
+// parseUnixLine handles "ls -l" style lines such as
+//
+//	drwxr-xr-x  2 www  www   4096 Oct 17 04:41 public
+//	lrwxrwxrwx  1 root root     11 Jan  3  2024 current -> releases/42
+//
+// Servers differ in whether they print the link count and the group, so the
+// date is located first and the remaining columns are derived from it.
+func parseUnixLine(line string) (Entry, bool) {
+	fields, offsets := splitFields(line)
+	if len(fields) < 6 || !isUnixMode(fields[0]) {
+		return Entry{}, false
+	}
+
+	for i := 2; i < len(fields)-1; i++ {
+		modTime, width := parseUnixDate(fields[i:])
+		if width == 0 || i+width >= len(fields) {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[i-1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		entry := Entry{
+			Name:        line[offsets[i+width]:],
+			Size:        size,
+			ModTime:     modTime,
+			IsDir:       fields[0][0] == 'd',
+			Permissions: fields[0],
+		}
+
+		owners := fields[1 : i-1]
+		if len(owners) > 0 {
+			if _, err := strconv.Atoi(owners[0]); err == nil {
+				owners = owners[1:]
+			}
+		}
+		if len(owners) > 0 {
+			entry.Owner = owners[0]
+		}
+		if len(owners) > 1 {
+			entry.Group = owners[1]
+		}
+
+		// Servers written in Go print symbolic links with an upper case L.
+		if fields[0][0] == 'l' || fields[0][0] == 'L' {
+			if name, target, found := strings.Cut(entry.Name, " -> "); found {
+				entry.Name, entry.Target = name, target
+			}
+		}
+		return entry, true
+	}
+
+	return Entry{}, false
+}
+
+// parseUnixDate recognises the date columns at the start of fields and
+// returns the time together with the number of columns it occupies.
+func parseUnixDate(fields []string) (time.Time, int) {
+	// ls --time-style=long-iso: "2024-01-03 04:41"
+	if len(fields) >= 2 {
+		if t, err := time.Parse("2006-01-02 15:04", fields[0]+" "+fields[1]); err == nil {
+			return t, 2
+		}
+	}
+
+	if len(fields) < 3 {
+		return time.Time{}, 0
+	}
+	month, day, last := fields[0], fields[1], fields[2]
+	if strings.Contains(last, ":") {
+		t := inferYear(month+" "+day, last, time.Now().UTC())
+		if t.IsZero() {
+			return time.Time{}, 0
+		}
+		return t, 3
+	}
+	t, err := time.Parse("Jan 2 2006", month+" "+day+" "+last)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return t, 3
+}
+
+// inferYear completes a "Jan 2" date with a "15:04" time. ls omits the year
+// for files modified within the last six months, so the result is the most
+// recent such date that does not lie in the future. A day of slack absorbs
+// clock and time zone differences between client and server, and the search
+// goes back far enough to find a leap year for February 29.
+func inferYear(date, clock string, now time.Time) time.Time {
+	for year := now.Year(); year >= now.Year()-4; year-- {
+		t, err := time.Parse("Jan 2 2006 15:04", fmt.Sprintf("%s %d %s", date, year, clock))
+		if err == nil && !t.After(now.Add(24*time.Hour)) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var dosLinePattern = regexp.MustCompile(`^(\d{2}-\d{2}-\d{2}(?:\d{2})?)\s+(\d{1,2}:\d{2}\s*(?:[AaPp][Mm])?)\s+(<DIR>|\d+)\s+(.+)$`)
+
+// parseDOSLine handles the listings of IIS and other Windows servers:
+//
+//	10-17-24  04:41PM       <DIR>          public
+//	10-17-24  04:41PM                 1234 index.html
+func parseDOSLine(line string) (Entry, bool) {
+	match := dosLinePattern.FindStringSubmatch(line)
+	if match == nil {
+		return Entry{}, false
+	}
+
+	entry := Entry{Name: match[4], IsDir: match[3] == "<DIR>"}
+	if !entry.IsDir {
+		entry.Size, _ = strconv.ParseInt(match[3], 10, 64)
+	}
+
+	clock := strings.ToUpper(strings.ReplaceAll(match[2], " ", ""))
+	for _, layout := range []string{"01-02-06 03:04PM", "01-02-2006 03:04PM", "01-02-06 15:04", "01-02-2006 15:04"} {
+		if t, err := time.Parse(layout, match[1]+" "+clock); err == nil {
+			entry.ModTime = t
+			break
+		}
+	}
+	return entry, true
+}
+
+// parseEPLFLine handles the Easily Parsed LIST Format:
+//
+//	+i8388621.48594,m825718503,r,s280,	djb.html
+func parseEPLFLine(line string) (Entry, bool) {
+	if !strings.HasPrefix(line, "+") {
+		return Entry{}, false
+	}
+	facts, name, found := strings.Cut(line[1:], "\t")
+	if !found {
+		return Entry{}, false
+	}
+
+	entry := Entry{Name: name}
+	unixMode, hasUnixMode := uint32(0), false
+	for _, fact := range strings.Split(facts, ",") {
+		switch {
+		case fact == "/":
+			entry.IsDir = true
+		case strings.HasPrefix(fact, "s"):
+			entry.Size, _ = strconv.ParseInt(fact[1:], 10, 64)
+		case strings.HasPrefix(fact, "m"):
+			if seconds, err := strconv.ParseInt(fact[1:], 10, 64); err == nil {
+				entry.ModTime = time.Unix(seconds, 0).UTC()
+			}
+		case strings.HasPrefix(fact, "up"):
+			if mode, err := strconv.ParseUint(fact[2:], 8, 32); err == nil {
+				unixMode, hasUnixMode = uint32(mode), true
+			}
+		}
+	}
+	// Facts come in any order, so the mode is formatted once the type is known.
+	if hasUnixMode {
+		entry.Permissions = unixModeString(unixMode, entry.IsDir)
+	}
+	return entry, true
+}
+
+// parseMLSxLine parses a line of MLSD output or the fact line of an MLST
+// reply as defined by RFC 3659:
+//
+//	type=file;size=1234;modify=20241017044100;UNIX.mode=0644; index.html
+//
+// The entries for the listed directory itself and its parent are skipped.
+func parseMLSxLine(raw string) (Entry, bool) {
+	line := strings.TrimRight(raw, "\r")
+	facts, name, found := strings.Cut(line, " ")
+	if !found {
+		return Entry{}, false
+	}
+
+	entry := Entry{Name: name, Raw: raw}
+	unixMode, hasUnixMode := uint32(0), false
+	for _, fact := range strings.Split(facts, ";") {
+		key, value, ok := strings.Cut(fact, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "type":
+			kind := strings.ToLower(value)
+			switch {
+			case kind == "cdir" || kind == "pdir":
+				return Entry{}, false
+			case kind == "dir":
+				entry.IsDir = true
+			case strings.HasPrefix(kind, "os.unix=slink") || strings.HasPrefix(kind, "os.unix=symlink"):
+				_, entry.Target, _ = strings.Cut(value, ":")
+			}
+		case "size":
+			entry.Size, _ = strconv.ParseInt(value, 10, 64)
+		case "modify":
+			if len(value) >= 14 {
+				if t, err := time.Parse("20060102150405", value[:14]); err == nil {
+					entry.ModTime = t
+				}
+			}
+		case "perm":
+			if entry.Permissions == "" {
+				entry.Permissions = value
+			}
+		case "unix.mode":
+			if mode, err := strconv.ParseUint(value, 8, 32); err == nil {
+				unixMode, hasUnixMode = uint32(mode), true
+			}
+		case "unix.owner", "unix.ownername":
+			entry.Owner = value
+		case "unix.uid":
+			if entry.Owner == "" {
+				entry.Owner = value
+			}
+		case "unix.group", "unix.groupname":
+			entry.Group = value
+		case "unix.gid":
+			if entry.Group == "" {
+				entry.Group = value
+			}
+		}
+	}
+
+	// The Unix mode is more familiar than the RFC 3659 perm letters.
+	if hasUnixMode {
+		entry.Permissions = unixModeString(unixMode, entry.IsDir)
+	}
+	return entry, true
+}
+
+// unixModeString renders numeric Unix permissions the way ls does.
+func unixModeString(mode uint32, isDir bool) string {
+	fileMode := os.FileMode(mode & 0777)
+	if isDir {
+		fileMode |= os.ModeDir
+	}
+	if mode&04000 != 0 {
+		fileMode |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		fileMode |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		fileMode |= os.ModeSticky
+	}
+	return fileMode.String()
+}
+
+func isUnixMode(field string) bool {
+	if len(field) < 10 || !strings.ContainsRune("-dlLbcps", rune(field[0])) {
+		return false
+	}
+	for _, r := range field[1:10] {
+		if !strings.ContainsRune("rwxsStTl-", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitFields splits line at runs of spaces and returns the fields together
+// with their byte offsets so that names containing spaces can be cut from the
+// original line.
+func splitFields(line string) ([]string, []int) {
+	fields := []string{}
+	offsets := []int{}
+	start := -1
+	for i, r := range line {
+		if r == ' ' || r == '\t' {
+			if start >= 0 {
+				fields = append(fields, line[start:i])
+				offsets = append(offsets, start)
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, line[start:])
+		offsets = append(offsets, start)
+	}
+	return fields, offsets
+}
@@ -0,0 +1,186 @@
+package ftp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseListLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Entry
+		skip bool
+	}{
+		{name: "blank", line: "  \r", skip: true},
+		{name: "total", line: "total 42", skip: true},
+		{
+			name: "unix directory",
+			line: "drwxr-xr-x  2 www  staff   4096 Jan  3  2024 public\r",
+			want: Entry{Name: "public", Size: 4096, ModTime: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), IsDir: true, Permissions: "drwxr-xr-x", Owner: "www", Group: "staff"},
+		},
+		{
+			name: "unix name with spaces",
+			line: "-rw-r--r--  1 www  staff  12 Feb 29  2024 my  file.txt",
+			want: Entry{Name: "my  file.txt", Size: 12, ModTime: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), Permissions: "-rw-r--r--", Owner: "www", Group: "staff"},
+		},
+		{
+			name: "unix without link count and group",
+			line: "-rw-r--r-- www 12 Mar 1 2023 a.txt",
+			want: Entry{Name: "a.txt", Size: 12, ModTime: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), Permissions: "-rw-r--r--", Owner: "www"},
+		},
+		{
+			name: "unix long iso date",
+			line: "-rw-r--r-- 1 root root 7 2024-01-03 04:41 b.txt",
+			want: Entry{Name: "b.txt", Size: 7, ModTime: time.Date(2024, 1, 3, 4, 41, 0, 0, time.UTC), Permissions: "-rw-r--r--", Owner: "root", Group: "root"},
+		},
+		{
+			name: "unix symbolic link",
+			line: "lrwxrwxrwx  1 root root     11 Jan  3  2024 current -> releases/42",
+			want: Entry{Name: "current", Size: 11, ModTime: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Permissions: "lrwxrwxrwx", Owner: "root", Group: "root", Target: "releases/42"},
+		},
+		{
+			name: "upper case link",
+			line: "Lrwxrwxrwx 1 u g 3 Jan 3 2024 x -> y",
+			want: Entry{Name: "x", Size: 3, ModTime: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Permissions: "Lrwxrwxrwx", Owner: "u", Group: "g", Target: "y"},
+		},
+		{
+			name: "dos directory",
+			line: "10-17-24  04:41PM       <DIR>          public",
+			want: Entry{Name: "public", ModTime: time.Date(2024, 10, 17, 16, 41, 0, 0, time.UTC), IsDir: true},
+		},
+		{
+			name: "dos file with four digit year",
+			line: "10-17-2024  09:05              1234 index file.html",
+			want: Entry{Name: "index file.html", Size: 1234, ModTime: time.Date(2024, 10, 17, 9, 5, 0, 0, time.UTC)},
+		},
+		{
+			name: "eplf file",
+			line: "+i8388621.48594,m825718503,r,s280,\tdjb.html",
+			want: Entry{Name: "djb.html", Size: 280, ModTime: time.Unix(825718503, 0).UTC()},
+		},
+		{
+			name: "eplf directory",
+			line: "+/,m825718503,\tpub",
+			want: Entry{Name: "pub", ModTime: time.Unix(825718503, 0).UTC(), IsDir: true},
+		},
+		{
+			name: "eplf mode before type",
+			line: "+up755,/,\tbin",
+			want: Entry{Name: "bin", IsDir: true, Permissions: "drwxr-xr-x"},
+		},
+		{
+			name: "eplf mode after type",
+			line: "+/,up1777,\ttmp",
+			want: Entry{Name: "tmp", IsDir: true, Permissions: "dtrwxrwxrwx"},
+		},
+		{
+			name: "unknown format",
+			line: "  something else  ",
+			want: Entry{Name: "something else"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseListLine(tt.line)
+			if ok == tt.skip {
+				t.Fatalf("parseListLine(%q) ok = %v, want %v", tt.line, ok, !tt.skip)
+			}
+			if tt.skip {
+				return
+			}
+			tt.want.Raw = tt.line
+			if got != tt.want {
+				t.Errorf("parseListLine(%q) =\n%+v\nwant\n%+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMLSxLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Entry
+		skip bool
+	}{
+		{name: "current directory", line: "type=cdir;modify=20241017044100; .", skip: true},
+		{name: "parent directory", line: "Type=PDir; ..", skip: true},
+		{name: "no name", line: "type=file;size=1;", skip: true},
+		{
+			name: "file",
+			line: "type=file;size=1234;modify=20241017044100.123;perm=rw; index.html\r",
+			want: Entry{Name: "index.html", Size: 1234, ModTime: time.Date(2024, 10, 17, 4, 41, 0, 0, time.UTC), Permissions: "rw"},
+		},
+		{
+			name: "name with spaces",
+			line: "type=file;size=0; a b ",
+			want: Entry{Name: "a b "},
+		},
+		{
+			name: "unix mode wins over perm",
+			line: "type=dir;perm=flcdmpe;UNIX.mode=0755;UNIX.owner=www;UNIX.group=staff; public",
+			want: Entry{Name: "public", IsDir: true, Permissions: "drwxr-xr-x", Owner: "www", Group: "staff"},
+		},
+		{
+			name: "mode before type",
+			line: "unix.mode=02755;type=dir; shared",
+			want: Entry{Name: "shared", IsDir: true, Permissions: "dgrwxr-xr-x"},
+		},
+		{
+			name: "ids when names are missing",
+			line: "type=file;unix.uid=1000;unix.gid=100; a",
+			want: Entry{Name: "a", Owner: "1000", Group: "100"},
+		},
+		{
+			name: "names win over ids",
+			line: "type=file;unix.uid=1000;unix.ownername=www;unix.gid=100;unix.groupname=staff; a",
+			want: Entry{Name: "a", Owner: "www", Group: "staff"},
+		},
+		{
+			name: "symbolic link",
+			line: "type=OS.unix=slink:/srv/releases/42;size=11; current",
+			want: Entry{Name: "current", Size: 11, Target: "/srv/releases/42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseMLSxLine(tt.line)
+			if ok == tt.skip {
+				t.Fatalf("parseMLSxLine(%q) ok = %v, want %v", tt.line, ok, !tt.skip)
+			}
+			if tt.skip {
+				return
+			}
+			tt.want.Raw = tt.line
+			if got != tt.want {
+				t.Errorf("parseMLSxLine(%q) =\n%+v\nwant\n%+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferYear(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		date  string
+		clock string
+		want  time.Time
+	}{
+		{date: "Mar 10", clock: "11:00", want: time.Date(2025, 3, 10, 11, 0, 0, 0, time.UTC)},
+		{date: "Mar 11", clock: "08:00", want: time.Date(2025, 3, 11, 8, 0, 0, 0, time.UTC)},
+		{date: "Mar 12", clock: "08:00", want: time.Date(2024, 3, 12, 8, 0, 0, 0, time.UTC)},
+		{date: "Dec 24", clock: "18:30", want: time.Date(2024, 12, 24, 18, 30, 0, 0, time.UTC)},
+		{date: "Feb 29", clock: "04:41", want: time.Date(2024, 2, 29, 4, 41, 0, 0, time.UTC)},
+		{date: "Foo 1", clock: "04:41", want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		if got := inferYear(tt.date, tt.clock, now); !got.Equal(tt.want) {
+			t.Errorf("inferYear(%q, %q) = %v, want %v", tt.date, tt.clock, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package sftp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+
+	sshservice "servercommander/src/services/ssh"
+	"servercommander/src/services/transfer"
+)
+
+// Entry describes a file or directory returned by the SFTP server.
+type Entry struct {
+	Name        string      `json:"name" yaml:"name"`
+	Size        int64       `json:"size" yaml:"size"`
+	ModTime     time.Time   `json:"modTime" yaml:"modTime"`
+	IsDir       bool        `json:"isDir" yaml:"isDir"`
+	Permissions string      `json:"permissions" yaml:"permissions"`
+	Mode        os.FileMode `json:"-" yaml:"-"`
+}
+
+// Client implements file operations over the SFTP subsystem of an SSH
+// connection.
+type Client struct {
+	conn   *sshservice.Client
+	client *sftp.Client
+	// progress observes the data copied by Upload and Download.
+	progress transfer.ProgressFactory
+	// limiter throttles the data copied by Upload and Download.
+	limiter *transfer.Limiter
+	// noChecksum remembers that sha256sum cannot be run on the server, for
+	// instance because the account is restricted to SFTP.
+	noChecksum bool
+}
+
+// NewClient starts the SFTP subsystem on an established SSH connection. The
+// returned client takes ownership of the connection and closes it on Close.
+func NewClient(conn *sshservice.Client) (*Client, error) {
+	client, err := sftp.NewClient(conn.Raw())
+	if err != nil {
+		return nil, fmt.Errorf("failed to start SFTP subsystem: %w", err)
+	}
+
+	return &Client{conn: conn, client: client}, nil
+}
+
+// Close terminates the SFTP subsystem and the underlying SSH connection.
+func (c *Client) Close() error {
+	sftpErr := c.client.Close()
+	if err := c.conn.Close(); err != nil {
+		return err
+	}
+	return sftpErr
+}
+
+// Ping performs a cheap round trip to verify that the connection is still
+// usable.
+func (c *Client) Ping() error {
+	_, err := c.client.Getwd()
+	return err
+}
+
+// SetLimit caps the bandwidth of uploads and downloads in bytes per second.
+// Zero removes the limit.
+func (c *Client) SetLimit(bytesPerSecond int64) {
+	c.limiter = transfer.NewLimiter(bytesPerSecond)
+}
+
+// SetLimiter makes the client draw from a limiter shared with other
+// connections, so that their combined bandwidth stays within its rate.
+func (c *Client) SetLimiter(limiter *transfer.Limiter) {
+	c.limiter = limiter
+}
+
+// SetProgress installs the factory used to report the progress of uploads
+// and downloads. nil disables progress reporting.
+func (c *Client) SetProgress(progress transfer.ProgressFactory) {
+	c.progress = progress
+}
+
+// List returns the entries of a remote directory sorted by name.
+func (c *Client) List(remotePath string) ([]Entry, error) {
+	infos, err := c.client.ReadDir(remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", remotePath, err)
+	}
+
+	entries := make([]Entry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, entryFromInfo(info))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
+// Stat returns metadata for a single remote path.
+func (c *Client) Stat(remotePath string) (Entry, error) {
+	info, err := c.client.Stat(remotePath)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to stat %s: %w", remotePath, err)
+	}
+	return entryFromInfo(info), nil
+}
+
+// Size returns the size of a remote file.
+func (c *Client) Size(remotePath string) (int64, error) {
+	info, err := c.client.Stat(remotePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat %s: %w", remotePath, err)
+	}
+	return info.Size(), nil
+}
+
+// Checksum computes the SHA-256 of a remote file by running sha256sum over
+// the SSH connection. SFTP itself has no hash operation, so it returns
+// transfer.ErrNoChecksum when the server does not allow running commands or
+// has no sha256sum; a failure to hash one file is reported for that file.
+func (c *Client) Checksum(remotePath string) (transfer.Checksum, error) {
+	if c.noChecksum {
+		return transfer.Checksum{}, transfer.ErrNoChecksum
+	}
+	// Stat first so that a missing file is not mistaken for a server without
+	// sha256sum.
+	if _, err := c.client.Stat(remotePath); err != nil {
+		return transfer.Checksum{}, fmt.Errorf("failed to stat %s: %w", remotePath, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	status, err := c.conn.Exec("sha256sum -- "+shellQuote(remotePath), &stdout, &stderr)
+	if err != nil {
+		// Accounts restricted to SFTP refuse to execute commands at all.
+		c.noChecksum = true
+		return transfer.Checksum{}, fmt.Errorf("%w: %v", transfer.ErrNoChecksum, err)
+	}
+	if status != 0 {
+		message := strings.TrimSpace(stderr.String())
+		if status == 127 || commandMissing(message) {
+			c.noChecksum = true
+			return transfer.Checksum{}, fmt.Errorf("%w: %s", transfer.ErrNoChecksum, message)
+		}
+		return transfer.Checksum{}, fmt.Errorf("sha256sum exited with status %d: %s", status, message)
+	}
+
+	output := stdout.String()
+	fields := strings.Fields(output)
+	if len(fields) == 0 || len(fields[0]) != 64 {
+		return transfer.Checksum{}, fmt.Errorf("invalid sha256sum output: %s", strings.TrimSpace(output))
+	}
+	return transfer.Checksum{Algorithm: "SHA-256", Sum: strings.ToLower(fields[0])}, nil
+}
+
+// commandMissing reports whether a shell complained that a command does not
+// exist, e.g. on systems where it exits with a status other than 127.
+func commandMissing(message string) bool {
+	message = strings.ToLower(message)
+	for _, text := range []string{"command not found", ": not found", "is not recognized as an internal or external command"} {
+		if strings.Contains(message, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote quotes value for a POSIX shell.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Upload stores a local file on the remote server, creating missing parent
+// directories.
+func (c *Client) Upload(localPath, remotePath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer file.Close()
+
+	if dir := path.Dir(remotePath); dir != "." && dir != "/" {
+		if err := c.client.MkdirAll(dir); err != nil {
+			return fmt.Errorf("failed to create remote directories: %w", err)
+		}
+	}
+
+	remote, err := c.client.Create(remotePath)
+	if err != nil {
+		return fmt.Errorf("failed to create remote file: %w", err)
+	}
+	defer remote.Close()
+
+	total := int64(-1)
+	if info, err := file.Stat(); err == nil {
+		total = info.Size()
+	}
+	progress := c.progress.Start(path.Base(remotePath), total, 0)
+	_, err = io.Copy(remote, transfer.NewProgressReader(transfer.NewLimitedReader(file, c.limiter), 0, progress))
+	progress.Done(err)
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	return remote.Close()
+}
+
+// Download retrieves a remote file and stores it locally.
+func (c *Client) Download(remotePath, localPath string) error {
+	remote, err := c.client.Open(remotePath)
+	if err != nil {
+		return fmt.Errorf("failed to open remote file: %w", err)
+	}
+	defer remote.Close()
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0750); err != nil {
+		return fmt.Errorf("failed to create local directories: %w", err)
+	}
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer file.Close()
+
+	total := int64(-1)
+	if info, err := remote.Stat(); err == nil {
+		total = info.Size()
+	}
+	progress := c.progress.Start(path.Base(remotePath), total, 0)
+	_, err = io.Copy(transfer.NewProgressWriter(transfer.NewLimitedWriter(file, c.limiter), 0, progress), remote)
+	progress.Done(err)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+
+	return file.Close()
+}
+
+// Remove deletes a remote file or an empty directory.
+func (c *Client) Remove(remotePath string) error {
+	if err := c.client.Remove(remotePath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// RemoveAll deletes a remote directory together with its contents.
+func (c *Client) RemoveAll(remotePath string) error {
+	if err := c.client.RemoveAll(remotePath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// Retrieve streams a remote file into w.
+func (c *Client) Retrieve(remotePath string, w io.Writer) error {
+	remote, err := c.client.Open(remotePath)
+	if err != nil {
+		return fmt.Errorf("failed to open remote file: %w", err)
+	}
+	defer remote.Close()
+
+	if _, err := io.Copy(w, remote); err != nil {
+		return fmt.Errorf("failed to read remote file: %w", err)
+	}
+	return nil
+}
+
+// Rename moves a remote path. The POSIX rename extension is preferred when
+// available because it replaces existing targets atomically.
+func (c *Client) Rename(oldPath, newPath string) error {
+	var err error
+	if _, ok := c.client.HasExtension("posix-rename@openssh.com"); ok {
+		err = c.client.PosixRename(oldPath, newPath)
+	} else {
+		err = c.client.Rename(oldPath, newPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %w", oldPath, newPath, err)
+	}
+	return nil
+}
+
+// Mkdir creates a remote directory including any missing parents.
+func (c *Client) Mkdir(remotePath string) error {
+	if err := c.client.MkdirAll(remotePath); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// Chmod changes the permission bits of a remote path.
+func (c *Client) Chmod(remotePath string, mode os.FileMode) error {
+	if err := c.client.Chmod(remotePath, mode); err != nil {
+		return fmt.Errorf("failed to change mode of %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+func entryFromInfo(info os.FileInfo) Entry {
+	return Entry{
+		Name:        info.Name(),
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		IsDir:       info.IsDir(),
+		Permissions: info.Mode().String(),
+		Mode:        info.Mode(),
+	}
+}
@@ -1,75 +1,297 @@
 package ssh
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/term"
 
 	"servercommander/src/services/config"
 )
 
-// Client encapsulates metadata required to spawn SSH processes.
+// ErrPassphraseRequired is returned by Connect when the configured private key
+// is encrypted and no passphrase was supplied. Callers can prompt the user and
+// retry the connection.
+var ErrPassphraseRequired = errors.New("private key is protected by a passphrase")
+
+// Client wraps an authenticated SSH connection established for a stored
+// session.
 type Client struct {
-	session  config.Session
-	password string
+	session config.Session
+	conn    *gossh.Client
+	jumps   []*gossh.Client
 }
 
-// Connect prepares an SSH client for the provided session. No network
-// connection is established at this point; instead we rely on the system's
-// native ssh binary to handle the heavy lifting when commands are executed.
-func Connect(session config.Session, password string, _ []byte) (*Client, error) {
+// Connect dials the remote host and authenticates using the password and/or
+// the private key configured on the session. The passphrase is only used when
+// the private key is encrypted.
+func Connect(session config.Session, password string, passphrase []byte) (*Client, error) {
 	if session.Protocol != config.ProtocolSSH && session.Protocol != config.ProtocolSFTP {
 		return nil, fmt.Errorf("protocol %s cannot be used with SSH", session.Protocol)
 	}
 
-	return &Client{session: session, password: password}, nil
+	auth, err := authMethods(session, password, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := loadKnownHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	clientConfig := &gossh.ClientConfig{
+		User:            session.Username,
+		Auth:            auth,
+		HostKeyCallback: hosts.check,
+		Timeout:         10 * time.Second,
+	}
+
+	jumps, err := dialJumpHosts(session, passphrase, clientConfig, hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	var via *gossh.Client
+	if len(jumps) > 0 {
+		via = jumps[len(jumps)-1]
+	}
+
+	address := net.JoinHostPort(session.Host, strconv.Itoa(session.Port))
+	clientConfig.HostKeyAlgorithms = hosts.algorithms(address)
+	conn, err := dialVia(via, address, clientConfig)
+	if err != nil {
+		closeClients(jumps)
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+
+	return &Client{session: session, conn: conn, jumps: jumps}, nil
 }
 
-// Close is a no-op kept for API compatibility with other services.
+// Close terminates the underlying SSH connection and any jump host
+// connections it was tunnelled through.
 func (c *Client) Close() error {
-	return nil
+	var err error
+	if c.conn != nil {
+		err = c.conn.Close()
+	}
+	closeClients(c.jumps)
+	return err
 }
 
-// InteractiveShell spawns the system ssh command and attaches STDIN/STDOUT to
-// provide an interactive shell. Password authentication is delegated to the ssh
-// binary which prompts the user as needed.
+// InteractiveShell opens a remote login shell attached to the local terminal.
+// When STDIN is a terminal a PTY is requested, the local terminal is switched to
+// raw mode and window size changes are forwarded to the remote side.
 func (c *Client) InteractiveShell() error {
-	args := c.buildBaseArgs()
-	cmd := exec.Command("ssh", args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	session, err := c.conn.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open SSH session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("failed to switch terminal to raw mode: %w", err)
+		}
+		defer term.Restore(fd, state)
+
+		width, height := terminalSize()
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+
+		modes := gossh.TerminalModes{
+			gossh.ECHO:          1,
+			gossh.TTY_OP_ISPEED: 14400,
+			gossh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty(termType, height, width, modes); err != nil {
+			return fmt.Errorf("failed to allocate PTY: %w", err)
+		}
+
+		stop := watchWindowSize(func(width, height int) {
+			_ = session.WindowChange(height, width)
+		})
+		defer stop()
+	}
+
+	if err := session.Shell(); err != nil {
+		return fmt.Errorf("failed to start remote shell: %w", err)
+	}
+
+	if err := session.Wait(); err != nil {
+		// The exit status of the last command typed into the shell is not an
+		// error of the shell itself.
+		var exitErr *gossh.ExitError
+		var missingErr *gossh.ExitMissingError
+		if errors.As(err, &exitErr) || errors.As(err, &missingErr) {
+			return nil
+		}
+		return fmt.Errorf("remote shell terminated: %w", err)
+	}
+
+	return nil
 }
 
-// Run executes a remote command via ssh and captures its combined output.
+// Run executes a remote command and captures its combined output.
 func (c *Client) Run(command string) (string, error) {
-	args := append(c.buildBaseArgs(), command)
-	cmd := exec.Command("ssh", args...)
-	var buffer bytes.Buffer
-	cmd.Stdout = &buffer
-	cmd.Stderr = &buffer
+	session, err := c.conn.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to open SSH session: %w", err)
+	}
+	defer session.Close()
 
-	if err := cmd.Run(); err != nil {
-		return buffer.String(), fmt.Errorf("remote command failed: %w", err)
+	output, err := session.CombinedOutput(command)
+	if err != nil {
+		var exitErr *gossh.ExitError
+		if errors.As(err, &exitErr) {
+			return string(output), fmt.Errorf("remote command exited with status %d", exitErr.ExitStatus())
+		}
+		return string(output), fmt.Errorf("remote command failed: %w", err)
 	}
 
-	return buffer.String(), nil
+	return string(output), nil
 }
 
-// Raw exposes the configuration to enable reuse in other packages. Since the
-// implementation relies on external processes the method returns nil and is
-// retained purely for API compatibility.
-func (c *Client) Raw() interface{} {
-	return nil
+// Exec executes a remote command, streaming its standard output and error
+// into the given writers as it arrives. It returns the exit status of the
+// command; the error is only set when the command could not be run to
+// completion, e.g. because the connection dropped.
+func (c *Client) Exec(command string, stdout, stderr io.Writer) (int, error) {
+	session, err := c.conn.NewSession()
+	if err != nil {
+		return -1, fmt.Errorf("failed to open SSH session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdout = stdout
+	session.Stderr = stderr
+	if err := session.Run(command); err != nil {
+		var exitErr *gossh.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitStatus(), nil
+		}
+		return -1, fmt.Errorf("remote command failed: %w", err)
+	}
+	return 0, nil
+}
+
+// Raw exposes the underlying SSH connection so other services, such as SFTP,
+// can open additional channels on it.
+func (c *Client) Raw() *gossh.Client {
+	return c.conn
+}
+
+func authMethods(session config.Session, password string, passphrase []byte) ([]gossh.AuthMethod, error) {
+	methods := []gossh.AuthMethod{}
+
+	if session.AuthMethod == config.AuthPrivateKey && session.KeyPath != "" {
+		signer, err := loadSigner(session.KeyPath, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, gossh.PublicKeys(signer))
+	}
+
+	if password != "" {
+		methods = append(methods, passwordMethods(password)...)
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no credentials available for session '%s'", session.Alias)
+	}
+
+	return methods, nil
+}
+
+// NeedsPassphrase reports whether the private key configured on the session
+// is encrypted, so that callers can collect the passphrase before connecting.
+func NeedsPassphrase(session config.Session) bool {
+	if session.AuthMethod != config.AuthPrivateKey || session.KeyPath == "" {
+		return false
+	}
+	_, err := loadSigner(session.KeyPath, nil)
+	return errors.Is(err, ErrPassphraseRequired)
+}
+
+// passwordMethods answers password and keyboard-interactive authentication
+// with password.
+func passwordMethods(password string) []gossh.AuthMethod {
+	return []gossh.AuthMethod{
+		gossh.Password(password),
+		gossh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range answers {
+				answers[i] = password
+			}
+			return answers, nil
+		}),
+	}
+}
+
+func loadSigner(keyPath string, passphrase []byte) (gossh.Signer, error) {
+	resolved, err := expandHome(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %w", resolved, err)
+	}
+
+	if len(passphrase) > 0 {
+		signer, err := gossh.ParsePrivateKeyWithPassphrase(data, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt private key %s: %w", resolved, err)
+		}
+		return signer, nil
+	}
+
+	signer, err := gossh.ParsePrivateKey(data)
+	if err != nil {
+		var missing *gossh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("%w: %s", ErrPassphraseRequired, resolved)
+		}
+		return nil, fmt.Errorf("failed to parse private key %s: %w", resolved, err)
+	}
+
+	return signer, nil
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+
+	return filepath.Join(home, path[1:]), nil
 }
 
-func (c *Client) buildBaseArgs() []string {
-	args := []string{"-p", strconv.Itoa(c.session.Port), fmt.Sprintf("%s@%s", c.session.Username, c.session.Host)}
-	if c.session.AuthMethod == config.AuthPrivateKey && c.session.KeyPath != "" {
-		args = append([]string{"-i", c.session.KeyPath}, args...)
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
 	}
-	return args
+	return width, height
 }
@@ -0,0 +1,167 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"servercommander/src/utils"
+)
+
+// promptLock serialises the confirmation of unknown hosts and the
+// credential prompts of jump hosts, so that connections opened in parallel
+// ask one at a time and do not write to known_hosts concurrently.
+var promptLock sync.Mutex
+
+// knownHosts verifies server host keys against the user's OpenSSH
+// known_hosts file. Unknown hosts are presented to the user for confirmation
+// and recorded on acceptance; changed keys are always rejected.
+type knownHosts struct {
+	path   string
+	verify gossh.HostKeyCallback
+}
+
+func loadKnownHosts() (*knownHosts, error) {
+	path, err := knownHostsFile()
+	if err != nil {
+		return nil, err
+	}
+
+	verify, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts from %s: %w", path, err)
+	}
+
+	return &knownHosts{path: path, verify: verify}, nil
+}
+
+// check is the HostKeyCallback of every connection.
+func (k *knownHosts) check(hostname string, remote net.Addr, key gossh.PublicKey) error {
+	err := k.verify(hostname, remote, key)
+	if err == nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+
+	// Only a different key of the same type means the host changed; a host
+	// that offers a key of another type is confirmed like an unknown one.
+	for _, want := range keyErr.Want {
+		if want.Key.Type() == key.Type() {
+			return fmt.Errorf("host key for %s does not match the entry in %s (line %d). The server may have been reinstalled or the connection is being intercepted", hostname, k.path, want.Line)
+		}
+	}
+
+	promptLock.Lock()
+	defer promptLock.Unlock()
+
+	fmt.Printf("%sThe authenticity of host '%s' can't be established.%s\n", utils.Yellow, hostname, utils.Reset)
+	for _, want := range keyErr.Want {
+		fmt.Printf("The host is known with a %s key (%s line %d), but offered another key type.\n", want.Key.Type(), k.path, want.Line)
+	}
+	fmt.Printf("%s key fingerprint is %s.\n", key.Type(), gossh.FingerprintSHA256(key))
+	trust, promptErr := utils.PromptBool("Trust this host and add it to known_hosts", false)
+	if promptErr != nil {
+		return promptErr
+	}
+	if !trust {
+		return fmt.Errorf("host key for %s rejected", hostname)
+	}
+
+	return appendKnownHost(k.path, hostname, key)
+}
+
+// algorithms returns the host key algorithms to offer when connecting to
+// address. Algorithms for the key types already recorded for the host come
+// first, so that a server with several host keys presents the known one;
+// nil keeps the default order for unknown hosts.
+func (k *knownHosts) algorithms(address string) []string {
+	// Checking a key that cannot be on file lists the known keys of the host.
+	var keyErr *knownhosts.KeyError
+	if !errors.As(k.verify(address, &net.TCPAddr{}, probeKey{}), &keyErr) || len(keyErr.Want) == 0 {
+		return nil
+	}
+
+	preferred := []string{}
+	for _, want := range keyErr.Want {
+		if want.Key.Type() == gossh.KeyAlgoRSA {
+			preferred = append(preferred, gossh.KeyAlgoRSASHA512, gossh.KeyAlgoRSASHA256)
+		}
+		preferred = append(preferred, want.Key.Type())
+	}
+
+	algorithms := preferred
+	for _, algorithm := range defaultHostKeyAlgorithms {
+		if !slices.Contains(preferred, algorithm) {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
+}
+
+// defaultHostKeyAlgorithms is the order x/crypto/ssh offers host key
+// algorithms in when HostKeyAlgorithms is not set.
+var defaultHostKeyAlgorithms = []string{
+	gossh.CertAlgoRSASHA256v01, gossh.CertAlgoRSASHA512v01,
+	gossh.CertAlgoRSAv01, gossh.CertAlgoDSAv01, gossh.CertAlgoECDSA256v01,
+	gossh.CertAlgoECDSA384v01, gossh.CertAlgoECDSA521v01, gossh.CertAlgoED25519v01,
+
+	gossh.KeyAlgoECDSA256, gossh.KeyAlgoECDSA384, gossh.KeyAlgoECDSA521,
+	gossh.KeyAlgoRSASHA256, gossh.KeyAlgoRSASHA512,
+	gossh.KeyAlgoRSA, gossh.KeyAlgoDSA,
+
+	gossh.KeyAlgoED25519,
+}
+
+// probeKey is a public key no known_hosts entry matches.
+type probeKey struct{}
+
+func (probeKey) Type() string                          { return "probe" }
+func (probeKey) Marshal() []byte                       { return []byte("probe") }
+func (probeKey) Verify([]byte, *gossh.Signature) error { return errors.New("probe key") }
+
+func knownHostsFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+
+	dir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	path := filepath.Join(dir, "known_hosts")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	file.Close()
+
+	return path, nil
+}
+
+func appendKnownHost(path, hostname string, key gossh.PublicKey) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	defer file.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+
+	return nil
+}
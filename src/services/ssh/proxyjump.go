@@ -0,0 +1,261 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"servercommander/src/services/config"
+	"servercommander/src/services/vault"
+	"servercommander/src/utils"
+)
+
+// jumpHost is a single hop of a ProxyJump specification.
+type jumpHost struct {
+	user    string
+	address string
+	// session is set when the hop names a stored SSH session, whose
+	// credentials are used instead of those of the target.
+	session *config.Session
+}
+
+// jumpSecrets remembers passwords and passphrases typed for jump hosts, keyed
+// by session alias or user@host, so that connections through the same hop
+// only ask once. It is guarded by promptLock.
+var jumpSecrets = map[string]string{}
+
+// dialJumpHosts connects to every hop listed in the session's ProxyJump
+// setting, each one tunnelled through the previous. A hop naming a stored SSH
+// session alias takes its host, port, username and credentials from that
+// session. Other hops are offered the private key of the target session or,
+// when it has none, ask for their own password; the target's password is
+// never sent to them.
+func dialJumpHosts(session config.Session, passphrase []byte, clientConfig *gossh.ClientConfig, hosts *knownHosts) ([]*gossh.Client, error) {
+	if session.ProxyJump == "" || strings.EqualFold(session.ProxyJump, "none") {
+		return nil, nil
+	}
+
+	hops, err := parseProxyJump(session.ProxyJump, session.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := []*gossh.Client{}
+	var via *gossh.Client
+	for _, hop := range hops {
+		hopConfig := gossh.ClientConfig{
+			User:              hop.user,
+			HostKeyCallback:   clientConfig.HostKeyCallback,
+			HostKeyAlgorithms: hosts.algorithms(hop.address),
+			Timeout:           clientConfig.Timeout,
+		}
+		if hop.session != nil {
+			auth, err := jumpAuth(*hop.session)
+			if err != nil {
+				closeClients(clients)
+				return nil, fmt.Errorf("failed to authenticate to jump host %s: %w", hop.session.Alias, err)
+			}
+			hopConfig.Auth = auth
+		} else {
+			auth, err := hopAuth(hop, session, passphrase)
+			if err != nil {
+				closeClients(clients)
+				return nil, fmt.Errorf("failed to authenticate to jump host %s: %w", hop.address, err)
+			}
+			hopConfig.Auth = auth
+		}
+
+		client, err := dialVia(via, hop.address, &hopConfig)
+		if err != nil {
+			closeClients(clients)
+			return nil, fmt.Errorf("failed to connect to jump host %s: %w", hop.address, err)
+		}
+
+		clients = append(clients, client)
+		via = client
+	}
+
+	return clients, nil
+}
+
+// PrepareJumpHosts asks for the passwords and passphrases the session's jump
+// hosts need and that are not in the vault, so that a later Connect does not
+// prompt. Callers connecting to several hosts at once use it before starting.
+func PrepareJumpHosts(session config.Session) error {
+	if session.ProxyJump == "" || strings.EqualFold(session.ProxyJump, "none") {
+		return nil
+	}
+
+	hops, err := parseProxyJump(session.ProxyJump, session.Username)
+	if err != nil {
+		return err
+	}
+	for _, hop := range hops {
+		switch {
+		case hop.session != nil:
+			if _, err := jumpAuth(*hop.session); err != nil {
+				return fmt.Errorf("failed to authenticate to jump host %s: %w", hop.session.Alias, err)
+			}
+		case session.AuthMethod != config.AuthPrivateKey || session.KeyPath == "":
+			if _, err := hopPassword(hop); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jumpAuth returns the authentication methods of a jump host stored as a
+// session. Its password and key passphrase are taken from the vault or asked
+// for like those of the target.
+func jumpAuth(session config.Session) ([]gossh.AuthMethod, error) {
+	password := ""
+	if session.RequiresPass {
+		var err error
+		password, err = jumpSecret(session, vault.KindPassword, fmt.Sprintf("Password for %s@%s", session.Username, session.Host))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	auth, err := authMethods(session, password, nil)
+	if !errors.Is(err, ErrPassphraseRequired) {
+		return auth, err
+	}
+	passphrase, err := jumpSecret(session, vault.KindPassphrase, fmt.Sprintf("Passphrase for key %s", session.KeyPath))
+	if err != nil {
+		return nil, err
+	}
+	return authMethods(session, password, []byte(passphrase))
+}
+
+// hopAuth returns the authentication methods of a jump host that is not a
+// stored session: the private key of the target session if it has one, and
+// otherwise a password asked for once per user@host.
+func hopAuth(hop jumpHost, target config.Session, passphrase []byte) ([]gossh.AuthMethod, error) {
+	if target.AuthMethod == config.AuthPrivateKey && target.KeyPath != "" {
+		signer, err := loadSigner(target.KeyPath, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []gossh.AuthMethod{gossh.PublicKeys(signer)}, nil
+	}
+
+	password, err := hopPassword(hop)
+	if err != nil {
+		return nil, err
+	}
+	return passwordMethods(password), nil
+}
+
+func hopPassword(hop jumpHost) (string, error) {
+	name := hop.user + "@" + hop.address
+	return promptJumpSecret(strings.ToLower(name)+"/"+string(vault.KindPassword), fmt.Sprintf("Password for %s", name))
+}
+
+func jumpSecret(session config.Session, kind vault.SecretKind, question string) (string, error) {
+	if secret, ok := vault.Lookup(session.Alias, kind); ok {
+		return secret, nil
+	}
+	return promptJumpSecret(strings.ToLower(session.Alias)+"/"+string(kind), question)
+}
+
+// promptJumpSecret asks for a jump host secret unless it was already typed
+// under the same key.
+func promptJumpSecret(key, question string) (string, error) {
+	promptLock.Lock()
+	defer promptLock.Unlock()
+
+	if secret, ok := jumpSecrets[key]; ok {
+		return secret, nil
+	}
+	secret, err := utils.PromptPassword(question)
+	if err != nil {
+		return "", err
+	}
+	jumpSecrets[key] = secret
+	return secret, nil
+}
+
+// dialVia opens an SSH connection to address, either directly or through an
+// already established connection.
+func dialVia(via *gossh.Client, address string, clientConfig *gossh.ClientConfig) (*gossh.Client, error) {
+	if via == nil {
+		return gossh.Dial("tcp", address, clientConfig)
+	}
+
+	conn, err := via.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConn, channels, requests, err := gossh.NewClientConn(conn, address, clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return gossh.NewClient(clientConn, channels, requests), nil
+}
+
+// parseProxyJump parses a comma separated list of [user@]host[:port] hops as
+// accepted by OpenSSH's ProxyJump directive.
+func parseProxyJump(value, defaultUser string) ([]jumpHost, error) {
+	var store *config.SessionStore
+	if loaded, err := config.LoadSessions(); err == nil {
+		store = loaded
+	}
+
+	hops := []jumpHost{}
+	for _, spec := range strings.Split(value, ",") {
+		spec = strings.TrimPrefix(strings.TrimSpace(spec), "ssh://")
+		if spec == "" {
+			continue
+		}
+
+		if store != nil {
+			if stored, ok := store.Get(spec); ok && stored.Protocol == config.ProtocolSSH {
+				hops = append(hops, jumpHost{
+					user:    stored.Username,
+					address: net.JoinHostPort(stored.Host, strconv.Itoa(stored.Port)),
+					session: &stored,
+				})
+				continue
+			}
+		}
+
+		user := defaultUser
+		if at := strings.LastIndex(spec, "@"); at != -1 {
+			user = spec[:at]
+			spec = spec[at+1:]
+		}
+
+		host, port := spec, "22"
+		if h, p, err := net.SplitHostPort(spec); err == nil {
+			host, port = h, p
+		} else {
+			host = strings.Trim(spec, "[]")
+		}
+
+		if host == "" {
+			return nil, fmt.Errorf("invalid ProxyJump host %q", value)
+		}
+		if _, err := strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid ProxyJump port %q", port)
+		}
+
+		hops = append(hops, jumpHost{user: user, address: net.JoinHostPort(host, port)})
+	}
+
+	return hops, nil
+}
+
+func closeClients(clients []*gossh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		clients[i].Close()
+	}
+}
@@ -0,0 +1,100 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+
+	"servercommander/src/services/config"
+	"servercommander/src/utils"
+)
+
+func TestParseProxyJump(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tests := []struct {
+		value string
+		want  []string
+		err   string
+	}{
+		{value: "bastion", want: []string{"alice@bastion:22"}},
+		{value: "bob@bastion:2222, ssh://10.0.0.1", want: []string{"bob@bastion:2222", "alice@10.0.0.1:22"}},
+		{value: "[2001:db8::1]:2200", want: []string{"alice@[2001:db8::1]:2200"}},
+		{value: "bob@", err: "invalid ProxyJump host"},
+		{value: "bastion:ssh", err: "invalid ProxyJump port"},
+	}
+
+	for _, tt := range tests {
+		hops, err := parseProxyJump(tt.value, "alice")
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseProxyJump(%q) error = %v, want %q", tt.value, err, tt.err)
+			}
+			continue
+		}
+		got := make([]string, len(hops))
+		for i, hop := range hops {
+			got[i] = hop.user + "@" + hop.address
+		}
+		if err != nil || strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("parseProxyJump(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestHopAuthDoesNotReuseTargetPassword(t *testing.T) {
+	t.Cleanup(func() { jumpSecrets = map[string]string{} })
+	utils.SetPromptReader(strings.NewReader("hop-secret\n"))
+
+	target := config.Session{Alias: "web", AuthMethod: config.AuthPassword, RequiresPass: true}
+	methods, err := hopAuth(jumpHost{user: "alice", address: "bastion:22"}, target, nil)
+	if err != nil {
+		t.Fatalf("hopAuth failed: %v", err)
+	}
+	if len(methods) != 2 {
+		t.Errorf("hopAuth returned %d methods, want password and keyboard-interactive", len(methods))
+	}
+	if got := jumpSecrets["alice@bastion:22/password"]; got != "hop-secret" {
+		t.Errorf("hop password = %q, want the one typed for the hop", got)
+	}
+
+	target = config.Session{Alias: "web", AuthMethod: config.AuthPrivateKey, KeyPath: "/missing/key"}
+	if _, err := hopAuth(jumpHost{user: "alice", address: "bastion:22"}, target, nil); err == nil {
+		t.Error("hopAuth succeeded with an unreadable target key")
+	}
+}
+
+func TestPromptJumpSecretAsksOnce(t *testing.T) {
+	t.Cleanup(func() { jumpSecrets = map[string]string{} })
+	utils.SetPromptReader(strings.NewReader("hop-secret\n"))
+
+	for i := 0; i < 2; i++ {
+		got, err := promptJumpSecret("alice@bastion:22/password", "Password for alice@bastion:22")
+		if err != nil || got != "hop-secret" {
+			t.Errorf("promptJumpSecret = %q, %v, want %q", got, err, "hop-secret")
+		}
+	}
+}
+
+func TestPrepareJumpHosts(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Cleanup(func() { jumpSecrets = map[string]string{} })
+	utils.SetPromptReader(strings.NewReader("hop-secret\n"))
+
+	// A target with a key offers it to the hop, so nothing is asked.
+	keyed := config.Session{Alias: "web", Username: "alice", AuthMethod: config.AuthPrivateKey, KeyPath: "/missing/key", ProxyJump: "bastion"}
+	if err := PrepareJumpHosts(keyed); err != nil || len(jumpSecrets) != 0 {
+		t.Errorf("PrepareJumpHosts(key) = %v, asked %v, want no prompt", err, jumpSecrets)
+	}
+
+	target := config.Session{Alias: "web", Username: "alice", AuthMethod: config.AuthPassword, ProxyJump: "bastion"}
+	if err := PrepareJumpHosts(target); err != nil {
+		t.Fatalf("PrepareJumpHosts failed: %v", err)
+	}
+	if got := jumpSecrets["alice@bastion:22/password"]; got != "hop-secret" {
+		t.Errorf("hop password = %q, want %q", got, "hop-secret")
+	}
+	// The answer is remembered for the connection.
+	if _, err := hopAuth(jumpHost{user: "alice", address: "bastion:22"}, target, nil); err != nil {
+		t.Errorf("hopAuth after PrepareJumpHosts = %v", err)
+	}
+}
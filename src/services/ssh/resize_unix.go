@@ -0,0 +1,33 @@
+//go:build !windows
+
+package ssh
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchWindowSize invokes onResize whenever the terminal receives SIGWINCH.
+// The returned function stops the watcher.
+func watchWindowSize(onResize func(width, height int)) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				onResize(terminalSize())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package ssh
+
+import "time"
+
+// watchWindowSize polls the console dimensions because Windows does not
+// deliver a resize signal. The returned function stops the watcher.
+func watchWindowSize(onResize func(width, height int)) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(250 * time.Millisecond)
+	width, height := terminalSize()
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				w, h := terminalSize()
+				if w != width || h != height {
+					width, height = w, h
+					onResize(w, h)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
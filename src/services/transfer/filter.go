@@ -0,0 +1,51 @@
+package transfer
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Filter selects the files of a recursive transfer. Patterns use path.Match
+// syntax. A pattern containing a slash is matched against the path relative to
+// the transfer root, any other pattern against the base name. Excluded
+// directories are skipped entirely; include patterns only apply to files.
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+// Validate reports malformed patterns before a transfer starts.
+func (f Filter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether the entry at the slash-separated relative path rel
+// takes part in the transfer.
+func (f Filter) Match(rel string, isDir bool) bool {
+	if matchAny(f.Exclude, rel) {
+		return false
+	}
+	if isDir || len(f.Include) == 0 {
+		return true
+	}
+	return matchAny(f.Include, rel)
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		target := path.Base(rel)
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
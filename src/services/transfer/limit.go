@@ -0,0 +1,116 @@
+package transfer
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket that caps the throughput of the streams sharing
+// it. The bucket holds at most one second worth of tokens, so a stream may
+// briefly burst after being idle but never exceeds the rate on average. A nil
+// Limiter imposes no limit.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// minBurst stops very low limits from splitting transfers into tiny reads
+// and writes.
+const minBurst = 4096
+
+// NewLimiter returns a limiter allowing bytesPerSecond, or nil when
+// bytesPerSecond is not positive.
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	burst := float64(bytesPerSecond)
+	if burst < minBurst {
+		burst = minBurst
+	}
+	return &Limiter{rate: float64(bytesPerSecond), burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait takes n tokens from the bucket and sleeps until the debt incurred by
+// taking more tokens than available has been paid off.
+func (l *Limiter) Wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit > 0 {
+		time.Sleep(time.Duration(deficit / l.rate * float64(time.Second)))
+	}
+}
+
+// chunk bounds a single read or write so that one call cannot take much more
+// than the bucket holds.
+func (l *Limiter) chunk(size int) int {
+	if l != nil && size > int(l.burst) {
+		return int(l.burst)
+	}
+	return size
+}
+
+type limitedReader struct {
+	reader  io.Reader
+	limiter *Limiter
+}
+
+// NewLimitedReader throttles reads from r to the rate of limiter. r is
+// returned unchanged when limiter is nil.
+func NewLimitedReader(r io.Reader, limiter *Limiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	return &limitedReader{reader: r, limiter: limiter}
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p[:r.limiter.chunk(len(p))])
+	r.limiter.Wait(n)
+	return n, err
+}
+
+type limitedWriter struct {
+	writer  io.Writer
+	limiter *Limiter
+}
+
+// NewLimitedWriter throttles writes to w to the rate of limiter. w is
+// returned unchanged when limiter is nil.
+func NewLimitedWriter(w io.Writer, limiter *Limiter) io.Writer {
+	if limiter == nil {
+		return w
+	}
+	return &limitedWriter{writer: w, limiter: limiter}
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		size := w.limiter.chunk(len(p) - written)
+		w.limiter.Wait(size)
+		n, err := w.writer.Write(p[written : written+size])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
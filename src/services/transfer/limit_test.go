@@ -0,0 +1,116 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewLimiter(t *testing.T) {
+	tests := []struct {
+		rate  int64
+		off   bool
+		burst float64
+	}{
+		{rate: 0, off: true},
+		{rate: -1, off: true},
+		{rate: 100, burst: minBurst},
+		{rate: 1 << 20, burst: 1 << 20},
+	}
+
+	for _, tt := range tests {
+		limiter := NewLimiter(tt.rate)
+		if (limiter == nil) != tt.off {
+			t.Errorf("NewLimiter(%d) = %v, want nil %v", tt.rate, limiter, tt.off)
+			continue
+		}
+		if limiter != nil && (limiter.burst != tt.burst || limiter.tokens != tt.burst || limiter.rate != float64(tt.rate)) {
+			t.Errorf("NewLimiter(%d) burst = %v, tokens = %v, rate = %v, want burst %v", tt.rate, limiter.burst, limiter.tokens, limiter.rate, tt.burst)
+		}
+	}
+}
+
+func TestLimiterChunk(t *testing.T) {
+	tests := []struct {
+		limiter *Limiter
+		size    int
+		want    int
+	}{
+		{limiter: nil, size: 1 << 20, want: 1 << 20},
+		{limiter: NewLimiter(100), size: 100, want: 100},
+		{limiter: NewLimiter(100), size: 10000, want: minBurst},
+		{limiter: NewLimiter(50000), size: 32768, want: 32768},
+		{limiter: NewLimiter(50000), size: 65536, want: 50000},
+	}
+
+	for _, tt := range tests {
+		if got := tt.limiter.chunk(tt.size); got != tt.want {
+			t.Errorf("chunk(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestNilLimiterPassesThrough(t *testing.T) {
+	var buffer bytes.Buffer
+	if NewLimitedWriter(&buffer, nil) != io.Writer(&buffer) {
+		t.Error("NewLimitedWriter wrapped the writer without a limiter")
+	}
+	if NewLimitedReader(&buffer, nil) != io.Reader(&buffer) {
+		t.Error("NewLimitedReader wrapped the reader without a limiter")
+	}
+	var limiter *Limiter
+	limiter.Wait(1 << 30)
+}
+
+// TestLimitedStreams checks the throughput of throttled streams. The bucket
+// starts full, so only the data beyond one second worth of tokens is delayed.
+func TestLimitedStreams(t *testing.T) {
+	const rate = 400000
+
+	tests := []struct {
+		name    string
+		streams int
+		size    int
+		write   bool
+		min     time.Duration
+	}{
+		{name: "burst is not delayed", streams: 1, size: rate, write: true, min: 0},
+		{name: "writer", streams: 1, size: rate * 3 / 2, write: true, min: 500 * time.Millisecond},
+		{name: "reader", streams: 1, size: rate * 3 / 2, write: false, min: 500 * time.Millisecond},
+		{name: "streams share the limit", streams: 2, size: rate * 3 / 4, write: true, min: 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewLimiter(rate)
+			data := bytes.Repeat([]byte("x"), tt.size)
+
+			start := time.Now()
+			var wg sync.WaitGroup
+			for i := 0; i < tt.streams; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var buffer bytes.Buffer
+					var err error
+					if tt.write {
+						_, err = NewLimitedWriter(&buffer, limiter).Write(data)
+					} else {
+						_, err = io.Copy(&buffer, NewLimitedReader(bytes.NewReader(data), limiter))
+					}
+					if err != nil || !bytes.Equal(buffer.Bytes(), data) {
+						t.Errorf("stream copied %d of %d bytes: %v", buffer.Len(), len(data), err)
+					}
+				}()
+			}
+			wg.Wait()
+			elapsed := time.Since(start)
+
+			if elapsed < tt.min*95/100 || elapsed > tt.min+400*time.Millisecond {
+				t.Errorf("transfer took %v, want about %v", elapsed, tt.min)
+			}
+		})
+	}
+}
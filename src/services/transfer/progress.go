@@ -0,0 +1,73 @@
+package transfer
+
+import "io"
+
+// Progress observes the transfer of a single file.
+type Progress interface {
+	// Update is called with the number of bytes transferred so far,
+	// including a prefix that was resumed from an earlier attempt.
+	Update(transferred int64)
+	// Done is called once the data has been copied or the copy failed.
+	Done(err error)
+}
+
+// ProgressFactory starts observing the transfer of name. total is -1 when
+// the size is not known in advance and offset is the number of bytes a
+// resumed transfer skips.
+type ProgressFactory func(name string, total, offset int64) Progress
+
+// Start returns the Progress for one file. A nil factory discards all
+// updates so clients do not have to check whether progress is wanted.
+func (f ProgressFactory) Start(name string, total, offset int64) Progress {
+	if f == nil {
+		return discardProgress{}
+	}
+	return f(name, total, offset)
+}
+
+type discardProgress struct{}
+
+func (discardProgress) Update(int64) {}
+func (discardProgress) Done(error)   {}
+
+type progressReader struct {
+	reader      io.Reader
+	transferred int64
+	progress    Progress
+}
+
+// NewProgressReader counts the bytes read from r and reports them to
+// progress. offset is the number of bytes transferred before r was opened.
+func NewProgressReader(r io.Reader, offset int64, progress Progress) io.Reader {
+	return &progressReader{reader: r, transferred: offset, progress: progress}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.transferred += int64(n)
+		r.progress.Update(r.transferred)
+	}
+	return n, err
+}
+
+type progressWriter struct {
+	writer      io.Writer
+	transferred int64
+	progress    Progress
+}
+
+// NewProgressWriter counts the bytes written to w and reports them to
+// progress. offset is the number of bytes transferred before w was opened.
+func NewProgressWriter(w io.Writer, offset int64, progress Progress) io.Writer {
+	return &progressWriter{writer: w, transferred: offset, progress: progress}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if n > 0 {
+		w.transferred += int64(n)
+		w.progress.Update(w.transferred)
+	}
+	return n, err
+}
@@ -0,0 +1,640 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Conn is a connection owned by one worker of a queued job.
+type Conn interface {
+	Remote
+	Close() error
+}
+
+// JobState describes where a queued job is in its life cycle.
+type JobState string
+
+const (
+	JobScanning  JobState = "scanning"
+	JobRunning   JobState = "running"
+	JobPaused    JobState = "paused"
+	JobCompleted JobState = "completed"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// maxBackoff caps the delay between two attempts of the same file.
+const maxBackoff = 30 * time.Second
+
+// JobSpec describes a recursive transfer submitted to a Queue.
+type JobSpec struct {
+	Alias      string
+	Direction  Direction
+	LocalRoot  string
+	RemoteRoot string
+	Filter     Filter
+	// Connections is the size of the connection pool. Additional connections
+	// are only opened when there are enough files to keep them busy.
+	Connections int
+	// Retries is how often a file failing with a transient error is tried
+	// again. The delay doubles after every attempt.
+	Retries int
+	// Verify compares every copied file with its source, see VerifyFile. A
+	// mismatch counts as a transient failure, so the file is copied again.
+	Verify bool
+	// Connect opens another authenticated connection. It runs in the
+	// background and therefore must not prompt.
+	Connect func() (Conn, error)
+	// Report is called after every file. It may be nil.
+	Report func(FileResult)
+}
+
+// FileResult describes the outcome for one file of a queued job.
+type FileResult struct {
+	Path     string
+	Size     int64
+	Attempts int
+	// Verified names the checksum algorithm, or "size", that confirmed the
+	// copy. It is empty when the job does not verify files.
+	Verified string
+	Err      error
+}
+
+// JobStatus is a snapshot of a job.
+type JobStatus struct {
+	ID          int       `json:"id" yaml:"id"`
+	Alias       string    `json:"alias" yaml:"alias"`
+	Direction   Direction `json:"direction" yaml:"direction"`
+	Source      string    `json:"source" yaml:"source"`
+	Destination string    `json:"destination" yaml:"destination"`
+	State       JobState  `json:"state" yaml:"state"`
+	Files       int       `json:"files" yaml:"files"`
+	Completed   int       `json:"completed" yaml:"completed"`
+	Failed      int       `json:"failed" yaml:"failed"`
+	Connections int       `json:"connections" yaml:"connections"`
+	TotalBytes  int64     `json:"totalBytes" yaml:"totalBytes"`
+	Bytes       int64     `json:"bytes" yaml:"bytes"`
+	Started     time.Time `json:"started" yaml:"started"`
+	Finished    time.Time `json:"finished,omitempty" yaml:"finished,omitempty"`
+	Error       string    `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Elapsed returns how long the job has been running or ran.
+func (s JobStatus) Elapsed() time.Duration {
+	if s.Finished.IsZero() {
+		return time.Since(s.Started)
+	}
+	return s.Finished.Sub(s.Started)
+}
+
+// Queue runs recursive transfers in the background, each with its own pool
+// of connections.
+type Queue struct {
+	mu     sync.Mutex
+	jobs   []*Job
+	nextID int
+}
+
+// NewQueue returns an empty queue.
+func NewQueue() *Queue {
+	return &Queue{nextID: 1}
+}
+
+// Submit starts a job. first is an established connection that becomes the
+// first worker of the pool, so that authentication problems and prompts are
+// dealt with before the job runs in the background.
+func (q *Queue) Submit(spec JobSpec, first Conn) *Job {
+	if spec.Connections < 1 {
+		spec.Connections = 1
+	}
+
+	q.mu.Lock()
+	job := &Job{
+		spec:    spec,
+		id:      q.nextID,
+		state:   JobScanning,
+		conns:   map[Conn]bool{},
+		started: time.Now(),
+		done:    make(chan struct{}),
+	}
+	job.resumed = sync.NewCond(&job.mu)
+	q.nextID++
+	q.jobs = append(q.jobs, job)
+	q.mu.Unlock()
+
+	go job.run(first)
+	return job
+}
+
+// Jobs returns the status of every job submitted during this process.
+func (q *Queue) Jobs() []JobStatus {
+	q.mu.Lock()
+	jobs := append([]*Job{}, q.jobs...)
+	q.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		statuses = append(statuses, job.Status())
+	}
+	return statuses
+}
+
+// Running reports whether any job has not finished yet.
+func (q *Queue) Running() bool {
+	for _, status := range q.Jobs() {
+		if !status.State.Finished() {
+			return true
+		}
+	}
+	return false
+}
+
+// Pause stops job id from starting further files. Files in progress are
+// completed.
+func (q *Queue) Pause(id int) error {
+	job, err := q.unfinished(id)
+	if err != nil {
+		return err
+	}
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.paused = true
+	return nil
+}
+
+// Resume continues a paused job.
+func (q *Queue) Resume(id int) error {
+	job, err := q.unfinished(id)
+	if err != nil {
+		return err
+	}
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.paused = false
+	job.resumed.Broadcast()
+	return nil
+}
+
+// Cancel aborts job id. Its connections are closed, which interrupts the
+// files in progress; partial files are left for a later resume.
+func (q *Queue) Cancel(id int) error {
+	job, err := q.unfinished(id)
+	if err != nil {
+		return err
+	}
+	job.cancel()
+	return nil
+}
+
+func (q *Queue) unfinished(id int) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.jobs {
+		if job.id != id {
+			continue
+		}
+		if job.Status().State.Finished() {
+			return nil, fmt.Errorf("transfer #%d has already finished", id)
+		}
+		return job, nil
+	}
+	return nil, fmt.Errorf("no transfer with id %d", id)
+}
+
+// Finished reports whether a job in this state has ended.
+func (s JobState) Finished() bool {
+	return s == JobCompleted || s == JobFailed || s == JobCancelled
+}
+
+// Job is a recursive transfer run by a Queue.
+type Job struct {
+	spec JobSpec
+	id   int
+
+	mu        sync.Mutex
+	resumed   *sync.Cond
+	state     JobState
+	paused    bool
+	cancelled bool
+	conns     map[Conn]bool
+
+	files      int
+	completed  int
+	failed     int
+	totalBytes int64
+	doneBytes  int64
+	// inFlight holds how much of its current file each worker has
+	// transferred.
+	inFlight []int64
+
+	started  time.Time
+	finished time.Time
+	err      error
+	done     chan struct{}
+}
+
+// ID returns the number the job is addressed by.
+func (j *Job) ID() int {
+	return j.id
+}
+
+// Wait blocks until the job has finished and returns its final status.
+func (j *Job) Wait() JobStatus {
+	<-j.done
+	return j.Status()
+}
+
+// Status returns a snapshot of the job.
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	source, destination := j.spec.LocalRoot, j.spec.Alias+":"+j.spec.RemoteRoot
+	if j.spec.Direction == Download {
+		source, destination = destination, source
+	}
+
+	state := j.state
+	if state == JobRunning && j.paused {
+		state = JobPaused
+	}
+
+	bytes := j.doneBytes
+	for _, n := range j.inFlight {
+		bytes += n
+	}
+
+	status := JobStatus{
+		ID:          j.id,
+		Alias:       j.spec.Alias,
+		Direction:   j.spec.Direction,
+		Source:      source,
+		Destination: destination,
+		State:       state,
+		Files:       j.files,
+		Completed:   j.completed,
+		Failed:      j.failed,
+		Connections: len(j.conns),
+		TotalBytes:  j.totalBytes,
+		Bytes:       bytes,
+		Started:     j.started,
+		Finished:    j.finished,
+	}
+	if j.err != nil {
+		status.Error = j.err.Error()
+	}
+	return status
+}
+
+type queuedFile struct {
+	rel  string
+	size int64
+}
+
+func (j *Job) run(first Conn) {
+	defer close(j.done)
+
+	j.track(first)
+	files, err := j.plan(first)
+	if err != nil {
+		j.release(first)
+		j.finish(err)
+		return
+	}
+
+	workers := j.spec.Connections
+	if workers > len(files) {
+		workers = len(files)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	pending := make(chan queuedFile, len(files))
+	for _, file := range files {
+		pending <- file
+	}
+	close(pending)
+
+	j.mu.Lock()
+	if j.state == JobScanning {
+		j.state = JobRunning
+	}
+	j.inFlight = make([]int64, workers)
+	j.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		conn := first
+		if worker > 0 {
+			conn = nil
+		}
+		wg.Add(1)
+		go func(worker int, conn Conn) {
+			defer wg.Done()
+			j.work(worker, conn, pending)
+		}(worker, conn)
+	}
+	wg.Wait()
+
+	j.finish(nil)
+}
+
+// plan lists the files to transfer and creates the destination directories
+// up front, so workers never race to create the same directory.
+func (j *Job) plan(conn Conn) ([]queuedFile, error) {
+	var tree map[string]fileState
+	var err error
+	if j.spec.Direction == Upload {
+		tree, err = scanLocal(j.spec.LocalRoot, j.spec.Filter, false)
+	} else {
+		tree, err = scanRemote(conn, j.spec.RemoteRoot, j.spec.Filter, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := j.makeDir(conn, ""); err != nil {
+		return nil, err
+	}
+
+	files := []queuedFile{}
+	var total int64
+	for _, rel := range sortedPaths(tree) {
+		state := tree[rel]
+		if state.isDir {
+			if err := j.makeDir(conn, rel); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		files = append(files, queuedFile{rel: rel, size: state.size})
+		total += state.size
+	}
+
+	// Large files first keeps the pool busy until the end instead of leaving
+	// one connection working on a big file while the others are idle.
+	sort.SliceStable(files, func(a, b int) bool {
+		return files[a].size > files[b].size
+	})
+
+	j.mu.Lock()
+	j.files = len(files)
+	j.totalBytes = total
+	j.mu.Unlock()
+	return files, nil
+}
+
+func (j *Job) makeDir(conn Conn, rel string) error {
+	if j.spec.Direction == Upload {
+		return conn.Mkdir(path.Join(j.spec.RemoteRoot, rel))
+	}
+	if err := os.MkdirAll(filepath.Join(j.spec.LocalRoot, filepath.FromSlash(rel)), 0750); err != nil {
+		return fmt.Errorf("failed to create local directories: %w", err)
+	}
+	return nil
+}
+
+func (j *Job) work(worker int, conn Conn, pending <-chan queuedFile) {
+	// Servers often limit the connections per user. A worker that cannot
+	// connect leaves its share of the files to the rest of the pool.
+	if conn == nil {
+		var err error
+		if conn, err = j.connect(); err != nil {
+			return
+		}
+	}
+	defer func() {
+		if conn != nil {
+			j.release(conn)
+		}
+	}()
+
+	for file := range pending {
+		if !j.waitWhilePaused() {
+			return
+		}
+		result := j.transfer(worker, &conn, file)
+		if j.isCancelled() {
+			return
+		}
+		j.record(worker, file, result)
+	}
+}
+
+// transfer copies one file, retrying transient failures on a fresh
+// connection with exponential backoff.
+func (j *Job) transfer(worker int, conn *Conn, file queuedFile) FileResult {
+	result := FileResult{Path: file.rel, Size: file.size}
+	localPath := filepath.Join(j.spec.LocalRoot, filepath.FromSlash(file.rel))
+	remotePath := path.Join(j.spec.RemoteRoot, file.rel)
+	backoff := time.Second
+
+	for {
+		result.Attempts++
+		if *conn == nil {
+			*conn, result.Err = j.connect()
+		}
+		if *conn != nil {
+			j.observe(worker, *conn)
+			if j.spec.Direction == Upload {
+				result.Err = (*conn).Upload(localPath, remotePath)
+			} else {
+				result.Err = (*conn).Download(remotePath, localPath)
+			}
+			if result.Err == nil && j.spec.Verify {
+				result.Verified, result.Err = verifyCopy(*conn, localPath, remotePath)
+			}
+		}
+
+		if result.Err == nil || result.Attempts > j.spec.Retries || !IsTransient(result.Err) || j.isCancelled() {
+			return result
+		}
+
+		// The failure may have left the connection in an unknown state.
+		if *conn != nil {
+			j.release(*conn)
+			*conn = nil
+		}
+		if !j.sleep(backoff) {
+			return result
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// verifyCopy compares a copied file with its source and returns the method
+// that confirmed it.
+func verifyCopy(conn Conn, localPath, remotePath string) (string, error) {
+	verifier, ok := conn.(Verifier)
+	if !ok {
+		return "", fmt.Errorf("%s cannot be verified: the connection does not support it", remotePath)
+	}
+	verification, err := VerifyFile(verifier, localPath, remotePath)
+	if err != nil {
+		return "", err
+	}
+	return verification.Method, verification.Err()
+}
+
+// observe reports the bytes moved by the current file of worker when the
+// connection supports progress reporting.
+func (j *Job) observe(worker int, conn Conn) {
+	reporter, ok := conn.(interface{ SetProgress(ProgressFactory) })
+	if !ok {
+		return
+	}
+	reporter.SetProgress(func(string, int64, int64) Progress {
+		return &jobProgress{job: j, worker: worker}
+	})
+}
+
+type jobProgress struct {
+	job    *Job
+	worker int
+}
+
+func (p *jobProgress) Update(transferred int64) {
+	p.job.mu.Lock()
+	if p.worker < len(p.job.inFlight) {
+		p.job.inFlight[p.worker] = transferred
+	}
+	p.job.mu.Unlock()
+}
+
+func (p *jobProgress) Done(error) {}
+
+func (j *Job) record(worker int, file queuedFile, result FileResult) {
+	j.mu.Lock()
+	if worker < len(j.inFlight) {
+		j.inFlight[worker] = 0
+	}
+	if result.Err != nil {
+		j.failed++
+	} else {
+		j.completed++
+		j.doneBytes += file.size
+	}
+	j.mu.Unlock()
+
+	if j.spec.Report != nil {
+		j.spec.Report(result)
+	}
+}
+
+func (j *Job) connect() (Conn, error) {
+	conn, err := j.spec.Connect()
+	if err != nil {
+		return nil, err
+	}
+	if !j.track(conn) {
+		conn.Close()
+		return nil, errors.New("transfer cancelled")
+	}
+	return conn, nil
+}
+
+// track registers conn so that Cancel can close it. It reports false when the
+// job has been cancelled in the meantime.
+func (j *Job) track(conn Conn) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.cancelled {
+		return false
+	}
+	j.conns[conn] = true
+	return true
+}
+
+func (j *Job) release(conn Conn) {
+	j.mu.Lock()
+	tracked := j.conns[conn]
+	delete(j.conns, conn)
+	j.mu.Unlock()
+	if tracked {
+		conn.Close()
+	}
+}
+
+func (j *Job) waitWhilePaused() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for j.paused && !j.cancelled {
+		j.resumed.Wait()
+	}
+	return !j.cancelled
+}
+
+// sleep waits for d and reports false when the job is cancelled meanwhile.
+func (j *Job) sleep(d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if j.isCancelled() {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return !j.isCancelled()
+}
+
+func (j *Job) isCancelled() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.cancelled
+}
+
+func (j *Job) cancel() {
+	j.mu.Lock()
+	j.cancelled = true
+	conns := make([]Conn, 0, len(j.conns))
+	for conn := range j.conns {
+		conns = append(conns, conn)
+	}
+	j.conns = map[Conn]bool{}
+	j.resumed.Broadcast()
+	j.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.finished = time.Now()
+	j.err = err
+	j.inFlight = nil
+	switch {
+	case j.cancelled:
+		j.state = JobCancelled
+	case err != nil || j.failed > 0:
+		j.state = JobFailed
+	default:
+		j.state = JobCompleted
+	}
+}
+
+// IsTransient reports whether a failed transfer is worth retrying. FTP
+// replies in the 5xx range and missing or forbidden files are permanent;
+// anything else, such as dropped connections, timeouts and 4xx replies, may
+// succeed on another attempt.
+func IsTransient(err error) bool {
+	var protocolErr *textproto.Error
+	if errors.As(err, &protocolErr) {
+		return protocolErr.Code < 500
+	}
+	return !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission)
+}
@@ -0,0 +1,432 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sharedRemote lets the connections of a queued job work on one fakeRemote.
+type sharedRemote struct {
+	mu     sync.Mutex
+	remote *fakeRemote
+	// failures holds the errors returned by the next copies of a path.
+	failures map[string][]error
+	// gate, when set, blocks every copy until a value is received from it.
+	gate   chan struct{}
+	open   int
+	opened int
+}
+
+func newSharedRemote(files map[string]string) *sharedRemote {
+	return &sharedRemote{remote: newFakeRemote(files), failures: map[string][]error{}}
+}
+
+func (s *sharedRemote) connect() (Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.open++
+	s.opened++
+	return &sharedConn{shared: s, closed: make(chan struct{})}, nil
+}
+
+func (s *sharedRemote) files() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := map[string]string{}
+	for name, entry := range s.remote.entries {
+		if !entry.isDir {
+			files[name] = string(entry.data)
+		}
+	}
+	return files
+}
+
+type sharedConn struct {
+	shared    *sharedRemote
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// copy waits for the gate, returns a scheduled failure or runs fn.
+func (c *sharedConn) copy(remotePath string, fn func() error) error {
+	if c.shared.gate != nil {
+		select {
+		case <-c.shared.gate:
+		case <-c.closed:
+			return errors.New("connection closed")
+		}
+	}
+
+	c.shared.mu.Lock()
+	defer c.shared.mu.Unlock()
+	if failures := c.shared.failures[remotePath]; len(failures) > 0 {
+		c.shared.failures[remotePath] = failures[1:]
+		return failures[0]
+	}
+	return fn()
+}
+
+func (c *sharedConn) List(dir string) ([]RemoteFile, error) {
+	c.shared.mu.Lock()
+	defer c.shared.mu.Unlock()
+	return c.shared.remote.List(dir)
+}
+
+func (c *sharedConn) Upload(localPath, remotePath string) error {
+	return c.copy(remotePath, func() error { return c.shared.remote.Upload(localPath, remotePath) })
+}
+
+func (c *sharedConn) Download(remotePath, localPath string) error {
+	return c.copy(remotePath, func() error { return c.shared.remote.Download(remotePath, localPath) })
+}
+
+func (c *sharedConn) Retrieve(remotePath string, w io.Writer) error {
+	c.shared.mu.Lock()
+	defer c.shared.mu.Unlock()
+	return c.shared.remote.Retrieve(remotePath, w)
+}
+
+func (c *sharedConn) Mkdir(dir string) error {
+	c.shared.mu.Lock()
+	defer c.shared.mu.Unlock()
+	return c.shared.remote.Mkdir(dir)
+}
+
+func (c *sharedConn) Remove(remotePath string) error {
+	c.shared.mu.Lock()
+	defer c.shared.mu.Unlock()
+	return c.shared.remote.Remove(remotePath)
+}
+
+func (c *sharedConn) RemoveAll(dir string) error {
+	c.shared.mu.Lock()
+	defer c.shared.mu.Unlock()
+	return c.shared.remote.RemoveAll(dir)
+}
+
+func (c *sharedConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.shared.mu.Lock()
+		c.shared.open--
+		c.shared.mu.Unlock()
+	})
+	return nil
+}
+
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(name)
+		rel, _ := filepath.Rel(root, name)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestQueue(t *testing.T) {
+	transient := &textproto.Error{Code: 421, Msg: "Too many connections"}
+	permanent := fmt.Errorf("open: %w", fs.ErrPermission)
+	tree := map[string]string{
+		"a.txt":         "alpha",
+		"big.bin":       strings.Repeat("x", 100),
+		"docs/b.txt":    "bravo",
+		"docs/sub/c.md": "charlie",
+		"empty/":        "",
+	}
+	files := map[string]string{
+		"a.txt":         "alpha",
+		"big.bin":       strings.Repeat("x", 100),
+		"docs/b.txt":    "bravo",
+		"docs/sub/c.md": "charlie",
+	}
+
+	tests := []struct {
+		name        string
+		direction   Direction
+		connections int
+		retries     int
+		failures    map[string][]error
+		filter      Filter
+		noConnect   bool
+		state       JobState
+		completed   int
+		failed      int
+		attempts    map[string]int
+		maxOpened   int
+	}{
+		{name: "upload", direction: Upload, connections: 3, state: JobCompleted, completed: 4, maxOpened: 3},
+		{name: "download", direction: Download, connections: 2, state: JobCompleted, completed: 4, maxOpened: 2},
+		{name: "pool not larger than the files", direction: Upload, connections: 10, state: JobCompleted, completed: 4, maxOpened: 4},
+		{name: "filtered", direction: Upload, connections: 2, filter: Filter{Exclude: []string{"*.txt"}}, state: JobCompleted, completed: 2, maxOpened: 2},
+		{name: "extra connections refused", direction: Upload, connections: 3, noConnect: true, state: JobCompleted, completed: 4, maxOpened: 1},
+		{
+			name:        "transient failure retried",
+			direction:   Upload,
+			connections: 1,
+			retries:     1,
+			failures:    map[string][]error{"/dst/a.txt": {transient}},
+			state:       JobCompleted,
+			completed:   4,
+			attempts:    map[string]int{"a.txt": 2},
+			maxOpened:   2,
+		},
+		{
+			name:        "transient failure without retries",
+			direction:   Upload,
+			connections: 1,
+			failures:    map[string][]error{"/dst/a.txt": {transient}},
+			state:       JobFailed,
+			completed:   3,
+			failed:      1,
+			attempts:    map[string]int{"a.txt": 1},
+			maxOpened:   1,
+		},
+		{
+			name:        "permanent failure not retried",
+			direction:   Download,
+			connections: 1,
+			retries:     3,
+			failures:    map[string][]error{"/dst/docs/b.txt": {permanent}},
+			state:       JobFailed,
+			completed:   3,
+			failed:      1,
+			attempts:    map[string]int{"docs/b.txt": 1},
+			maxOpened:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			localRoot := t.TempDir()
+			shared := newSharedRemote(nil)
+			if tt.direction == Upload {
+				writeTree(t, localRoot, tree)
+			} else {
+				localRoot = filepath.Join(localRoot, "download")
+				for name, content := range tree {
+					shared.remote.add("/dst/"+name, content, oldTime)
+				}
+			}
+			for name, errs := range tt.failures {
+				shared.failures[name] = errs
+			}
+
+			connect := shared.connect
+			if tt.noConnect {
+				connect = func() (Conn, error) { return nil, errors.New("too many connections") }
+			}
+			first, _ := shared.connect()
+
+			var mu sync.Mutex
+			results := map[string]FileResult{}
+			job := NewQueue().Submit(JobSpec{
+				Alias:       "test",
+				Direction:   tt.direction,
+				LocalRoot:   localRoot,
+				RemoteRoot:  "/dst",
+				Filter:      tt.filter,
+				Connections: tt.connections,
+				Retries:     tt.retries,
+				Connect:     connect,
+				Report: func(result FileResult) {
+					mu.Lock()
+					results[result.Path] = result
+					mu.Unlock()
+				},
+			}, first)
+
+			status := job.Wait()
+			if status.State != tt.state || status.Completed != tt.completed || status.Failed != tt.failed {
+				t.Errorf("status = %s, %d completed, %d failed, want %s, %d, %d: %s", status.State, status.Completed, status.Failed, tt.state, tt.completed, tt.failed, status.Error)
+			}
+			if status.Files != tt.completed+tt.failed || len(results) != status.Files {
+				t.Errorf("status counts %d files, %d reported", status.Files, len(results))
+			}
+			if status.Bytes > status.TotalBytes || (tt.failed == 0 && status.Bytes != status.TotalBytes) {
+				t.Errorf("status bytes = %d of %d", status.Bytes, status.TotalBytes)
+			}
+			for rel, attempts := range tt.attempts {
+				if results[rel].Attempts != attempts {
+					t.Errorf("%s took %d attempts, want %d", rel, results[rel].Attempts, attempts)
+				}
+			}
+			if shared.open != 0 || shared.opened > tt.maxOpened {
+				t.Errorf("%d connections left open, %d opened, want at most %d", shared.open, shared.opened, tt.maxOpened)
+			}
+
+			want := map[string]string{}
+			for name, content := range files {
+				if tt.filter.Match(name, false) && results[name].Err == nil {
+					want[name] = content
+				}
+			}
+			got := readTree(t, localRoot)
+			if tt.direction == Upload {
+				got = map[string]string{}
+				for name, content := range shared.files() {
+					got[strings.TrimPrefix(name, "/dst/")] = content
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("copied files = %v, want %v", keys(got), keys(want))
+			}
+		})
+	}
+}
+
+func keys(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestQueueMissingSource(t *testing.T) {
+	shared := newSharedRemote(nil)
+	first, _ := shared.connect()
+	job := NewQueue().Submit(JobSpec{
+		Direction:  Upload,
+		LocalRoot:  filepath.Join(t.TempDir(), "missing"),
+		RemoteRoot: "/dst",
+		Connect:    shared.connect,
+	}, first)
+
+	status := job.Wait()
+	if status.State != JobFailed || status.Error == "" || shared.open != 0 {
+		t.Errorf("status = %s (%q), %d connections open", status.State, status.Error, shared.open)
+	}
+}
+
+func TestQueuePauseResumeCancel(t *testing.T) {
+	localRoot := t.TempDir()
+	writeTree(t, localRoot, map[string]string{"a": "1", "b": "2", "c": "3"})
+
+	shared := newSharedRemote(nil)
+	shared.gate = make(chan struct{})
+	first, _ := shared.connect()
+
+	queue := NewQueue()
+	job := queue.Submit(JobSpec{
+		Alias:       "test",
+		Direction:   Upload,
+		LocalRoot:   localRoot,
+		RemoteRoot:  "/dst",
+		Connections: 1,
+		Connect:     shared.connect,
+	}, first)
+	waitForState(t, job, JobRunning)
+
+	if err := queue.Pause(job.ID()); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, job, JobPaused)
+
+	// The file in progress completes; the next one waits for Resume.
+	shared.gate <- struct{}{}
+	waitFor(t, func() bool { return job.Status().Completed == 1 })
+	time.Sleep(50 * time.Millisecond)
+	if status := job.Status(); status.Completed != 1 || status.State != JobPaused {
+		t.Fatalf("paused job went on: %+v", status)
+	}
+
+	if err := queue.Resume(job.ID()); err != nil {
+		t.Fatal(err)
+	}
+	shared.gate <- struct{}{}
+	waitFor(t, func() bool { return job.Status().Completed == 2 })
+
+	if !queue.Running() {
+		t.Error("queue reports no running job")
+	}
+	if err := queue.Cancel(job.ID()); err != nil {
+		t.Fatal(err)
+	}
+	status := job.Wait()
+	if status.State != JobCancelled || status.Completed != 2 || shared.open != 0 {
+		t.Errorf("cancelled job = %+v, %d connections open", status, shared.open)
+	}
+	if queue.Running() {
+		t.Error("queue reports a running job after cancelling it")
+	}
+
+	tests := []struct {
+		name string
+		call func(int) error
+		id   int
+		err  string
+	}{
+		{name: "pause finished", call: queue.Pause, id: job.ID(), err: "already finished"},
+		{name: "resume finished", call: queue.Resume, id: job.ID(), err: "already finished"},
+		{name: "cancel finished", call: queue.Cancel, id: job.ID(), err: "already finished"},
+		{name: "unknown id", call: queue.Cancel, id: 42, err: "no transfer with id 42"},
+	}
+	for _, tt := range tests {
+		if err := tt.call(tt.id); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	jobs := queue.Jobs()
+	if len(jobs) != 1 || jobs[0].ID != 1 || jobs[0].Source != localRoot || jobs[0].Destination != "test:/dst" {
+		t.Errorf("Jobs = %+v", jobs)
+	}
+}
+
+func waitForState(t *testing.T, job *Job, state JobState) {
+	t.Helper()
+	waitFor(t, func() bool { return job.Status().State == state })
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "ftp 4xx", err: &textproto.Error{Code: 425, Msg: "Can't open data connection"}, want: true},
+		{name: "ftp 5xx", err: fmt.Errorf("upload: %w", &textproto.Error{Code: 550, Msg: "Permission denied"}), want: false},
+		{name: "missing file", err: fmt.Errorf("stat: %w", fs.ErrNotExist), want: false},
+		{name: "forbidden", err: os.ErrPermission, want: false},
+		{name: "dropped connection", err: io.ErrUnexpectedEOF, want: true},
+		{name: "mismatch", err: fmt.Errorf("a: %w", ErrMismatch), want: true},
+	}
+
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("%s: IsTransient(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+// Package transfer implements protocol independent file transfer logic such
+// as directory synchronisation. Protocol clients are adapted to the Remote
+// interface by their callers.
+package transfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RemoteFile describes an entry returned by Remote.List.
+type RemoteFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// Remote is the set of operations a synchronisation needs from a protocol
+// client. Paths are slash separated.
+type Remote interface {
+	List(dir string) ([]RemoteFile, error)
+	Upload(localPath, remotePath string) error
+	Download(remotePath, localPath string) error
+	Retrieve(remotePath string, w io.Writer) error
+	Mkdir(dir string) error
+	Remove(remotePath string) error
+	RemoveAll(dir string) error
+}
+
+// Direction selects which side of a synchronisation is the source.
+type Direction string
+
+const (
+	// Upload makes the remote tree mirror the local tree.
+	Upload Direction = "upload"
+	// Download makes the local tree mirror the remote tree.
+	Download Direction = "download"
+)
+
+// SyncOptions controls how trees are compared.
+type SyncOptions struct {
+	Direction Direction
+	// Delete removes destination entries that do not exist in the source.
+	Delete bool
+	// Checksum compares the SHA-256 of files whose size matches instead of
+	// relying on modification times. Remote files are read for this.
+	Checksum bool
+	// Force allows Delete to empty the destination when the source is empty,
+	// which usually means that the source path is wrong.
+	Force  bool
+	Filter Filter
+	// Tolerance absorbs the limited timestamp precision of some servers.
+	Tolerance time.Duration
+}
+
+// Action is the operation planned for one path.
+type Action string
+
+const (
+	ActionMkdir  Action = "mkdir"
+	ActionCopy   Action = "copy"
+	ActionDelete Action = "delete"
+	ActionRmdir  Action = "rmdir"
+)
+
+// Step is one planned operation. Path is relative to the synchronised roots.
+type Step struct {
+	Action Action
+	Path   string
+	Size   int64
+	Reason string
+	// ModTime is the modification time of the source file. Downloads apply it
+	// to the local copy so the next comparison sees both sides as equal.
+	ModTime time.Time
+}
+
+// SyncSummary aggregates the outcome of Apply.
+type SyncSummary struct {
+	Copied   int
+	Deleted  int
+	Created  int
+	Failed   int
+	Bytes    int64
+	Duration time.Duration
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// Plan compares the local and remote trees and returns the steps needed to
+// make the destination match the source. Directories are created before the
+// files they contain and removed after them.
+func Plan(remote Remote, localRoot, remoteRoot string, options SyncOptions) ([]Step, error) {
+	// Only the destination may be missing; it is created by the first step.
+	// A source that cannot be read must never look like an empty tree, as
+	// that would delete the whole destination.
+	download := options.Direction == Download
+	local, err := scanLocal(localRoot, options.Filter, download)
+	if err != nil {
+		return nil, err
+	}
+	remoteTree, err := scanRemote(remote, remoteRoot, options.Filter, !download)
+	if err != nil {
+		return nil, err
+	}
+
+	source, destination := local, remoteTree
+	sourceRoot := localRoot
+	if download {
+		source, destination = remoteTree, local
+		sourceRoot = remoteRoot
+	}
+	if options.Delete && !options.Force && len(source) == 0 && len(destination) > 0 {
+		return nil, fmt.Errorf("%s is empty; refusing to delete all %d entries of the destination, use --force to do so", sourceRoot, len(destination))
+	}
+
+	steps := []Step{}
+	// removed holds the destination entries deleted because the source has
+	// an entry of the other type at the same path.
+	removed := []Step{}
+	conflicts := []string{}
+	replace := func(rel string, dst fileState) {
+		if !options.Delete {
+			conflicts = append(conflicts, rel)
+			return
+		}
+		step := Step{Action: ActionDelete, Path: rel, Reason: "replaced by a directory"}
+		if dst.isDir {
+			step = Step{Action: ActionRmdir, Path: rel, Reason: "replaced by a file"}
+		}
+		steps = append(steps, step)
+		removed = append(removed, step)
+	}
+
+	for _, rel := range sortedPaths(source) {
+		src := source[rel]
+		dst, exists := destination[rel]
+
+		if src.isDir {
+			if exists && !dst.isDir {
+				replace(rel, dst)
+				exists = false
+			}
+			if !exists {
+				steps = append(steps, Step{Action: ActionMkdir, Path: rel, Reason: "new"})
+			}
+			continue
+		}
+
+		reason := ""
+		switch {
+		case !exists:
+			reason = "new"
+		case dst.isDir:
+			replace(rel, dst)
+			reason = "replaces directory"
+		case src.size != dst.size:
+			reason = "size changed"
+		case options.Checksum:
+			same, err := sameContent(remote, localRoot, remoteRoot, rel)
+			if err != nil {
+				return nil, err
+			}
+			if !same {
+				reason = "checksum differs"
+			}
+		case src.modTime.After(dst.modTime.Add(options.Tolerance)):
+			reason = "newer"
+		}
+		if reason != "" {
+			steps = append(steps, Step{Action: ActionCopy, Path: rel, Size: src.size, Reason: reason, ModTime: src.modTime})
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("%s %s a file on one side and a directory on the other; use --delete to replace the destination", strings.Join(conflicts, ", "), plural(len(conflicts), "is", "are"))
+	}
+
+	if options.Delete {
+		extraneous := []Step{}
+		for _, rel := range sortedPaths(destination) {
+			if _, exists := source[rel]; exists || insideAny(rel, extraneous) || insideAny(rel, removed) {
+				continue
+			}
+			action := ActionDelete
+			if destination[rel].isDir {
+				action = ActionRmdir
+			}
+			extraneous = append(extraneous, Step{Action: action, Path: rel, Reason: "extraneous"})
+		}
+		// Delete children before their parents.
+		for i := len(extraneous) - 1; i >= 0; i-- {
+			steps = append(steps, extraneous[i])
+		}
+	}
+
+	return steps, nil
+}
+
+// Apply executes the planned steps. Failed steps are passed to report and
+// counted; the remaining steps are still attempted.
+func Apply(remote Remote, localRoot, remoteRoot string, direction Direction, steps []Step, report func(Step, error)) SyncSummary {
+	started := time.Now()
+	summary := SyncSummary{}
+
+	for _, step := range steps {
+		localPath := filepath.Join(localRoot, filepath.FromSlash(step.Path))
+		remotePath := path.Join(remoteRoot, step.Path)
+
+		var err error
+		switch {
+		case step.Action == ActionMkdir && direction == Upload:
+			err = remote.Mkdir(remotePath)
+		case step.Action == ActionMkdir:
+			err = os.MkdirAll(localPath, 0750)
+		case step.Action == ActionCopy && direction == Upload:
+			err = remote.Upload(localPath, remotePath)
+		case step.Action == ActionCopy:
+			if err = remote.Download(remotePath, localPath); err == nil && !step.ModTime.IsZero() {
+				err = os.Chtimes(localPath, step.ModTime, step.ModTime)
+			}
+		case step.Action == ActionDelete && direction == Upload:
+			err = remote.Remove(remotePath)
+		case step.Action == ActionDelete:
+			err = os.Remove(localPath)
+		case step.Action == ActionRmdir && direction == Upload:
+			err = remote.RemoveAll(remotePath)
+		case step.Action == ActionRmdir:
+			err = os.RemoveAll(localPath)
+		}
+
+		report(step, err)
+		if err != nil {
+			summary.Failed++
+			continue
+		}
+		switch step.Action {
+		case ActionCopy:
+			summary.Copied++
+			summary.Bytes += step.Size
+		case ActionMkdir:
+			summary.Created++
+		case ActionDelete, ActionRmdir:
+			summary.Deleted++
+		}
+	}
+
+	summary.Duration = time.Since(started)
+	return summary
+}
+
+// scanLocal lists the tree below root. A root that does not exist yields an
+// empty tree when missingOK is set and an error otherwise.
+func scanLocal(root string, filter Filter, missingOK bool) (map[string]fileState, error) {
+	tree := map[string]fileState{}
+	if _, err := os.Stat(root); err != nil {
+		if missingOK && errors.Is(err, fs.ErrNotExist) {
+			return tree, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", root, err)
+	}
+
+	err := filepath.WalkDir(root, func(localPath string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if localPath == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, localPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if !filter.Match(rel, true) {
+				return filepath.SkipDir
+			}
+			tree[rel] = fileState{isDir: true}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !filter.Match(rel, false) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		tree[rel] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+	return tree, nil
+}
+
+// scanRemote lists the tree below root. Like scanLocal it only accepts a
+// missing root when missingOK is set, and only when the server confirmed that
+// it does not exist; other failures such as a denied listing or a dropped
+// connection are errors.
+func scanRemote(remote Remote, root string, filter Filter, missingOK bool) (map[string]fileState, error) {
+	tree := map[string]fileState{}
+	entries, err := remote.List(root)
+	if err != nil {
+		if missingOK && errors.Is(err, fs.ErrNotExist) {
+			return tree, nil
+		}
+		return nil, err
+	}
+	return tree, scanRemoteEntries(remote, root, "", entries, filter, tree)
+}
+
+func scanRemoteDir(remote Remote, root, rel string, filter Filter, tree map[string]fileState) error {
+	entries, err := remote.List(path.Join(root, rel))
+	if err != nil {
+		return err
+	}
+	return scanRemoteEntries(remote, root, rel, entries, filter, tree)
+}
+
+func scanRemoteEntries(remote Remote, root, rel string, entries []RemoteFile, filter Filter, tree map[string]fileState) error {
+	for _, entry := range entries {
+		name := path.Base(entry.Name)
+		if name == "." || name == ".." {
+			continue
+		}
+		childRel := path.Join(rel, name)
+		if !filter.Match(childRel, entry.IsDir) {
+			continue
+		}
+		if entry.IsDir {
+			tree[childRel] = fileState{isDir: true}
+			if err := scanRemoteDir(remote, root, childRel, filter, tree); err != nil {
+				return err
+			}
+			continue
+		}
+		tree[childRel] = fileState{size: entry.Size, modTime: entry.ModTime}
+	}
+	return nil
+}
+
+func sameContent(remote Remote, localRoot, remoteRoot, rel string) (bool, error) {
+	file, err := os.Open(filepath.Join(localRoot, filepath.FromSlash(rel)))
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	localHash := sha256.New()
+	if _, err := io.Copy(localHash, file); err != nil {
+		return false, err
+	}
+
+	remoteHash := sha256.New()
+	if err := remote.Retrieve(path.Join(remoteRoot, rel), remoteHash); err != nil {
+		return false, fmt.Errorf("failed to read %s for checksum: %w", rel, err)
+	}
+
+	return hex.EncodeToString(localHash.Sum(nil)) == hex.EncodeToString(remoteHash.Sum(nil)), nil
+}
+
+func sortedPaths(tree map[string]fileState) []string {
+	paths := make([]string, 0, len(tree))
+	for rel := range tree {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// insideAny reports whether rel lies inside a directory already scheduled for
+// removal, which removes its contents as well.
+func insideAny(rel string, steps []Step) bool {
+	for _, step := range steps {
+		if step.Action == ActionRmdir && strings.HasPrefix(rel, step.Path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func plural(n int, singular, multiple string) string {
+	if n == 1 {
+		return singular
+	}
+	return multiple
+}
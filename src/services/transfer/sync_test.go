@@ -0,0 +1,412 @@
+package transfer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newTime = oldTime.Add(time.Hour)
+)
+
+// fakeEntry is a file or, with a nil data slice and isDir set, a directory of
+// fakeRemote.
+type fakeEntry struct {
+	data    []byte
+	isDir   bool
+	modTime time.Time
+}
+
+// fakeRemote is an in-memory Remote. Paths are absolute and slash separated.
+type fakeRemote struct {
+	entries map[string]fakeEntry
+	// listErr is returned when listing the path it is keyed by.
+	listErr map[string]error
+}
+
+func newFakeRemote(files map[string]string) *fakeRemote {
+	remote := &fakeRemote{entries: map[string]fakeEntry{}, listErr: map[string]error{}}
+	for name, content := range files {
+		remote.add(name, content, oldTime)
+	}
+	return remote
+}
+
+// add creates a file, or a directory when name ends with a slash, together
+// with its parent directories.
+func (r *fakeRemote) add(name, content string, modTime time.Time) {
+	isDir := strings.HasSuffix(name, "/")
+	name = path.Clean("/" + name)
+	for dir := path.Dir(name); dir != "/"; dir = path.Dir(dir) {
+		r.entries[dir] = fakeEntry{isDir: true, modTime: modTime}
+	}
+	if isDir {
+		r.entries[name] = fakeEntry{isDir: true, modTime: modTime}
+		return
+	}
+	r.entries[name] = fakeEntry{data: []byte(content), modTime: modTime}
+}
+
+func (r *fakeRemote) List(dir string) ([]RemoteFile, error) {
+	dir = path.Clean(dir)
+	if err, ok := r.listErr[dir]; ok {
+		return nil, err
+	}
+	if entry, ok := r.entries[dir]; dir != "/" && (!ok || !entry.isDir) {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, fs.ErrNotExist)
+	}
+	files := []RemoteFile{}
+	for name, entry := range r.entries {
+		if path.Dir(name) == dir && name != dir {
+			files = append(files, RemoteFile{Name: path.Base(name), Size: int64(len(entry.data)), ModTime: entry.modTime, IsDir: entry.isDir})
+		}
+	}
+	return files, nil
+}
+
+func (r *fakeRemote) Upload(localPath, remotePath string) error {
+	if entry, ok := r.entries[path.Clean(remotePath)]; ok && entry.isDir {
+		return fmt.Errorf("%s is a directory", remotePath)
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	r.entries[path.Clean(remotePath)] = fakeEntry{data: data, modTime: newTime}
+	return nil
+}
+
+func (r *fakeRemote) Download(remotePath, localPath string) error {
+	entry, ok := r.entries[path.Clean(remotePath)]
+	if !ok || entry.isDir {
+		return fmt.Errorf("%s: %w", remotePath, fs.ErrNotExist)
+	}
+	return os.WriteFile(localPath, entry.data, 0o644)
+}
+
+func (r *fakeRemote) Retrieve(remotePath string, w io.Writer) error {
+	entry, ok := r.entries[path.Clean(remotePath)]
+	if !ok || entry.isDir {
+		return fmt.Errorf("%s: %w", remotePath, fs.ErrNotExist)
+	}
+	_, err := io.Copy(w, bytes.NewReader(entry.data))
+	return err
+}
+
+func (r *fakeRemote) Mkdir(dir string) error {
+	if entry, ok := r.entries[path.Clean(dir)]; ok && !entry.isDir {
+		return fmt.Errorf("%s is a file", dir)
+	}
+	r.add(dir+"/", "", newTime)
+	return nil
+}
+
+func (r *fakeRemote) Remove(remotePath string) error {
+	delete(r.entries, path.Clean(remotePath))
+	return nil
+}
+
+func (r *fakeRemote) RemoveAll(dir string) error {
+	dir = path.Clean(dir)
+	for name := range r.entries {
+		if name == dir || strings.HasPrefix(name, dir+"/") {
+			delete(r.entries, name)
+		}
+	}
+	return nil
+}
+
+// writeTree creates files below root; names ending with a slash are
+// directories. All entries get oldTime unless listed in newer.
+func writeTree(t *testing.T, root string, files map[string]string, newer ...string) {
+	t.Helper()
+	for name, content := range files {
+		target := filepath.Join(root, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := oldTime
+		for _, newerName := range newer {
+			if newerName == name {
+				modTime = newTime
+			}
+		}
+		if err := os.Chtimes(target, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func describeSteps(steps []Step) []string {
+	described := make([]string, len(steps))
+	for i, step := range steps {
+		described[i] = fmt.Sprintf("%s %s", step.Action, step.Path)
+	}
+	return described
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name       string
+		local      map[string]string
+		newerLocal []string
+		remote     map[string]string
+		// missingLocal removes the local root before planning.
+		missingLocal bool
+		remoteRoot   string
+		listErr      error
+		options      SyncOptions
+		want         []string
+		wantErr      string
+	}{
+		{
+			name:    "new files and directories are created",
+			local:   map[string]string{"a.txt": "a", "sub/b.txt": "b", "empty/": ""},
+			remote:  map[string]string{},
+			options: SyncOptions{Direction: Upload},
+			want:    []string{"copy a.txt", "mkdir empty", "mkdir sub", "copy sub/b.txt"},
+		},
+		{
+			name:    "identical trees need no steps",
+			local:   map[string]string{"a.txt": "a"},
+			remote:  map[string]string{"a.txt": "a"},
+			options: SyncOptions{Direction: Upload},
+			want:    []string{},
+		},
+		{
+			name:    "size change is copied",
+			local:   map[string]string{"a.txt": "abc"},
+			remote:  map[string]string{"a.txt": "a"},
+			options: SyncOptions{Direction: Upload},
+			want:    []string{"copy a.txt"},
+		},
+		{
+			name:       "newer source is copied",
+			local:      map[string]string{"a.txt": "a"},
+			newerLocal: []string{"a.txt"},
+			remote:     map[string]string{"a.txt": "b"},
+			options:    SyncOptions{Direction: Upload},
+			want:       []string{"copy a.txt"},
+		},
+		{
+			name:       "tolerance absorbs timestamp precision",
+			local:      map[string]string{"a.txt": "a"},
+			newerLocal: []string{"a.txt"},
+			remote:     map[string]string{"a.txt": "b"},
+			options:    SyncOptions{Direction: Upload, Tolerance: 2 * time.Hour},
+			want:       []string{},
+		},
+		{
+			name:    "checksum detects changed content of equal size",
+			local:   map[string]string{"a.txt": "aaa", "b.txt": "same"},
+			remote:  map[string]string{"a.txt": "bbb", "b.txt": "same"},
+			options: SyncOptions{Direction: Upload, Checksum: true},
+			want:    []string{"copy a.txt"},
+		},
+		{
+			name:    "delete removes children before parents",
+			local:   map[string]string{"keep.txt": "k"},
+			remote:  map[string]string{"keep.txt": "k", "old/x.txt": "x", "old.txt": "o"},
+			options: SyncOptions{Direction: Upload, Delete: true},
+			want:    []string{"delete old.txt", "rmdir old"},
+		},
+		{
+			name:    "without delete extraneous files stay",
+			local:   map[string]string{"keep.txt": "k"},
+			remote:  map[string]string{"keep.txt": "k", "old.txt": "o"},
+			options: SyncOptions{Direction: Upload},
+			want:    []string{},
+		},
+		{
+			name:    "file replacing a directory needs delete",
+			local:   map[string]string{"a": "file"},
+			remote:  map[string]string{"a/x.txt": "x"},
+			options: SyncOptions{Direction: Upload},
+			wantErr: "a is a file on one side and a directory on the other",
+		},
+		{
+			name:    "file replacing a directory removes it first",
+			local:   map[string]string{"a": "file"},
+			remote:  map[string]string{"a/x.txt": "x"},
+			options: SyncOptions{Direction: Upload, Delete: true},
+			want:    []string{"rmdir a", "copy a"},
+		},
+		{
+			name:    "directory replacing a file removes it first",
+			local:   map[string]string{"a/x.txt": "x"},
+			remote:  map[string]string{"a": "file"},
+			options: SyncOptions{Direction: Upload, Delete: true},
+			want:    []string{"delete a", "mkdir a", "copy a/x.txt"},
+		},
+		{
+			name:    "download compares the other way round",
+			local:   map[string]string{"a.txt": "a", "extra.txt": "e"},
+			remote:  map[string]string{"a.txt": "changed", "new/b.txt": "b"},
+			options: SyncOptions{Direction: Download, Delete: true},
+			want:    []string{"copy a.txt", "mkdir new", "copy new/b.txt", "delete extra.txt"},
+		},
+		{
+			name:       "missing remote destination is created",
+			local:      map[string]string{"a.txt": "a"},
+			remote:     map[string]string{},
+			remoteRoot: "/missing",
+			options:    SyncOptions{Direction: Upload, Delete: true},
+			want:       []string{"copy a.txt"},
+		},
+		{
+			name:         "missing local source is an error",
+			missingLocal: true,
+			remote:       map[string]string{"a.txt": "a"},
+			options:      SyncOptions{Direction: Upload, Delete: true},
+			wantErr:      "failed to read",
+		},
+		{
+			name:       "missing remote source is an error",
+			local:      map[string]string{"a.txt": "a"},
+			remote:     map[string]string{},
+			remoteRoot: "/missing",
+			options:    SyncOptions{Direction: Download, Delete: true},
+			wantErr:    "file does not exist",
+		},
+		{
+			name:    "unreadable remote destination is an error",
+			local:   map[string]string{"a.txt": "a"},
+			remote:  map[string]string{"a.txt": "a"},
+			listErr: fs.ErrPermission,
+			options: SyncOptions{Direction: Upload, Delete: true},
+			wantErr: "permission denied",
+		},
+		{
+			name:    "empty source does not empty the destination",
+			local:   map[string]string{},
+			remote:  map[string]string{"a.txt": "a"},
+			options: SyncOptions{Direction: Upload, Delete: true},
+			wantErr: "refusing to delete all 1 entries",
+		},
+		{
+			name:    "empty source empties the destination when forced",
+			local:   map[string]string{},
+			remote:  map[string]string{"a.txt": "a"},
+			options: SyncOptions{Direction: Upload, Delete: true, Force: true},
+			want:    []string{"delete a.txt"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			localRoot := filepath.Join(t.TempDir(), "local")
+			if err := os.Mkdir(localRoot, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			writeTree(t, localRoot, test.local, test.newerLocal...)
+			if test.missingLocal {
+				os.RemoveAll(localRoot)
+			}
+
+			remote := newFakeRemote(test.remote)
+			remoteRoot := test.remoteRoot
+			if remoteRoot == "" {
+				remoteRoot = "/"
+			}
+			if test.listErr != nil {
+				remote.listErr[remoteRoot] = test.listErr
+			}
+
+			steps, err := Plan(remote, localRoot, remoteRoot, test.options)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Plan() error = %v, want it to contain %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+			got := describeSteps(steps)
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("Plan() steps = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestApplyReplacesConflictingTypes checks that the planned removals make
+// the following copy and mkdir steps succeed in both directions.
+func TestApplyReplacesConflictingTypes(t *testing.T) {
+	for _, direction := range []Direction{Upload, Download} {
+		t.Run(string(direction), func(t *testing.T) {
+			localRoot := t.TempDir()
+			remote := newFakeRemote(nil)
+			source := map[string]string{"a": "file", "b/x.txt": "x"}
+			destination := map[string]string{"a/y.txt": "y", "b": "file"}
+			if direction == Upload {
+				writeTree(t, localRoot, source)
+				for name, content := range destination {
+					remote.add(name, content, oldTime)
+				}
+			} else {
+				writeTree(t, localRoot, destination)
+				for name, content := range source {
+					remote.add(name, content, oldTime)
+				}
+			}
+
+			steps, err := Plan(remote, localRoot, "/", SyncOptions{Direction: direction, Delete: true})
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+			summary := Apply(remote, localRoot, "/", direction, steps, func(step Step, err error) {
+				if err != nil {
+					t.Errorf("%s %s: %v", step.Action, step.Path, err)
+				}
+			})
+			if summary.Failed > 0 {
+				t.Fatalf("Apply() failed %d step(s)", summary.Failed)
+			}
+
+			// Both sides must now hold the source tree.
+			again, err := Plan(remote, localRoot, "/", SyncOptions{Direction: direction, Delete: true, Checksum: true, Tolerance: 24 * time.Hour})
+			if err != nil {
+				t.Fatalf("second Plan() error = %v", err)
+			}
+			if len(again) > 0 {
+				t.Errorf("trees differ after Apply: %q", describeSteps(again))
+			}
+		})
+	}
+}
+
+func TestScanRemoteMissingRoot(t *testing.T) {
+	remote := newFakeRemote(map[string]string{"a.txt": "a"})
+
+	tree, err := scanRemote(remote, "/missing", Filter{}, true)
+	if err != nil || len(tree) != 0 {
+		t.Errorf("scanRemote(missingOK) = %v, %v; want an empty tree", tree, err)
+	}
+	if _, err := scanRemote(remote, "/missing", Filter{}, false); err == nil {
+		t.Error("scanRemote() of a missing source succeeded")
+	}
+
+	remote.listErr["/"] = fmt.Errorf("connection reset")
+	if _, err := scanRemote(remote, "/", Filter{}, true); err == nil {
+		t.Error("scanRemote() swallowed a listing error")
+	}
+}
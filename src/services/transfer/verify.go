@@ -0,0 +1,206 @@
+package transfer
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoChecksum is returned by Verifier.Checksum when the server offers no
+// way to hash a file. Verification then falls back to comparing sizes.
+var ErrNoChecksum = errors.New("server cannot compute checksums")
+
+// ErrMismatch marks a copy whose checksum or size differs from its source.
+var ErrMismatch = errors.New("content differs")
+
+// Checksum is a digest computed by the server. Algorithm uses the names of
+// the FTP HASH command, e.g. SHA-256 or MD5; Sum is lower case hex.
+type Checksum struct {
+	Algorithm string
+	Sum       string
+}
+
+// Verifier is the set of operations verification needs from a protocol
+// client in addition to Remote.
+type Verifier interface {
+	Checksum(remotePath string) (Checksum, error)
+	Size(remotePath string) (int64, error)
+}
+
+// VerifyStatus is the outcome of comparing one file.
+type VerifyStatus string
+
+const (
+	VerifyOK       VerifyStatus = "ok"
+	VerifyMismatch VerifyStatus = "mismatch"
+	// VerifyMissing marks a local file that does not exist remotely.
+	VerifyMissing VerifyStatus = "missing"
+	// VerifyExtra marks a remote file that does not exist locally.
+	VerifyExtra VerifyStatus = "extra"
+	VerifyError VerifyStatus = "error"
+)
+
+// Verification describes the comparison of a local file with its remote copy.
+// Method is the checksum algorithm, or "size" when the server cannot hash
+// files.
+type Verification struct {
+	Path       string       `json:"path" yaml:"path"`
+	Status     VerifyStatus `json:"status" yaml:"status"`
+	Method     string       `json:"method,omitempty" yaml:"method,omitempty"`
+	LocalSize  int64        `json:"localSize" yaml:"localSize"`
+	RemoteSize int64        `json:"remoteSize" yaml:"remoteSize"`
+	Local      string       `json:"local,omitempty" yaml:"local,omitempty"`
+	Remote     string       `json:"remote,omitempty" yaml:"remote,omitempty"`
+	Error      string       `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Err returns nil for a matching file and an error wrapping ErrMismatch for
+// a file whose content differs.
+func (v Verification) Err() error {
+	switch v.Status {
+	case VerifyOK:
+		return nil
+	case VerifyMismatch:
+		if v.Method == "size" {
+			return fmt.Errorf("%s: %w: local %d bytes, remote %d bytes", v.Path, ErrMismatch, v.LocalSize, v.RemoteSize)
+		}
+		return fmt.Errorf("%s: %w: local %s %s, remote %s", v.Path, ErrMismatch, v.Method, v.Local, v.Remote)
+	case VerifyError:
+		return fmt.Errorf("%s: %s", v.Path, v.Error)
+	default:
+		return fmt.Errorf("%s: %s", v.Path, v.Status)
+	}
+}
+
+// VerifyFile compares a local file with a remote one. The server computes
+// the checksum so the remote file does not have to be downloaded; servers
+// without a hash command are checked by size only.
+func VerifyFile(remote Verifier, localPath, remotePath string) (Verification, error) {
+	result := Verification{Path: remotePath}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+	result.LocalSize = info.Size()
+
+	result.RemoteSize, err = remote.Size(remotePath)
+	if err != nil {
+		return result, fmt.Errorf("failed to read the size of %s: %w", remotePath, err)
+	}
+	if result.LocalSize != result.RemoteSize {
+		result.Method = "size"
+		result.Status = VerifyMismatch
+		return result, nil
+	}
+
+	checksum, err := remote.Checksum(remotePath)
+	if errors.Is(err, ErrNoChecksum) {
+		result.Method = "size"
+		result.Status = VerifyOK
+		return result, nil
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to compute the checksum of %s: %w", remotePath, err)
+	}
+
+	local, err := hashLocal(localPath, checksum.Algorithm)
+	if err != nil {
+		return result, err
+	}
+	result.Method = checksum.Algorithm
+	result.Local = local
+	result.Remote = strings.ToLower(checksum.Sum)
+	result.Status = VerifyOK
+	if result.Local != result.Remote {
+		result.Status = VerifyMismatch
+	}
+	return result, nil
+}
+
+// VerifyTree compares every file below localRoot with its counterpart below
+// remoteRoot. Files present on one side only are reported as missing or
+// extra; a failure to compare a single file is recorded in its result.
+func VerifyTree(remote interface {
+	Remote
+	Verifier
+}, localRoot, remoteRoot string, filter Filter) ([]Verification, error) {
+	local, err := scanLocal(localRoot, filter, false)
+	if err != nil {
+		return nil, err
+	}
+	remoteTree, err := scanRemote(remote, remoteRoot, filter, true)
+	if err != nil {
+		return nil, err
+	}
+
+	all := map[string]fileState{}
+	for rel, state := range remoteTree {
+		all[rel] = state
+	}
+	for rel, state := range local {
+		all[rel] = state
+	}
+
+	results := []Verification{}
+	for _, rel := range sortedPaths(all) {
+		localState, inLocal := local[rel]
+		remoteState, inRemote := remoteTree[rel]
+		if localState.isDir || remoteState.isDir {
+			continue
+		}
+
+		switch {
+		case !inRemote:
+			results = append(results, Verification{Path: rel, Status: VerifyMissing, LocalSize: localState.size})
+		case !inLocal:
+			results = append(results, Verification{Path: rel, Status: VerifyExtra, RemoteSize: remoteState.size})
+		default:
+			result, err := VerifyFile(remote, filepath.Join(localRoot, filepath.FromSlash(rel)), path.Join(remoteRoot, rel))
+			result.Path = rel
+			if err != nil {
+				result.Status = VerifyError
+				result.Error = err.Error()
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// hashLocal computes the digest of a local file with the named algorithm.
+func hashLocal(localPath, algorithm string) (string, error) {
+	var digest hash.Hash
+	switch strings.ToUpper(algorithm) {
+	case "SHA-256":
+		digest = sha256.New()
+	case "SHA-512":
+		digest = sha512.New()
+	case "SHA-1":
+		digest = sha1.New()
+	case "MD5":
+		digest = md5.New()
+	default:
+		return "", fmt.Errorf("unsupported checksum algorithm %s", algorithm)
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(digest, file); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
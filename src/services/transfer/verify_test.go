@@ -0,0 +1,261 @@
+package transfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeVerifier answers Size and Checksum from fixed values.
+type fakeVerifier struct {
+	size        int64
+	sizeErr     error
+	checksum    Checksum
+	checksumErr error
+}
+
+func (v fakeVerifier) Size(string) (int64, error) { return v.size, v.sizeErr }
+
+func (v fakeVerifier) Checksum(string) (Checksum, error) { return v.checksum, v.checksumErr }
+
+func TestVerifyFile(t *testing.T) {
+	// Digests of "test".
+	const (
+		sha256Sum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+		md5Sum    = "098f6bcd4621d373cade4e832627b4f6"
+	)
+
+	tests := []struct {
+		name     string
+		remote   fakeVerifier
+		want     Verification
+		err      string
+		mismatch bool
+	}{
+		{
+			name:   "matching checksum",
+			remote: fakeVerifier{size: 4, checksum: Checksum{Algorithm: "SHA-256", Sum: strings.ToUpper(sha256Sum)}},
+			want:   Verification{Path: "/f", Status: VerifyOK, Method: "SHA-256", LocalSize: 4, RemoteSize: 4, Local: sha256Sum, Remote: sha256Sum},
+		},
+		{
+			name:     "different checksum",
+			remote:   fakeVerifier{size: 4, checksum: Checksum{Algorithm: "MD5", Sum: strings.Repeat("0", 32)}},
+			want:     Verification{Path: "/f", Status: VerifyMismatch, Method: "MD5", LocalSize: 4, RemoteSize: 4, Local: md5Sum, Remote: strings.Repeat("0", 32)},
+			mismatch: true,
+		},
+		{
+			name:     "different size skips the checksum",
+			remote:   fakeVerifier{size: 5, checksumErr: errors.New("not called")},
+			want:     Verification{Path: "/f", Status: VerifyMismatch, Method: "size", LocalSize: 4, RemoteSize: 5},
+			mismatch: true,
+		},
+		{
+			name:   "server without checksums",
+			remote: fakeVerifier{size: 4, checksumErr: ErrNoChecksum},
+			want:   Verification{Path: "/f", Status: VerifyOK, Method: "size", LocalSize: 4, RemoteSize: 4},
+		},
+		{
+			name:   "size fails",
+			remote: fakeVerifier{sizeErr: errors.New("550 not found")},
+			err:    "failed to read the size of /f: 550 not found",
+		},
+		{
+			name:   "checksum fails",
+			remote: fakeVerifier{size: 4, checksumErr: errors.New("timeout")},
+			err:    "failed to compute the checksum of /f: timeout",
+		},
+		{
+			name:   "unknown algorithm",
+			remote: fakeVerifier{size: 4, checksum: Checksum{Algorithm: "CRC32", Sum: "d87f7e0c"}},
+			err:    "unsupported checksum algorithm CRC32",
+		},
+	}
+
+	localPath := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(localPath, []byte("test"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyFile(tt.remote, localPath, "/f")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("VerifyFile error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("VerifyFile = %+v, %v, want %+v", got, err, tt.want)
+			}
+			if mismatch := errors.Is(got.Err(), ErrMismatch); mismatch != tt.mismatch {
+				t.Errorf("Err() = %v, want mismatch %v", got.Err(), tt.mismatch)
+			}
+		})
+	}
+}
+
+// verifyRemote adds the Verifier methods to fakeRemote.
+type verifyRemote struct {
+	*fakeRemote
+}
+
+func (r verifyRemote) Size(remotePath string) (int64, error) {
+	entry, ok := r.entries[path.Clean(remotePath)]
+	if !ok || entry.isDir {
+		return 0, fmt.Errorf("%s: %w", remotePath, fs.ErrNotExist)
+	}
+	return int64(len(entry.data)), nil
+}
+
+func (r verifyRemote) Checksum(remotePath string) (Checksum, error) {
+	sum := sha256.Sum256(r.entries[path.Clean(remotePath)].data)
+	return Checksum{Algorithm: "SHA-256", Sum: hex.EncodeToString(sum[:])}, nil
+}
+
+func TestVerifyTree(t *testing.T) {
+	tests := []struct {
+		name  string
+		local map[string]string
+		// missingLocal removes the local root before verifying.
+		missingLocal bool
+		remote       map[string]string
+		remoteRoot   string
+		want         []string
+		err          string
+	}{
+		{
+			name:   "identical trees",
+			local:  map[string]string{"a.txt": "a", "sub/b.txt": "b", "empty/": ""},
+			remote: map[string]string{"a.txt": "a", "sub/b.txt": "b"},
+			want:   []string{"a.txt ok SHA-256", "sub/b.txt ok SHA-256"},
+		},
+		{
+			name:   "differences",
+			local:  map[string]string{"a.txt": "a", "b.txt": "bb", "c.txt": "c"},
+			remote: map[string]string{"a.txt": "x", "b.txt": "b", "d.txt": "d"},
+			want:   []string{"a.txt mismatch SHA-256", "b.txt mismatch size", "c.txt missing ", "d.txt extra "},
+		},
+		{
+			name:       "missing remote root",
+			local:      map[string]string{"a.txt": "a"},
+			remote:     map[string]string{},
+			remoteRoot: "/missing",
+			want:       []string{"a.txt missing "},
+		},
+		{
+			name:         "missing local root",
+			missingLocal: true,
+			remote:       map[string]string{"a.txt": "a"},
+			err:          "failed to read",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			localRoot := filepath.Join(t.TempDir(), "local")
+			if !tt.missingLocal {
+				if err := os.Mkdir(localRoot, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				writeTree(t, localRoot, tt.local)
+			}
+			remoteRoot := tt.remoteRoot
+			if remoteRoot == "" {
+				remoteRoot = "/"
+			}
+
+			results, err := VerifyTree(verifyRemote{newFakeRemote(tt.remote)}, localRoot, remoteRoot, Filter{})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("VerifyTree error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyTree failed: %v", err)
+			}
+
+			got := make([]string, len(results))
+			for i, result := range results {
+				got[i] = fmt.Sprintf("%s %s %s", result.Path, result.Status, result.Method)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("VerifyTree = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyFileMissingLocal(t *testing.T) {
+	_, err := VerifyFile(fakeVerifier{size: 4}, filepath.Join(t.TempDir(), "missing"), "/f")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("VerifyFile = %v, want a not exist error", err)
+	}
+}
+
+func TestHashLocal(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		want      string
+		err       string
+	}{
+		{algorithm: "SHA-256", want: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		{algorithm: "sha-512", want: "ee26b0dd4af7e749aa1a8ee3c10ae9923f618980772e473f8819a5d4940e0db27ac185f8a0e1d5f84f88bc887fd67b143732c304cc5fa9ad8e6f57f50028a8ff"},
+		{algorithm: "SHA-1", want: "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"},
+		{algorithm: "MD5", want: "098f6bcd4621d373cade4e832627b4f6"},
+		{algorithm: "CRC32", err: "unsupported checksum algorithm CRC32"},
+	}
+
+	localPath := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(localPath, []byte("test"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		got, err := hashLocal(localPath, tt.algorithm)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("hashLocal(%s) error = %v, want %q", tt.algorithm, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("hashLocal(%s) = %q, %v, want %q", tt.algorithm, got, err, tt.want)
+		}
+	}
+
+	if _, err := hashLocal(filepath.Join(t.TempDir(), "missing"), "MD5"); err == nil {
+		t.Error("hashLocal succeeded for a missing file")
+	}
+}
+
+func TestVerificationErr(t *testing.T) {
+	tests := []struct {
+		result Verification
+		want   string
+	}{
+		{result: Verification{Path: "a", Status: VerifyOK}},
+		{result: Verification{Path: "a", Status: VerifyMismatch, Method: "size", LocalSize: 1, RemoteSize: 2}, want: "a: content differs: local 1 bytes, remote 2 bytes"},
+		{result: Verification{Path: "a", Status: VerifyMismatch, Method: "MD5", Local: "aa", Remote: "bb"}, want: "a: content differs: local MD5 aa, remote bb"},
+		{result: Verification{Path: "a", Status: VerifyError, Error: "timeout"}, want: "a: timeout"},
+		{result: Verification{Path: "a", Status: VerifyMissing}, want: "a: missing"},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		if err := tt.result.Err(); err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("Err() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"servercommander/src/services/config"
+)
+
+// DefaultTimeout is the period after which an unlocked vault locks itself
+// again when no explicit timeout is requested.
+const DefaultTimeout = 15 * time.Minute
+
+const (
+	vaultFileName    = "vault.json"
+	vaultVersion     = 1
+	vaultCipher      = "xchacha20-poly1305"
+	vaultKDF         = "argon2id"
+	vaultKeyLength   = chacha20poly1305.KeySize
+	vaultSaltLength  = 16
+	vaultAssociation = "servercommander-vault"
+)
+
+var (
+	// ErrNotInitialised is returned when no vault file exists yet.
+	ErrNotInitialised = errors.New("vault is not initialised. Run 'vault init' first")
+	// ErrLocked is returned when an operation requires the unlocked vault.
+	ErrLocked = errors.New("vault is locked. Run 'vault unlock' first")
+	// ErrWrongPassword is returned when the master password cannot decrypt
+	// the vault.
+	ErrWrongPassword = errors.New("invalid master password")
+)
+
+// SecretKind distinguishes the secrets stored for a session.
+type SecretKind string
+
+const (
+	KindPassword   SecretKind = "password"
+	KindPassphrase SecretKind = "passphrase"
+)
+
+// ParseSecretKind validates a user supplied secret kind.
+func ParseSecretKind(value string) (SecretKind, error) {
+	kind := SecretKind(strings.ToLower(value))
+	switch kind {
+	case KindPassword, KindPassphrase:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("unknown secret kind '%s' (expected password or passphrase)", value)
+	}
+}
+
+// Entry identifies a stored secret without revealing it.
+type Entry struct {
+	Alias string
+	Kind  SecretKind
+}
+
+// kdfParams records the Argon2id parameters used to derive the vault key so
+// they can be tuned without breaking existing files.
+type kdfParams struct {
+	Name    string `json:"name"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// vaultFile is the on-disk representation of the vault.
+type vaultFile struct {
+	Version int       `json:"version"`
+	KDF     kdfParams `json:"kdf"`
+	Cipher  string    `json:"cipher"`
+	Nonce   []byte    `json:"nonce"`
+	Data    []byte    `json:"data"`
+}
+
+// state holds the decrypted vault while it is unlocked.
+var state struct {
+	sync.Mutex
+	key     []byte
+	kdf     kdfParams
+	secrets map[string]string
+	expires time.Time
+}
+
+// Exists reports whether a vault file has been created.
+func Exists() (bool, error) {
+	path, err := config.FilePath(vaultFileName)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("unable to stat vault file: %w", err)
+	}
+	return true, nil
+}
+
+// Init creates an empty vault protected by the master password and leaves it
+// unlocked for the given timeout.
+func Init(master string, timeout time.Duration) error {
+	exists, err := Exists()
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New("vault already exists")
+	}
+	if master == "" {
+		return errors.New("master password cannot be empty")
+	}
+
+	salt := make([]byte, vaultSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	params := kdfParams{Name: vaultKDF, Salt: salt, Time: 3, Memory: 64 * 1024, Threads: 4}
+
+	state.Lock()
+	defer state.Unlock()
+
+	state.key = deriveKey(master, params)
+	state.kdf = params
+	state.secrets = map[string]string{}
+	state.expires = time.Now().Add(timeout)
+
+	if err := save(); err != nil {
+		wipe()
+		return err
+	}
+	return nil
+}
+
+// Unlock decrypts the vault with the master password. The vault locks itself
+// again once timeout has elapsed.
+func Unlock(master string, timeout time.Duration) error {
+	file, err := load()
+	if err != nil {
+		return err
+	}
+
+	key := deriveKey(master, file.KDF)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return fmt.Errorf("failed to initialise vault cipher: %w", err)
+	}
+
+	plaintext, err := aead.Open(nil, file.Nonce, file.Data, []byte(vaultAssociation))
+	if err != nil {
+		return ErrWrongPassword
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return fmt.Errorf("invalid vault contents: %w", err)
+	}
+
+	state.Lock()
+	defer state.Unlock()
+
+	state.key = key
+	state.kdf = file.KDF
+	state.secrets = secrets
+	state.expires = time.Now().Add(timeout)
+	return nil
+}
+
+// Lock discards the decrypted secrets and the derived key from memory.
+func Lock() {
+	state.Lock()
+	defer state.Unlock()
+	wipe()
+}
+
+// IsUnlocked reports whether the vault is currently unlocked.
+func IsUnlocked() bool {
+	state.Lock()
+	defer state.Unlock()
+	return unlocked()
+}
+
+// ExpiresAt returns the time at which the unlocked vault locks itself.
+func ExpiresAt() time.Time {
+	state.Lock()
+	defer state.Unlock()
+	return state.expires
+}
+
+// Lookup returns the secret stored for the session alias. It reports false
+// when the vault is locked or no such secret exists.
+func Lookup(alias string, kind SecretKind) (string, bool) {
+	state.Lock()
+	defer state.Unlock()
+
+	if !unlocked() {
+		return "", false
+	}
+	secret, ok := state.secrets[secretKey(alias, kind)]
+	return secret, ok
+}
+
+// Set stores a secret for the session alias and persists the vault.
+func Set(alias string, kind SecretKind, secret string) error {
+	state.Lock()
+	defer state.Unlock()
+
+	if !unlocked() {
+		return ErrLocked
+	}
+	state.secrets[secretKey(alias, kind)] = secret
+	return save()
+}
+
+// Remove deletes a secret from the vault and persists the change.
+func Remove(alias string, kind SecretKind) error {
+	state.Lock()
+	defer state.Unlock()
+
+	if !unlocked() {
+		return ErrLocked
+	}
+	key := secretKey(alias, kind)
+	if _, ok := state.secrets[key]; !ok {
+		return fmt.Errorf("no %s stored for '%s'", kind, strings.ToLower(alias))
+	}
+	delete(state.secrets, key)
+	return save()
+}
+
+// List returns the stored entries sorted by alias and kind.
+func List() ([]Entry, error) {
+	state.Lock()
+	defer state.Unlock()
+
+	if !unlocked() {
+		return nil, ErrLocked
+	}
+
+	entries := make([]Entry, 0, len(state.secrets))
+	for key := range state.secrets {
+		alias, kind, _ := strings.Cut(key, "\x00")
+		entries = append(entries, Entry{Alias: alias, Kind: SecretKind(kind)})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Alias == entries[j].Alias {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].Alias < entries[j].Alias
+	})
+
+	return entries, nil
+}
+
+func secretKey(alias string, kind SecretKind) string {
+	return strings.ToLower(alias) + "\x00" + string(kind)
+}
+
+// unlocked must be called with the state mutex held. It locks the vault when
+// the timeout has elapsed.
+func unlocked() bool {
+	if state.key == nil {
+		return false
+	}
+	if time.Now().After(state.expires) {
+		wipe()
+		return false
+	}
+	return true
+}
+
+// wipe must be called with the state mutex held.
+func wipe() {
+	for i := range state.key {
+		state.key[i] = 0
+	}
+	state.key = nil
+	state.secrets = nil
+	state.expires = time.Time{}
+}
+
+func deriveKey(master string, params kdfParams) []byte {
+	return argon2.IDKey([]byte(master), params.Salt, params.Time, params.Memory, params.Threads, vaultKeyLength)
+}
+
+func load() (*vaultFile, error) {
+	path, err := config.FilePath(vaultFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotInitialised
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read vault file: %w", err)
+	}
+
+	file := &vaultFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("invalid vault file: %w", err)
+	}
+	if file.Version != vaultVersion || file.Cipher != vaultCipher || file.KDF.Name != vaultKDF {
+		return nil, fmt.Errorf("unsupported vault format (version %d, cipher %s, kdf %s)", file.Version, file.Cipher, file.KDF.Name)
+	}
+
+	return file, nil
+}
+
+// save encrypts the secrets with a fresh nonce and atomically replaces the
+// vault file. It must be called with the state mutex held.
+func save() error {
+	path, err := config.FilePath(vaultFileName)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(state.secrets)
+	if err != nil {
+		return fmt.Errorf("failed to serialise vault: %w", err)
+	}
+
+	aead, err := chacha20poly1305.NewX(state.key)
+	if err != nil {
+		return fmt.Errorf("failed to initialise vault cipher: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	file := vaultFile{
+		Version: vaultVersion,
+		KDF:     state.kdf,
+		Cipher:  vaultCipher,
+		Nonce:   nonce,
+		Data:    aead.Seal(nil, nonce, plaintext, []byte(vaultAssociation)),
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialise vault: %w", err)
+	}
+
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+
+	return nil
+}
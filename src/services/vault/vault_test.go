@@ -0,0 +1,209 @@
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"servercommander/src/services/config"
+)
+
+func newTestVault(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Cleanup(Lock)
+	if err := Init("master", time.Minute); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+}
+
+func TestSealAndOpen(t *testing.T) {
+	newTestVault(t)
+
+	secrets := []struct {
+		alias  string
+		kind   SecretKind
+		secret string
+	}{
+		{alias: "Web", kind: KindPassword, secret: "p@ss word"},
+		{alias: "web", kind: KindPassphrase, secret: "key phrase"},
+		{alias: "db", kind: KindPassword, secret: ""},
+	}
+	for _, s := range secrets {
+		if err := Set(s.alias, s.kind, s.secret); err != nil {
+			t.Fatalf("Set(%s, %s) failed: %v", s.alias, s.kind, err)
+		}
+	}
+
+	path, err := config.FilePath(vaultFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "p@ss word") || strings.Contains(string(data), "key phrase") {
+		t.Error("vault file contains a secret in plain text")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("vault file mode = %v, %v", info.Mode(), err)
+	}
+
+	Lock()
+	if IsUnlocked() {
+		t.Fatal("vault still unlocked after Lock")
+	}
+	if _, ok := Lookup("web", KindPassword); ok {
+		t.Error("Lookup succeeded on a locked vault")
+	}
+	if err := Set("x", KindPassword, "y"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Set on a locked vault = %v, want ErrLocked", err)
+	}
+
+	if err := Unlock("master", time.Minute); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	for _, s := range secrets {
+		if got, ok := Lookup(strings.ToUpper(s.alias), s.kind); !ok || got != s.secret {
+			t.Errorf("Lookup(%s, %s) = %q, %v, want %q", s.alias, s.kind, got, ok, s.secret)
+		}
+	}
+
+	entries, err := List()
+	want := []Entry{{Alias: "db", Kind: KindPassword}, {Alias: "web", Kind: KindPassphrase}, {Alias: "web", Kind: KindPassword}}
+	if err != nil || !reflect.DeepEqual(entries, want) {
+		t.Errorf("List = %v, %v, want %v", entries, err, want)
+	}
+
+	if err := Remove("DB", KindPassword); err != nil {
+		t.Errorf("Remove failed: %v", err)
+	}
+	if err := Remove("db", KindPassword); err == nil || !strings.Contains(err.Error(), "no password stored for 'db'") {
+		t.Errorf("second Remove = %v", err)
+	}
+}
+
+func TestUnlockErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		master string
+		tamper func(file map[string]any)
+		err    error
+		msg    string
+	}{
+		{name: "wrong password", master: "Master", err: ErrWrongPassword},
+		{name: "empty password", master: "", err: ErrWrongPassword},
+		{
+			name:   "tampered data",
+			master: "master",
+			tamper: func(file map[string]any) {
+				data, _ := base64.StdEncoding.DecodeString(file["data"].(string))
+				data[len(data)/2] ^= 1
+				file["data"] = base64.StdEncoding.EncodeToString(data)
+			},
+			err: ErrWrongPassword,
+		},
+		{
+			name:   "unknown cipher",
+			master: "master",
+			tamper: func(file map[string]any) { file["cipher"] = "rot13" },
+			msg:    "unsupported vault format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestVault(t)
+			if err := Set("web", KindPassword, "secret"); err != nil {
+				t.Fatal(err)
+			}
+			Lock()
+
+			if tt.tamper != nil {
+				path, _ := config.FilePath(vaultFileName)
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				file := map[string]any{}
+				if err := json.Unmarshal(data, &file); err != nil {
+					t.Fatal(err)
+				}
+				tt.tamper(file)
+				if data, err = json.Marshal(file); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, data, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := Unlock(tt.master, time.Minute)
+			if tt.err != nil && !errors.Is(err, tt.err) || tt.msg != "" && (err == nil || !strings.Contains(err.Error(), tt.msg)) {
+				t.Errorf("Unlock error = %v, want %v %q", err, tt.err, tt.msg)
+			}
+			if IsUnlocked() {
+				t.Error("vault unlocked after a failed Unlock")
+			}
+		})
+	}
+}
+
+func TestVaultLifecycle(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Cleanup(Lock)
+
+	if exists, err := Exists(); exists || err != nil {
+		t.Fatalf("Exists = %v, %v before Init", exists, err)
+	}
+	if err := Unlock("master", time.Minute); !errors.Is(err, ErrNotInitialised) {
+		t.Errorf("Unlock before Init = %v, want ErrNotInitialised", err)
+	}
+	if err := Init("", time.Minute); err == nil {
+		t.Error("Init accepted an empty master password")
+	}
+
+	if err := Init("master", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init("master", time.Minute); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("second Init = %v", err)
+	}
+	if !IsUnlocked() {
+		t.Fatal("vault locked right after Init")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if IsUnlocked() {
+		t.Error("vault still unlocked after its timeout")
+	}
+	if _, err := List(); !errors.Is(err, ErrLocked) {
+		t.Errorf("List after timeout = %v, want ErrLocked", err)
+	}
+}
+
+func TestParseSecretKind(t *testing.T) {
+	tests := []struct {
+		value string
+		want  SecretKind
+		ok    bool
+	}{
+		{value: "password", want: KindPassword, ok: true},
+		{value: "Passphrase", want: KindPassphrase, ok: true},
+		{value: "token", ok: false},
+		{value: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSecretKind(tt.value)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseSecretKind(%q) = %q, %v", tt.value, got, err)
+		}
+	}
+}
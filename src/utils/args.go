@@ -0,0 +1,279 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrUnterminatedQuote is returned when a quoted argument is not closed.
+	ErrUnterminatedQuote = errors.New("unterminated quote")
+	// ErrUnterminatedVariable is returned when a ${VAR} reference is not
+	// closed.
+	ErrUnterminatedVariable = errors.New("unterminated variable reference")
+)
+
+// SplitCommandLine splits input into arguments following POSIX shell rules:
+//
+//   - single quotes preserve their content literally;
+//   - double quotes preserve whitespace but expand variables and accept the
+//     escapes \", \\ and \$;
+//   - outside quotes a backslash escapes whitespace, quotes, backslashes, $
+//     and ~. Other backslashes are kept so Windows paths need no quoting;
+//   - $VAR and ${VAR} expand to environment variables, undefined ones to an
+//     empty string;
+//   - a leading ~ or ~/ expands to the user's home directory.
+func SplitCommandLine(input string) ([]string, error) {
+	t := tokenizer{input: []rune(input), expand: true}
+	if err := t.run(); err != nil {
+		return nil, err
+	}
+	return t.args, nil
+}
+
+// SplitCommandLineOffsets splits input like SplitCommandLine and also returns
+// the byte offset at which each argument starts, so that callers can recover
+// the remainder of the line as it was typed.
+func SplitCommandLineOffsets(input string) ([]string, []int, error) {
+	t := tokenizer{input: []rune(input), expand: true}
+	if err := t.run(); err != nil {
+		return nil, nil, err
+	}
+	offsets := make([]int, len(t.starts))
+	for i, start := range t.starts {
+		offsets[i] = len(string(t.input[:start]))
+	}
+	return t.args, offsets, nil
+}
+
+// SplitCompletionLine splits a partially typed command line for completion.
+// It never fails: an unterminated quote simply extends to the end of the line.
+// It returns the completed arguments, the unquoted word being typed (without
+// variable or ~ expansion) and the byte offset at which that word starts.
+func SplitCompletionLine(input string) ([]string, string, int) {
+	t := tokenizer{input: []rune(input), expand: true, lenient: true}
+	_ = t.run()
+
+	if !t.open {
+		return t.args, "", len(input)
+	}
+
+	start := len(string(t.input[:t.start]))
+	word := tokenizer{input: t.input[t.start:], lenient: true}
+	_ = word.run()
+
+	args := t.args[:len(t.args)-1]
+	if len(word.args) == 0 {
+		return args, "", start
+	}
+	return args, word.args[0], start
+}
+
+// QuoteArgument escapes value so that SplitCommandLine yields it unchanged.
+// A leading ~/ is kept unescaped so that completed paths stay expandable.
+func QuoteArgument(value string) string {
+	if value == "" {
+		return `""`
+	}
+
+	var builder strings.Builder
+	for i, r := range value {
+		switch {
+		case r == '~' && i == 0:
+		case unicode.IsSpace(r), strings.ContainsRune(`'"\$~`, r):
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+type tokenizer struct {
+	input   []rune
+	pos     int
+	expand  bool
+	lenient bool
+
+	args    []string
+	current strings.Builder
+	inToken bool
+	start   int
+	// starts holds the rune index at which each argument of args began.
+	starts []int
+	// open reports whether the input ended inside the last argument.
+	open bool
+}
+
+func (t *tokenizer) run() error {
+	for t.pos < len(t.input) {
+		r := t.input[t.pos]
+		switch {
+		case unicode.IsSpace(r):
+			t.endToken()
+			t.pos++
+		case r == '\'':
+			t.beginToken()
+			if err := t.singleQuoted(); err != nil {
+				return t.finish(err)
+			}
+		case r == '"':
+			t.beginToken()
+			if err := t.doubleQuoted(); err != nil {
+				return t.finish(err)
+			}
+		case r == '\\':
+			t.beginToken()
+			t.escape(`'"\$~`, true)
+		case r == '$' && t.expand:
+			t.beginToken()
+			if err := t.variable(); err != nil {
+				return t.finish(err)
+			}
+		case r == '~' && t.expand && !t.inToken && t.atPathEnd(t.pos+1):
+			t.beginToken()
+			t.current.WriteString(homeDir())
+			t.pos++
+		default:
+			t.beginToken()
+			t.current.WriteRune(r)
+			t.pos++
+		}
+	}
+	t.open = t.inToken
+	t.endToken()
+	return nil
+}
+
+func (t *tokenizer) singleQuoted() error {
+	t.pos++
+	for t.pos < len(t.input) {
+		r := t.input[t.pos]
+		t.pos++
+		if r == '\'' {
+			return nil
+		}
+		t.current.WriteRune(r)
+	}
+	return ErrUnterminatedQuote
+}
+
+func (t *tokenizer) doubleQuoted() error {
+	t.pos++
+	for t.pos < len(t.input) {
+		r := t.input[t.pos]
+		switch {
+		case r == '"':
+			t.pos++
+			return nil
+		case r == '\\':
+			t.escape(`"\$`, false)
+		case r == '$' && t.expand:
+			if err := t.variable(); err != nil {
+				return err
+			}
+		default:
+			t.current.WriteRune(r)
+			t.pos++
+		}
+	}
+	return ErrUnterminatedQuote
+}
+
+// escape handles a backslash at the current position. The following rune is
+// taken literally when it is one of special (or whitespace, if allowed);
+// otherwise the backslash itself is kept.
+func (t *tokenizer) escape(special string, whitespace bool) {
+	t.pos++
+	if t.pos >= len(t.input) {
+		t.current.WriteRune('\\')
+		return
+	}
+
+	next := t.input[t.pos]
+	if strings.ContainsRune(special, next) || (whitespace && unicode.IsSpace(next)) {
+		t.current.WriteRune(next)
+		t.pos++
+		return
+	}
+	t.current.WriteRune('\\')
+}
+
+func (t *tokenizer) variable() error {
+	t.pos++
+	if t.pos < len(t.input) && t.input[t.pos] == '{' {
+		end := t.pos + 1
+		for end < len(t.input) && t.input[end] != '}' {
+			end++
+		}
+		if end >= len(t.input) {
+			return ErrUnterminatedVariable
+		}
+		t.current.WriteString(os.Getenv(string(t.input[t.pos+1 : end])))
+		t.pos = end + 1
+		return nil
+	}
+
+	end := t.pos
+	for end < len(t.input) && isVariableRune(t.input[end], end == t.pos) {
+		end++
+	}
+	if end == t.pos {
+		t.current.WriteRune('$')
+		return nil
+	}
+	t.current.WriteString(os.Getenv(string(t.input[t.pos:end])))
+	t.pos = end
+	return nil
+}
+
+func (t *tokenizer) atPathEnd(pos int) bool {
+	if pos >= len(t.input) {
+		return true
+	}
+	r := t.input[pos]
+	return r == '/' || r == os.PathSeparator || unicode.IsSpace(r)
+}
+
+func (t *tokenizer) beginToken() {
+	if !t.inToken {
+		t.inToken = true
+		t.start = t.pos
+	}
+}
+
+func (t *tokenizer) endToken() {
+	if t.inToken {
+		t.args = append(t.args, t.current.String())
+		t.starts = append(t.starts, t.start)
+		t.current.Reset()
+		t.inToken = false
+	}
+}
+
+// finish records the partial token in lenient mode and swallows the error.
+func (t *tokenizer) finish(err error) error {
+	if !t.lenient {
+		return err
+	}
+	t.pos = len(t.input)
+	t.open = true
+	t.endToken()
+	return nil
+}
+
+func isVariableRune(r rune, first bool) bool {
+	if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+		return true
+	}
+	return !first && r >= '0' && r <= '9'
+}
+
+func homeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "~"
+	}
+	return home
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	t.Setenv("HOME", "/home/test")
+	t.Setenv("SC_NAME", "prod")
+	t.Setenv("SC_EMPTY", "")
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+		err   error
+	}{
+		{name: "empty", input: "", want: nil},
+		{name: "blank", input: "  \t ", want: nil},
+		{name: "words", input: "ssh exec web", want: []string{"ssh", "exec", "web"}},
+		{name: "repeated whitespace", input: " ftp \t ls   prod ", want: []string{"ftp", "ls", "prod"}},
+		{name: "single quotes", input: `echo 'a  "b" $SC_NAME'`, want: []string{"echo", `a  "b" $SC_NAME`}},
+		{name: "double quotes", input: `echo "a  'b' $SC_NAME"`, want: []string{"echo", "a  'b' prod"}},
+		{name: "double quote escapes", input: `echo "\"x\" \\ \$SC_NAME \n"`, want: []string{"echo", `"x" \ $SC_NAME \n`}},
+		{name: "empty quotes", input: `set "" ''`, want: []string{"set", "", ""}},
+		{name: "adjacent quotes join", input: `a'b'"c"d`, want: []string{"abcd"}},
+		{name: "escaped space", input: `cd my\ dir`, want: []string{"cd", "my dir"}},
+		{name: "escaped quote", input: `echo \'x\"`, want: []string{"echo", `'x"`}},
+		{name: "windows path keeps backslashes", input: `get C:\temp\file.txt`, want: []string{"get", `C:\temp\file.txt`}},
+		{name: "trailing backslash", input: `echo a\`, want: []string{"echo", `a\`}},
+		{name: "variable", input: "connect $SC_NAME", want: []string{"connect", "prod"}},
+		{name: "braced variable", input: "connect ${SC_NAME}-db", want: []string{"connect", "prod-db"}},
+		{name: "variable ends at other runes", input: "x $SC_NAME.log", want: []string{"x", "prod.log"}},
+		{name: "undefined variable", input: "x a$SC_UNDEFINED_VARIABLE", want: []string{"x", "a"}},
+		{name: "empty variable keeps word", input: `x "$SC_EMPTY"`, want: []string{"x", ""}},
+		{name: "lone dollar", input: "echo $ 5$", want: []string{"echo", "$", "5$"}},
+		{name: "escaped dollar", input: `echo \$SC_NAME`, want: []string{"echo", "$SC_NAME"}},
+		{name: "home", input: "cd ~", want: []string{"cd", "/home/test"}},
+		{name: "home path", input: "cd ~/src", want: []string{"cd", "/home/test/src"}},
+		{name: "tilde inside word", input: "cd a~ ~user", want: []string{"cd", "a~", "~user"}},
+		{name: "escaped tilde", input: `cd \~/src`, want: []string{"cd", "~/src"}},
+		{name: "unterminated single quote", input: "echo 'abc", err: ErrUnterminatedQuote},
+		{name: "unterminated double quote", input: `echo "abc`, err: ErrUnterminatedQuote},
+		{name: "unterminated variable", input: "echo ${SC_NAME", err: ErrUnterminatedVariable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitCommandLine(tt.input)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("SplitCommandLine(%q) error = %v, want %v", tt.input, err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitCommandLine(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitCommandLineOffsets(t *testing.T) {
+	t.Setenv("SC_NAME", "prod")
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		offsets []int
+	}{
+		{name: "empty", input: "", want: nil, offsets: []int{}},
+		{name: "words", input: "ssh exec web", want: []string{"ssh", "exec", "web"}, offsets: []int{0, 4, 9}},
+		{name: "leading whitespace", input: "  ls \t -l", want: []string{"ls", "-l"}, offsets: []int{2, 7}},
+		{name: "quoted word starts at the quote", input: `echo "a b" 'c'`, want: []string{"echo", "a b", "c"}, offsets: []int{0, 5, 11}},
+		{name: "empty quotes", input: `x "" y`, want: []string{"x", "", "y"}, offsets: []int{0, 2, 5}},
+		{name: "variable", input: "x $SC_NAME y", want: []string{"x", "prod", "y"}, offsets: []int{0, 2, 11}},
+		{name: "multibyte runes count bytes", input: "cd Bücher ä", want: []string{"cd", "Bücher", "ä"}, offsets: []int{0, 3, 11}},
+		{name: "escaped space", input: `a my\ dir b`, want: []string{"a", "my dir", "b"}, offsets: []int{0, 2, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, offsets, err := SplitCommandLineOffsets(tt.input)
+			if err != nil {
+				t.Fatalf("SplitCommandLineOffsets(%q) failed: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(offsets, tt.offsets) {
+				t.Errorf("SplitCommandLineOffsets(%q) = %q, %v, want %q, %v", tt.input, got, offsets, tt.want, tt.offsets)
+			}
+		})
+	}
+
+	if _, _, err := SplitCommandLineOffsets("echo 'abc"); !errors.Is(err, ErrUnterminatedQuote) {
+		t.Errorf("SplitCommandLineOffsets error = %v, want ErrUnterminatedQuote", err)
+	}
+}
+
+func TestSplitCompletionLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		args  []string
+		word  string
+		start int
+	}{
+		{name: "empty", input: "", args: nil, word: "", start: 0},
+		{name: "after space", input: "ftp ls ", args: []string{"ftp", "ls"}, word: "", start: 7},
+		{name: "partial word", input: "ftp l", args: []string{"ftp"}, word: "l", start: 4},
+		{name: "open quote", input: `get "my fi`, args: []string{"get"}, word: "my fi", start: 4},
+		{name: "escaped space", input: `get my\ fi`, args: []string{"get"}, word: "my fi", start: 4},
+		{name: "variable not expanded", input: "cd $HO", args: []string{"cd"}, word: "$HO", start: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, word, start := SplitCompletionLine(tt.input)
+			if !reflect.DeepEqual(args, tt.args) || word != tt.word || start != tt.start {
+				t.Errorf("SplitCompletionLine(%q) = %q, %q, %d, want %q, %q, %d", tt.input, args, word, start, tt.args, tt.word, tt.start)
+			}
+		})
+	}
+}
+
+func TestQuoteArgument(t *testing.T) {
+	t.Setenv("HOME", "/home/test")
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "plain", value: "file.txt", want: "file.txt"},
+		{name: "empty", value: "", want: `""`},
+		{name: "space", value: "my file", want: `my\ file`},
+		{name: "tab", value: "a\tb", want: "a\\\tb"},
+		{name: "quotes", value: `it's "x"`, want: `it\'s\ \"x\"`},
+		{name: "dollar", value: "$HOME", want: `\$HOME`},
+		{name: "backslash", value: `C:\temp`, want: `C:\\temp`},
+		{name: "leading home kept", value: "~/my dir", want: `~/my\ dir`},
+		{name: "inner tilde", value: "a~b", want: `a\~b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteArgument(tt.value); got != tt.want {
+				t.Errorf("QuoteArgument(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestQuoteArgumentRoundTrip checks that quoted values split back into the
+// original value, which completion relies on.
+func TestQuoteArgumentRoundTrip(t *testing.T) {
+	values := []string{
+		"plain",
+		"",
+		"two  spaces",
+		`single ' and double " quotes`,
+		`$NOT_EXPANDED and ${NEITHER}`,
+		`C:\Program Files\app`,
+		`trailing\`,
+		"~user",
+		"a~b",
+		"tab\tand\nnewline",
+	}
+
+	for _, value := range values {
+		args, err := SplitCommandLine(QuoteArgument(value))
+		if err != nil {
+			t.Errorf("SplitCommandLine(QuoteArgument(%q)) failed: %v", value, err)
+			continue
+		}
+		if len(args) != 1 || args[0] != value {
+			t.Errorf("SplitCommandLine(QuoteArgument(%q)) = %q", value, args)
+		}
+	}
+}
@@ -1,6 +1,14 @@
 package utils
 
-const (
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Terminal color sequences. They are variables so that DisableColors can
+// blank them when the output is not a terminal.
+var (
 	Reset  = "\033[0m"
 	Red    = "\033[31m"
 	Green  = "\033[32m"
@@ -10,3 +18,16 @@ const (
 	Cyan   = "\033[36m"
 	White  = "\033[37m"
 )
+
+// Colors are suppressed automatically when STDOUT is redirected or NO_COLOR
+// is set, so piped output contains no escape sequences.
+func init() {
+	if os.Getenv("NO_COLOR") != "" || !term.IsTerminal(int(os.Stdout.Fd())) {
+		DisableColors()
+	}
+}
+
+// DisableColors turns all color sequences into empty strings.
+func DisableColors() {
+	Reset, Red, Green, Yellow, Blue, Purple, Cyan, White = "", "", "", "", "", "", "", ""
+}
@@ -0,0 +1,16 @@
+package utils
+
+import "fmt"
+
+// ExitError is returned by the exit command outside the interactive console.
+// Scripts stop at it and the program ends with Code. Explicit is false when
+// no status was given, in which case a script keeps the status of the
+// commands that ran before.
+type ExitError struct {
+	Code     int
+	Explicit bool
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit %d", e.Code)
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatBytes renders a byte count using binary units, e.g. "1.5 MiB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value := float64(n)
+	suffixes := []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+	for i, suffix := range suffixes {
+		value /= unit
+		if value < unit || i == len(suffixes)-1 {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// FormatRate renders the throughput of n bytes moved in elapsed, e.g.
+// "12.3 MiB/s".
+func FormatRate(n int64, elapsed time.Duration) string {
+	if elapsed <= 0 {
+		return "- B/s"
+	}
+	return FormatBytes(int64(float64(n)/elapsed.Seconds())) + "/s"
+}
+
+// ParseBytes converts sizes such as "512K", "1.5M" or "2GiB" into bytes.
+// Units are binary; a plain number is taken as bytes.
+func ParseBytes(value string) (int64, error) {
+	number := strings.TrimSpace(value)
+	unit := strings.TrimLeft(number, "0123456789.")
+	number = strings.TrimSuffix(number, unit)
+
+	multipliers := map[string]float64{
+		"": 1, "b": 1,
+		"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+		"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+		"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	}
+	multiplier, ok := multipliers[strings.ToLower(strings.TrimSpace(unit))]
+	size, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size '%s'; expected a number with an optional K, M or G suffix", value)
+	}
+	return int64(size * multiplier), nil
+}
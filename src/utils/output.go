@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OutputFormat selects how list and show commands print their results.
+type OutputFormat string
+
+const (
+	OutputTable OutputFormat = "table"
+	OutputJSON  OutputFormat = "json"
+	OutputYAML  OutputFormat = "yaml"
+	OutputCSV   OutputFormat = "csv"
+)
+
+// OutputFormats lists the accepted output format names.
+func OutputFormats() []string {
+	return []string{string(OutputTable), string(OutputJSON), string(OutputYAML), string(OutputCSV)}
+}
+
+// Render prints value in the requested format. value must be a struct or a
+// slice of structs; field names are taken from their json tags so that every
+// machine-readable format uses the same names. The table format is specific to
+// each command and therefore delegated to the table callback.
+func Render(format OutputFormat, value any, table func() error) error {
+	// Encode empty lists as [] rather than null.
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Slice && v.IsNil() {
+		value = reflect.MakeSlice(v.Type(), 0, 0).Interface()
+	}
+
+	switch format {
+	case OutputTable, "":
+		return table()
+	case OutputJSON:
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	case OutputYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(value); err != nil {
+			return fmt.Errorf("failed to encode YAML: %w", err)
+		}
+		return encoder.Close()
+	case OutputCSV:
+		return renderCSV(value)
+	default:
+		return fmt.Errorf("unsupported output format '%s'", format)
+	}
+}
+
+func renderCSV(value any) error {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	rows := []reflect.Value{v}
+	elemType := v.Type()
+	if v.Kind() == reflect.Slice {
+		rows = rows[:0]
+		for i := 0; i < v.Len(); i++ {
+			rows = append(rows, reflect.Indirect(v.Index(i)))
+		}
+		elemType = v.Type().Elem()
+		if elemType.Kind() == reflect.Pointer {
+			elemType = elemType.Elem()
+		}
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot render %s as CSV", elemType)
+	}
+
+	headers, fields := csvColumns(elemType)
+	writer := csv.NewWriter(os.Stdout)
+	if err := writer.Write(headers); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(fields))
+		for i, index := range fields {
+			record[i] = csvValue(row.Field(index))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvColumns(t reflect.Type) ([]string, []int) {
+	headers := []string{}
+	fields := []int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		headers = append(headers, name)
+		fields = append(fields, i)
+	}
+	return headers, fields
+}
+
+func csvValue(v reflect.Value) string {
+	switch value := v.Interface().(type) {
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		return value.Format(time.RFC3339)
+	case []string:
+		return strings.Join(value, ";")
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type renderItem struct {
+	Name    string    `json:"name" yaml:"name"`
+	Port    int       `json:"port,omitempty" yaml:"port,omitempty"`
+	Tags    []string  `json:"tags" yaml:"tags"`
+	Created time.Time `json:"created" yaml:"created"`
+	Secret  string    `json:"-" yaml:"-"`
+	Plain   bool
+	hidden  string
+}
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	err = fn()
+	writer.Close()
+	return <-output, err
+}
+
+func TestRender(t *testing.T) {
+	created := time.Date(2024, 10, 17, 4, 41, 0, 0, time.UTC)
+	items := []renderItem{
+		{Name: "web", Port: 22, Tags: []string{"prod", "eu"}, Created: created, Secret: "s", Plain: true, hidden: "h"},
+		{Name: "a,b \"c\""},
+	}
+
+	tests := []struct {
+		name   string
+		format OutputFormat
+		value  any
+		want   string
+		err    string
+	}{
+		{
+			name:   "json list",
+			format: OutputJSON,
+			value:  items[:1],
+			want:   "[\n  {\n    \"name\": \"web\",\n    \"port\": 22,\n    \"tags\": [\n      \"prod\",\n      \"eu\"\n    ],\n    \"created\": \"2024-10-17T04:41:00Z\",\n    \"Plain\": true\n  }\n]\n",
+		},
+		{name: "json empty list", format: OutputJSON, value: []renderItem(nil), want: "[]\n"},
+		{name: "yaml empty list", format: OutputYAML, value: []renderItem(nil), want: "[]\n"},
+		{
+			name:   "yaml struct",
+			format: OutputYAML,
+			value:  renderItem{Name: "web", Tags: []string{"prod"}, Created: created},
+			want:   "name: web\ntags:\n  - prod\ncreated: 2024-10-17T04:41:00Z\nplain: false\n",
+		},
+		{
+			name:   "csv list",
+			format: OutputCSV,
+			value:  items,
+			want:   "name,port,tags,created,Plain\nweb,22,prod;eu,2024-10-17T04:41:00Z,true\n\"a,b \"\"c\"\"\",0,,,false\n",
+		},
+		{name: "csv pointers", format: OutputCSV, value: []*renderItem{&items[1]}, want: "name,port,tags,created,Plain\n\"a,b \"\"c\"\"\",0,,,false\n"},
+		{name: "csv struct", format: OutputCSV, value: &items[1], want: "name,port,tags,created,Plain\n\"a,b \"\"c\"\"\",0,,,false\n"},
+		{name: "csv empty list", format: OutputCSV, value: []renderItem{}, want: "name,port,tags,created,Plain\n"},
+		{name: "csv scalars", format: OutputCSV, value: []string{"a"}, err: "cannot render string as CSV"},
+		{name: "table", format: OutputTable, value: items, want: "table\n"},
+		{name: "default is table", format: "", value: items, want: "table\n"},
+		{name: "unknown format", format: "xml", value: items, err: "unsupported output format 'xml'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := captureStdout(t, func() error {
+				return Render(tt.format, tt.value, func() error {
+					_, err := os.Stdout.WriteString("table\n")
+					return err
+				})
+			})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Render error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render output =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTableError(t *testing.T) {
+	want := errors.New("broken")
+	if err := Render(OutputTable, nil, func() error { return want }); err != want {
+		t.Errorf("Render = %v, want the table error", err)
+	}
+}
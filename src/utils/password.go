@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ErrInterrupted is returned when the user cancels an input prompt with
+// Ctrl+C.
+var ErrInterrupted = errors.New("input cancelled")
+
+const (
+	keyCtrlC     = 0x03
+	keyCtrlD     = 0x04
+	keyBackspace = 0x08
+	keyCtrlU     = 0x15
+	keyEscape    = 0x1b
+	keyDelete    = 0x7f
+)
+
+// readMasked reads a line from the terminal in raw mode. Input is not echoed;
+// an asterisk is printed for every character instead. Backspace removes the
+// last character, Ctrl+U clears the line and Ctrl+C cancels the prompt.
+func readMasked(fd int) (string, error) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to switch terminal to raw mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	value := []byte{}
+	buffer := make([]byte, 1)
+	escape := false
+
+	for {
+		n, err := os.Stdin.Read(buffer)
+		if err != nil {
+			fmt.Print("\r\n")
+			return "", err
+		}
+		if n == 0 {
+			continue
+		}
+
+		b := buffer[0]
+		if escape {
+			// Skip the remainder of escape sequences such as arrow keys. CSI
+			// sequences end with a byte in the range 0x40-0x7e.
+			if b >= 0x40 && b <= 0x7e && b != '[' && b != 'O' {
+				escape = false
+			}
+			continue
+		}
+
+		switch {
+		case b == '\r' || b == '\n':
+			fmt.Print("\r\n")
+			return string(value), nil
+		case b == keyCtrlC:
+			fmt.Print("^C\r\n")
+			return "", ErrInterrupted
+		case b == keyCtrlD:
+			if len(value) == 0 {
+				fmt.Print("\r\n")
+				return "", io.EOF
+			}
+		case b == keyBackspace || b == keyDelete:
+			if len(value) > 0 {
+				_, size := utf8.DecodeLastRune(value)
+				value = value[:len(value)-size]
+				fmt.Print("\b \b")
+			}
+		case b == keyCtrlU:
+			for count := utf8.RuneCount(value); count > 0; count-- {
+				fmt.Print("\b \b")
+			}
+			value = value[:0]
+		case b == keyEscape:
+			escape = true
+		case b < 0x20:
+			// Ignore remaining control characters.
+		default:
+			value = append(value, b)
+			// Only acknowledge the first byte of multi-byte characters.
+			if b&0xC0 != 0x80 {
+				fmt.Print("*")
+			}
+		}
+	}
+}
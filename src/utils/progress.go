@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	progressBarWidth    = 24
+	progressRedraw      = 100 * time.Millisecond
+	progressLogInterval = 5 * time.Second
+)
+
+// ProgressBar renders the progress of one file transfer on STDERR. On a
+// terminal a single line is redrawn in place and cleared when the transfer
+// ends; otherwise a plain status line is printed every few seconds so that
+// logs of long transfers still show that data is moving.
+type ProgressBar struct {
+	name        string
+	total       int64
+	offset      int64
+	transferred int64
+	started     time.Time
+	finished    time.Time
+	lastPrint   time.Time
+	out         io.Writer
+	live        bool
+}
+
+// NewProgressBar starts a progress bar for name. total is -1 when the size is
+// unknown and offset is the number of bytes skipped by a resumed transfer,
+// which are excluded from the rate.
+func NewProgressBar(name string, total, offset int64) *ProgressBar {
+	now := time.Now()
+	return &ProgressBar{
+		name:        name,
+		total:       total,
+		offset:      offset,
+		transferred: offset,
+		started:     now,
+		lastPrint:   now,
+		out:         os.Stderr,
+		live:        term.IsTerminal(int(os.Stderr.Fd())),
+	}
+}
+
+// Update records the number of bytes transferred so far.
+func (b *ProgressBar) Update(transferred int64) {
+	b.transferred = transferred
+
+	now := time.Now()
+	interval := progressLogInterval
+	if b.live {
+		interval = progressRedraw
+	}
+	if now.Sub(b.lastPrint) < interval {
+		return
+	}
+	b.lastPrint = now
+
+	if b.live {
+		fmt.Fprintf(b.out, "\r%s\033[K", b.render(now, true))
+		return
+	}
+	fmt.Fprintln(b.out, b.render(now, false))
+}
+
+// Done stops the progress bar and removes it from the terminal so the caller
+// can print the outcome in its place.
+func (b *ProgressBar) Done(err error) {
+	b.finished = time.Now()
+	if b.live {
+		fmt.Fprint(b.out, "\r\033[K")
+	}
+}
+
+// Stats returns the number of bytes moved by this transfer, excluding a
+// resumed prefix, and how long it took.
+func (b *ProgressBar) Stats() (int64, time.Duration) {
+	end := b.finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return b.transferred - b.offset, end.Sub(b.started)
+}
+
+func (b *ProgressBar) render(now time.Time, live bool) string {
+	elapsed := now.Sub(b.started)
+	moved := b.transferred - b.offset
+	rate := FormatRate(moved, elapsed)
+
+	name := b.name
+	if live && len(name) > 24 {
+		name = name[:21] + "..."
+	}
+
+	if b.total <= 0 {
+		return fmt.Sprintf("%s  %s  %s", name, FormatBytes(b.transferred), rate)
+	}
+
+	percent := float64(b.transferred) / float64(b.total) * 100
+	eta := "--"
+	if moved > 0 {
+		remaining := float64(b.total-b.transferred) / (float64(moved) / elapsed.Seconds())
+		eta = (time.Duration(remaining) * time.Second).Round(time.Second).String()
+	}
+
+	if !live {
+		return fmt.Sprintf("%s: %s of %s (%.0f%%), %s, ETA %s",
+			name, FormatBytes(b.transferred), FormatBytes(b.total), percent, rate, eta)
+	}
+
+	filled := int(float64(progressBarWidth) * float64(b.transferred) / float64(b.total))
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	return fmt.Sprintf("%-24s [%s] %3.0f%%  %s / %s  %s  ETA %s",
+		name, bar, percent, FormatBytes(b.transferred), FormatBytes(b.total), rate, eta)
+}
@@ -7,11 +7,16 @@ import (
 	"os"
 	"strings"
 	"sync"
+
+	"golang.org/x/term"
 )
 
 var (
 	readerMu sync.RWMutex
 	reader   = bufio.NewReader(os.Stdin)
+	// overridden is true once SetPromptReader replaced STDIN. Password
+	// prompts then read plain lines from the custom reader.
+	overridden bool
 )
 
 // SetPromptReader allows callers to override the input source used by the
@@ -23,9 +28,17 @@ func SetPromptReader(r io.Reader) {
 
 	readerMu.Lock()
 	reader = bufio.NewReader(r)
+	overridden = true
 	readerMu.Unlock()
 }
 
+// ReadLine reads the next line from the prompt input including the trailing
+// newline. Consumers reading STDIN line by line should use it instead of their
+// own buffered reader so that prompts issued in between see the same data.
+func ReadLine() (string, error) {
+	return readLine()
+}
+
 func readLine() (string, error) {
 	readerMu.RLock()
 	active := reader
@@ -56,15 +69,29 @@ func Prompt(question, defaultValue string) (string, error) {
 }
 
 // PromptPassword reads sensitive input from the terminal without echoing the
-// characters. It returns an error when the stdin file descriptor is not a
-// terminal.
+// characters. Each typed character is acknowledged with an asterisk. When STDIN
+// is not a terminal the value is read as a plain line after printing a
+// warning, which keeps piped input and custom prompt readers working.
 func PromptPassword(question string) (string, error) {
-	fmt.Printf("%s%s (input hidden not supported): %s", Cyan, question, Reset)
-	value, err := readLine()
-	if err != nil {
-		return "", err
+	fmt.Printf("%s%s: %s", Cyan, question, Reset)
+
+	readerMu.RLock()
+	custom := overridden
+	readerMu.RUnlock()
+
+	fd := int(os.Stdin.Fd())
+	if custom || !term.IsTerminal(fd) {
+		if !custom {
+			fmt.Fprintf(os.Stderr, "%s(warning: input is not a terminal, the password is read from STDIN)%s ", Yellow, Reset)
+		}
+		value, err := readLine()
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(value, "\r\n"), nil
 	}
-	return strings.TrimSpace(value), nil
+
+	return readMasked(fd)
 }
 
 // PromptBool converts user input into a boolean. Accepted inputs are "y",
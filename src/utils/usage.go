@@ -11,3 +11,19 @@ func FormatUsageError(usage string) string {
 	}
 	return fmt.Sprintf("invalid command usage. expected: %s", usage)
 }
+
+// UsageError reports that a command was invoked with invalid arguments. It is
+// distinguished from other failures so that non-interactive runs can exit
+// with a dedicated status code.
+type UsageError struct {
+	Message string
+}
+
+func (e *UsageError) Error() string {
+	return e.Message
+}
+
+// NewUsageError returns a UsageError describing the expected usage.
+func NewUsageError(usage string) error {
+	return &UsageError{Message: FormatUsageError(usage)}
+}